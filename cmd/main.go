@@ -1,16 +1,30 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/http/account"
+	"github.com/Aytaditya/slotwise/internal/http/admin"
+	"github.com/Aytaditya/slotwise/internal/http/apikey"
 	"github.com/Aytaditya/slotwise/internal/http/assignment"
+	"github.com/Aytaditya/slotwise/internal/http/audit"
 	"github.com/Aytaditya/slotwise/internal/http/auth"
+	"github.com/Aytaditya/slotwise/internal/http/deletion"
 	Interns "github.com/Aytaditya/slotwise/internal/http/handler"
 	"github.com/Aytaditya/slotwise/internal/http/handler/mentor"
 	"github.com/Aytaditya/slotwise/internal/http/handler/project"
+	"github.com/Aytaditya/slotwise/internal/http/invitation"
+	"github.com/Aytaditya/slotwise/internal/http/organization"
+	"github.com/Aytaditya/slotwise/internal/http/trash"
+	"github.com/Aytaditya/slotwise/internal/mail"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/middleware/rbac"
+	"github.com/Aytaditya/slotwise/internal/middleware/requestid"
+	"github.com/Aytaditya/slotwise/internal/oidc"
 	"github.com/Aytaditya/slotwise/internal/storage"
 )
 
@@ -19,7 +33,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Request-ID")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -33,38 +48,133 @@ func corsMiddleware(next http.Handler) http.Handler {
 func main() {
 	cfg := config.MustLoad()
 
+	if err := jwt.LoadKeys(&cfg.JWT); err != nil {
+		log.Fatalf("Failed to load jwt keys: %v", err)
+	}
+
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+	if flag.Arg(0) == "migrate" {
+		runMigrate(cfg, flag.Args()[1:])
+		return
+	}
+
 	storage, err1 := storage.ConnectDB(cfg)
 	if err1 != nil {
-		log.Fatal("Failed to connect to db")
+		log.Fatalf("Failed to connect to db: %v", err1)
+	}
+
+	// trashed records older than trash.retention are deleted for good
+	go trash.Purge(storage, &cfg.Trash)
+
+	sender, err2 := mail.New(&cfg.Mail)
+	if err2 != nil {
+		log.Fatalf("Failed to set up mail: %v", err2)
 	}
 
 	router := http.NewServeMux()
 
-	router.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+	if cfg.OIDC.Enabled {
+		provider, err3 := oidc.New(&cfg.OIDC)
+		if err3 != nil {
+			log.Fatalf("Failed to set up single sign-on: %v", err3)
+		}
+		router.HandleFunc("GET /api/oidc/login", auth.OIDCLogin(storage, cfg, provider))
+		router.HandleFunc("GET /api/oidc/callback", auth.OIDCCallback(storage, cfg, provider))
+	}
+
+	router.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Hello, World!"))
 	})
 
-	router.HandleFunc("POST /api/signup", auth.Signup(storage))
-	router.HandleFunc("POST /api/login", auth.Login(storage))
-	router.HandleFunc("POST /api/add-mentor", mentor.AddMentor(storage))
-	router.HandleFunc("GET /api/all-intern", Interns.FetchInterns(storage))
-	router.HandleFunc("GET /api/all-mentor", mentor.FetchMentors(storage))
-	router.HandleFunc("GET /api/all-project", project.AllProjects(storage))
-	router.HandleFunc("GET /api/all-assignment", assignment.AllAssignments(storage))
-	router.HandleFunc("PUT /api/update-intern/{internId}", Interns.UpdateIntern(storage))
-	router.HandleFunc("PUT /api/update-mentor/{mentorId}", mentor.UpdateMentor(storage))
-	router.HandleFunc("PUT /api/update-project/{projectId}", project.UpdateProject(storage))
-	router.HandleFunc("PUT /api/update-assignment/{assignmentId}", assignment.UpdateAssignment(storage))
-	router.HandleFunc("DELETE /api/delete-mentor/{mentorId}", mentor.DeleteMentor(storage))
-	router.HandleFunc("DELETE /api/delete-intern/{internId}", Interns.DeleteIntern(storage))
-	router.HandleFunc("DELETE /api/delete-project/{projectId}", project.DeleteProject(storage))
-	router.HandleFunc("DELETE /api/delete-assignment/{assignmentId}", assignment.DeleteAssignment(storage))
-	router.HandleFunc("POST /api/add-intern", Interns.AddIntern(storage))
-	router.HandleFunc("POST /api/add-project", project.AddProject(storage))
-	router.HandleFunc("POST /api/add-assignment", assignment.AddAssignment(storage))
+	router.HandleFunc("GET /.well-known/jwks.json", auth.JWKS())
+	router.HandleFunc("POST /api/signup", auth.Signup(storage, cfg))
+	router.HandleFunc("POST /api/login", auth.Login(storage, cfg))
+	router.HandleFunc("POST /api/login/2fa", auth.LoginTwoFactor(storage, cfg))
+	router.HandleFunc("POST /api/login/2fa/enroll", auth.LoginTwoFactorEnroll(storage, cfg))
+	router.HandleFunc("POST /api/token/refresh", auth.Refresh(storage))
+	router.HandleFunc("POST /api/password/forgot", auth.ForgotPassword(storage, cfg, sender))
+	router.HandleFunc("POST /api/password/reset", auth.ResetPassword(storage))
+	router.HandleFunc("POST /api/accounts/activate", account.ActivateAccount(storage))
+	router.HandleFunc("POST /api/invitations/{token}/accept", invitation.AcceptInvitation(storage, cfg))
+
+	// every other /api route requires a valid token or api key, permissions are checked per route by rbac.Require
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/me", auth.Me())
+	api.HandleFunc("POST /api/logout", auth.Logout(storage))
+	api.HandleFunc("POST /api/password/change", auth.ChangePassword(storage, cfg))
+	api.HandleFunc("GET /api/sessions", auth.ListSessions(storage))
+	api.HandleFunc("DELETE /api/sessions/{sessionId}", auth.RevokeSession(storage))
+	api.HandleFunc("POST /api/sessions/revoke-all", rbac.Require(storage, rbac.Security, rbac.Update, auth.RevokeAllSessions(storage)))
+	api.HandleFunc("GET /api/2fa", rbac.Require(storage, rbac.TwoFactor, rbac.Read, auth.TwoFactorStatus(storage, cfg)))
+	api.HandleFunc("POST /api/2fa/enroll", rbac.Require(storage, rbac.TwoFactor, rbac.Create, auth.EnrollTwoFactor(storage, cfg)))
+	api.HandleFunc("POST /api/2fa/confirm", rbac.Require(storage, rbac.TwoFactor, rbac.Create, auth.ConfirmTwoFactor(storage)))
+	api.HandleFunc("POST /api/2fa/recovery-codes", rbac.Require(storage, rbac.TwoFactor, rbac.Update, auth.RegenerateRecoveryCodes(storage)))
+	api.HandleFunc("POST /api/2fa/disable", rbac.Require(storage, rbac.TwoFactor, rbac.Delete, auth.DisableTwoFactor(storage, cfg)))
+	api.HandleFunc("POST /api/api-keys", rbac.Require(storage, rbac.APIKeys, rbac.Create, apikey.CreateAPIKey(storage)))
+	api.HandleFunc("GET /api/api-keys", rbac.Require(storage, rbac.APIKeys, rbac.Read, apikey.ListAPIKeys(storage)))
+	api.HandleFunc("DELETE /api/api-keys/{apiKeyId}", rbac.Require(storage, rbac.APIKeys, rbac.Delete, apikey.RevokeAPIKey(storage)))
+	api.HandleFunc("GET /api/admins", rbac.Require(storage, rbac.Admins, rbac.Read, admin.ListAdmins(storage)))
+	api.HandleFunc("GET /api/admins/{adminId}", rbac.Require(storage, rbac.Admins, rbac.Read, admin.GetAdmin(storage)))
+	api.HandleFunc("POST /api/admins", rbac.Require(storage, rbac.Admins, rbac.Create, admin.CreateAdmin(storage)))
+	api.HandleFunc("PUT /api/admins/{adminId}", rbac.Require(storage, rbac.Admins, rbac.Update, admin.UpdateAdmin(storage)))
+	api.HandleFunc("POST /api/admins/{adminId}/activate", rbac.Require(storage, rbac.Admins, rbac.Update, admin.SetActive(storage, true)))
+	api.HandleFunc("POST /api/admins/{adminId}/deactivate", rbac.Require(storage, rbac.Admins, rbac.Update, admin.SetActive(storage, false)))
+	api.HandleFunc("POST /api/admins/{adminId}/password", rbac.Require(storage, rbac.Admins, rbac.Update, admin.SetPassword(storage)))
+	api.HandleFunc("DELETE /api/admins/{adminId}", rbac.Require(storage, rbac.Admins, rbac.Delete, admin.DeleteAdmin(storage)))
+	api.HandleFunc("GET /api/orgs", rbac.Require(storage, rbac.Organizations, rbac.Read, organization.ListOrganizations(storage)))
+	api.HandleFunc("POST /api/orgs", rbac.Require(storage, rbac.Organizations, rbac.Create, organization.CreateOrganization(storage)))
+	api.HandleFunc("POST /api/orgs/switch", rbac.Require(storage, rbac.Organizations, rbac.Read, organization.SwitchOrganization(storage)))
+	api.HandleFunc("POST /api/orgs/members", rbac.Require(storage, rbac.Organizations, rbac.Update, organization.InviteMember(storage, cfg)))
+	api.HandleFunc("DELETE /api/orgs/members/{adminId}", rbac.Require(storage, rbac.Organizations, rbac.Update, organization.RemoveMember(storage)))
+	api.HandleFunc("GET /api/orgs/invitations", rbac.Require(storage, rbac.Organizations, rbac.Read, organization.ListOrgInvitations(storage)))
+	api.HandleFunc("POST /api/orgs/invitations/{invitationId}/accept", rbac.Require(storage, rbac.Organizations, rbac.Read, organization.AnswerOrgInvitation(storage, true)))
+	api.HandleFunc("POST /api/orgs/invitations/{invitationId}/decline", rbac.Require(storage, rbac.Organizations, rbac.Read, organization.AnswerOrgInvitation(storage, false)))
+	api.HandleFunc("POST /api/accounts", rbac.Require(storage, rbac.Accounts, rbac.Create, account.CreateAccount(storage, cfg)))
+	api.HandleFunc("GET /api/login-attempts", rbac.Require(storage, rbac.Security, rbac.Read, auth.LoginAttempts(storage)))
+	api.HandleFunc("POST /api/login-attempts/unlock", rbac.Require(storage, rbac.Security, rbac.Update, auth.UnlockLogin(storage)))
+	api.HandleFunc("POST /api/invitations", rbac.Require(storage, rbac.Invitations, rbac.Create, invitation.CreateInvitation(storage, cfg, sender)))
+	api.HandleFunc("GET /api/invitations", rbac.Require(storage, rbac.Invitations, rbac.Read, invitation.ListInvitations(storage)))
+	api.HandleFunc("DELETE /api/invitations/{invitationId}", rbac.Require(storage, rbac.Invitations, rbac.Delete, invitation.RevokeInvitation(storage)))
+	api.HandleFunc("POST /api/add-mentor", rbac.Require(storage, rbac.Mentors, rbac.Create, mentor.AddMentor(storage)))
+	api.HandleFunc("GET /api/all-intern", rbac.Require(storage, rbac.Interns, rbac.Read, Interns.FetchInterns(storage)))
+	api.HandleFunc("GET /api/all-mentor", rbac.Require(storage, rbac.Mentors, rbac.Read, mentor.FetchMentors(storage)))
+	api.HandleFunc("GET /api/all-project", rbac.Require(storage, rbac.Projects, rbac.Read, project.AllProjects(storage)))
+	api.HandleFunc("GET /api/all-assignment", rbac.Require(storage, rbac.Assignments, rbac.Read, assignment.AllAssignments(storage)))
+	api.HandleFunc("GET /api/mentors/{mentorId}", rbac.Require(storage, rbac.Mentors, rbac.Read, mentor.GetMentor(storage)))
+	api.HandleFunc("GET /api/interns/{internId}", rbac.Require(storage, rbac.Interns, rbac.Read, Interns.GetIntern(storage)))
+	api.HandleFunc("GET /api/projects/{projectId}", rbac.Require(storage, rbac.Projects, rbac.Read, project.GetProject(storage)))
+	api.HandleFunc("GET /api/assignments/{assignmentId}", rbac.Require(storage, rbac.Assignments, rbac.Read, assignment.GetAssignment(storage)))
+	api.HandleFunc("PUT /api/update-intern/{internId}", rbac.Require(storage, rbac.Interns, rbac.Update, Interns.UpdateIntern(storage, cfg)))
+	api.HandleFunc("PUT /api/update-mentor/{mentorId}", rbac.Require(storage, rbac.Mentors, rbac.Update, mentor.UpdateMentor(storage, cfg)))
+	api.HandleFunc("PUT /api/update-project/{projectId}", rbac.Require(storage, rbac.Projects, rbac.Update, project.UpdateProject(storage, cfg)))
+	api.HandleFunc("PUT /api/update-assignment/{assignmentId}", rbac.Require(storage, rbac.Assignments, rbac.Update, assignment.UpdateAssignment(storage, cfg)))
+	api.HandleFunc("DELETE /api/delete-mentor/{mentorId}", rbac.Require(storage, rbac.Mentors, rbac.Delete, mentor.DeleteMentor(storage, cfg)))
+	api.HandleFunc("DELETE /api/delete-intern/{internId}", rbac.Require(storage, rbac.Interns, rbac.Delete, Interns.DeleteIntern(storage, cfg)))
+	api.HandleFunc("DELETE /api/delete-project/{projectId}", rbac.Require(storage, rbac.Projects, rbac.Delete, project.DeleteProject(storage, cfg)))
+	api.HandleFunc("DELETE /api/delete-assignment/{assignmentId}", rbac.Require(storage, rbac.Assignments, rbac.Delete, assignment.DeleteAssignment(storage, cfg)))
+	api.HandleFunc("GET /api/mentors/{mentorId}/dependents", rbac.Require(storage, rbac.Mentors, rbac.Delete, deletion.Dependents(storage, "mentor", "mentorId")))
+	api.HandleFunc("GET /api/interns/{internId}/dependents", rbac.Require(storage, rbac.Interns, rbac.Delete, deletion.Dependents(storage, "intern", "internId")))
+	api.HandleFunc("GET /api/projects/{projectId}/dependents", rbac.Require(storage, rbac.Projects, rbac.Delete, deletion.Dependents(storage, "project", "projectId")))
+	api.HandleFunc("GET /api/trash", rbac.Require(storage, rbac.Trash, rbac.Read, trash.ListTrash(storage, cfg)))
+	api.HandleFunc("POST /api/mentors/{mentorId}/restore", rbac.Require(storage, rbac.Mentors, rbac.Delete, trash.Restore(storage, "mentor", "mentorId")))
+	api.HandleFunc("POST /api/interns/{internId}/restore", rbac.Require(storage, rbac.Interns, rbac.Delete, trash.Restore(storage, "intern", "internId")))
+	api.HandleFunc("POST /api/projects/{projectId}/restore", rbac.Require(storage, rbac.Projects, rbac.Delete, trash.Restore(storage, "project", "projectId")))
+	api.HandleFunc("POST /api/assignments/{assignmentId}/restore", rbac.Require(storage, rbac.Assignments, rbac.Delete, trash.Restore(storage, "assignment", "assignmentId")))
+	api.HandleFunc("GET /api/audit", rbac.Require(storage, rbac.Audit, rbac.Read, audit.ListAudit(storage)))
+	api.HandleFunc("GET /api/mentors/{mentorId}/history", rbac.Require(storage, rbac.Audit, rbac.Read, audit.History(storage, "mentor", "mentorId")))
+	api.HandleFunc("GET /api/interns/{internId}/history", rbac.Require(storage, rbac.Audit, rbac.Read, audit.History(storage, "intern", "internId")))
+	api.HandleFunc("GET /api/projects/{projectId}/history", rbac.Require(storage, rbac.Audit, rbac.Read, audit.History(storage, "project", "projectId")))
+	api.HandleFunc("GET /api/assignments/{assignmentId}/history", rbac.Require(storage, rbac.Audit, rbac.Read, audit.History(storage, "assignment", "assignmentId")))
+	api.HandleFunc("POST /api/add-intern", rbac.Require(storage, rbac.Interns, rbac.Create, Interns.AddIntern(storage, cfg)))
+	api.HandleFunc("POST /api/add-project", rbac.Require(storage, rbac.Projects, rbac.Create, project.AddProject(storage)))
+	api.HandleFunc("POST /api/add-assignment", rbac.Require(storage, rbac.Assignments, rbac.Create, assignment.AddAssignment(storage)))
+
+	router.Handle("/api/", jwt.Authenticate(storage, api))
 
 	server := http.Server{
-		Handler: corsMiddleware(router), // CORS enabled here
+		Handler: corsMiddleware(requestid.Middleware(router)), // CORS enabled here
 		Addr:    cfg.Address,
 	}
 
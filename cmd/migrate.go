@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrate handles `migrate up|down|status`, the config flag comes before the subcommand:
+//
+//	go run ./cmd -config config/local.yaml migrate status
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	migrator, err := storage.NewMigrator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open the database: %v", err)
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		ran, err := migrator.Up()
+		for _, m := range ran {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "status":
+		list, err := migrator.Status()
+		for _, m := range list {
+			state := "pending"
+			if m.AppliedAt != nil {
+				state = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if m.Modified {
+				state += ", edited since"
+			}
+			fmt.Printf("%04d_%s  %s\n", m.Version, m.Name, state)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
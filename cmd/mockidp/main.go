@@ -0,0 +1,30 @@
+// mockidp is a throwaway OpenID Connect provider for trying single sign-on locally. It signs
+// everyone in without a password: the email comes from the email query parameter of the
+// authorization request, or -email.
+//
+//	go run ./cmd/mockidp -email admin@example.com
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Aytaditya/slotwise/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9999", "listen address")
+	clientID := flag.String("client-id", "talentflow", "accepted client id")
+	clientSecret := flag.String("client-secret", "dev-secret", "accepted client secret")
+	email := flag.String("email", "admin@example.com", "email signed in when the request has none")
+	flag.Parse()
+
+	provider, err := oidctest.New("http://"+*addr, *clientID, *clientSecret, *email)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Mock identity provider running at:", provider.Issuer)
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
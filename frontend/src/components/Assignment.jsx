@@ -27,9 +27,8 @@ const Assignment = () => {
   // Fetch data from APIs
   const fetchAssignments = async () => {
     try {
-      const response = await fetch('http://localhost:8082/api/all-assignment')
-      if (!response.ok) throw new Error('Failed to fetch assignments')
-      const data = await response.json()
+      const response = await axios.get('http://localhost:8082/api/all-assignment')
+      const data = response.data
       setAssignments(data)
     } catch (err) {
       setError('Error fetching assignments: ' + err.message)
@@ -38,9 +37,8 @@ const Assignment = () => {
 
   const fetchProjects = async () => {
     try {
-      const response = await fetch('http://localhost:8082/api/all-project')
-      if (!response.ok) throw new Error('Failed to fetch projects')
-      const data = await response.json()
+      const response = await axios.get('http://localhost:8082/api/all-project')
+      const data = response.data
       setProjects(data)
     } catch (err) {
       setError('Error fetching projects: ' + err.message)
@@ -49,9 +47,8 @@ const Assignment = () => {
 
   const fetchInterns = async () => {
     try {
-      const response = await fetch('http://localhost:8082/api/all-intern')
-      if (!response.ok) throw new Error('Failed to fetch interns')
-      const data = await response.json()
+      const response = await axios.get('http://localhost:8082/api/all-intern')
+      const data = response.data
       setInterns(data)
     } catch (err) {
       setError('Error fetching interns: ' + err.message)
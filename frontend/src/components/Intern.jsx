@@ -25,9 +25,8 @@ const Intern = () => {
 
   const fetchInterns = async () => {
     try {
-      const response = await fetch("http://localhost:8082/api/all-intern")
-      if (!response.ok) throw new Error('Failed to fetch interns')
-      const data = await response.json()
+      const response = await axios.get("http://localhost:8082/api/all-intern")
+      const data = response.data
       setInterns(data)
       console.log(data)
     } catch (err) {
@@ -37,9 +36,8 @@ const Intern = () => {
 
   const fetchMentors = async () => {
     try {
-      const response = await fetch('http://localhost:8082/api/all-mentor')
-      if (!response.ok) throw new Error('Failed to fetch mentors')
-      const data = await response.json()
+      const response = await axios.get('http://localhost:8082/api/all-mentor')
+      const data = response.data
       setMentors(data)
       console.log(data)
     } catch (err) {
@@ -24,9 +24,8 @@ const Mentor = () => {
   // Fetch mentors from API
   const fetchMentors = async () => {
     try {
-      const response = await fetch('http://localhost:8082/api/all-mentor')
-      if (!response.ok) throw new Error('Failed to fetch mentors')
-      const data = await response.json()
+      const response = await axios.get('http://localhost:8082/api/all-mentor')
+      const data = response.data
       setMentors(data)
     } catch (err) {
       setError('Error fetching mentors: ' + err.message)
@@ -27,9 +27,8 @@ const Project = () => {
   // Fetch projects from API
   const fetchProjects = async () => {
     try {
-      const response = await fetch('http://localhost:8082/api/all-project')
-      if (!response.ok) throw new Error('Failed to fetch projects')
-      const data = await response.json()
+      const response = await axios.get('http://localhost:8082/api/all-project')
+      const data = response.data
       setProjects(data)
     } catch (err) {
       setError('Error fetching projects: ' + err.message)
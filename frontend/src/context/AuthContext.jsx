@@ -1,20 +1,70 @@
-import { createContext, useContext, useState } from "react";
+import { createContext, useContext, useEffect, useState } from "react";
+import axios from "axios";
 
 const AuthContext = createContext();
 
+const API = "http://localhost:8082/api";
+
+// every /api call except login/signup needs the bearer token
+const setAuthHeader = (tokenValue) => {
+  if (tokenValue) {
+    axios.defaults.headers.common["Authorization"] = `Bearer ${tokenValue}`;
+  } else {
+    delete axios.defaults.headers.common["Authorization"];
+  }
+};
+
+setAuthHeader(localStorage.getItem("token"));
+
 export const AuthProvider = ({ children }) => {
   const [token, setToken] = useState(localStorage.getItem("token"));
 
-  const login = (tokenValue) => {
+  const login = (tokenValue, refreshToken) => {
     localStorage.setItem("token", tokenValue);
+    if (refreshToken) localStorage.setItem("refresh_token", refreshToken);
+    setAuthHeader(tokenValue);
     setToken(tokenValue); // triggers re-render everywhere
   };
 
-  const logout = () => {
+  const clear = () => {
     localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
+    setAuthHeader(null);
     setToken(null);
   };
 
+  const logout = async () => {
+    try {
+      await axios.post(`${API}/logout`, { refresh_token: localStorage.getItem("refresh_token") });
+    } catch (err) {
+      console.log(err);
+    }
+    clear();
+  };
+
+  // access tokens are short lived, renew once with the refresh token before giving up
+  useEffect(() => {
+    const id = axios.interceptors.response.use(undefined, async (error) => {
+      const original = error.config;
+      const refreshToken = localStorage.getItem("refresh_token");
+      if (error.response?.status !== 401 || original._retried || !refreshToken || original.url.endsWith("/token/refresh")) {
+        if (error.response?.status === 401 && !original.url.endsWith("/login")) clear();
+        return Promise.reject(error);
+      }
+      original._retried = true;
+      try {
+        const res = await axios.post(`${API}/token/refresh`, { refresh_token: refreshToken });
+        login(res.data.token, res.data.refresh_token);
+        original.headers["Authorization"] = `Bearer ${res.data.token}`;
+        return axios(original);
+      } catch (refreshError) {
+        clear();
+        return Promise.reject(refreshError);
+      }
+    });
+    return () => axios.interceptors.response.eject(id);
+  }, []);
+
   return (
     <AuthContext.Provider value={{ token, login, logout }}>
       {children}
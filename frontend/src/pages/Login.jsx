@@ -1,4 +1,4 @@
-import { useState } from "react"
+import { useEffect, useState } from "react"
 import axios from "axios";
 import { useNavigate } from 'react-router-dom';
 import { useAuth } from "../context/AuthContext";
@@ -9,11 +9,28 @@ export default function LoginForm() {
   const [password, setPassword] = useState("adi123")
   const [isLoading, setIsLoading] = useState(false)
   const [error, setError] = useState("")
+  // set when the password was right but the admin still has to pass two-factor
+  const [challenge, setChallenge] = useState("")
+  const [enrollment, setEnrollment] = useState(null)
+  const [code, setCode] = useState("")
+  const [recoveryCodes, setRecoveryCodes] = useState([])
 
   const navigate=useNavigate()
 
   const { login } = useAuth();
 
+  // single sign-on comes back to /login with the tokens or an error in the fragment
+  useEffect(() => {
+    if (!window.location.hash) return
+    const params = new URLSearchParams(window.location.hash.slice(1))
+    window.history.replaceState(null, "", window.location.pathname)
+    if (params.get("token")) {
+      login(params.get("token"), params.get("refresh_token"))
+    } else if (params.get("error")) {
+      setError(params.get("error"))
+    }
+  }, [])
+
   const handleSubmit = async (e) => {
     e.preventDefault()
     setError("")
@@ -22,10 +39,43 @@ export default function LoginForm() {
     try {
       const response=await axios.post("http://localhost:8082/api/login",{email,password})
       console.log(response.data);
+      if (response.data.challenge_token) {
+        setChallenge(response.data.challenge_token)
+        if (response.data.enrollment_required === "true") {
+          const res = await axios.post("http://localhost:8082/api/login/2fa/enroll", { challenge_token: response.data.challenge_token })
+          setEnrollment(res.data)
+        }
+        return
+      }
       const token = response.data.token;
-      login(token)
+      login(token, response.data.refresh_token)
+    } catch (error) {
+      console.log(error)
+      setError(error.response?.data?.error || "Login failed")
+    }
+    finally{
+      setIsLoading(false)
+    }
+  }
+
+  const handleCode = async (e) => {
+    e.preventDefault()
+    setError("")
+    setIsLoading(true)
+
+    try {
+      const response = await axios.post("http://localhost:8082/api/login/2fa", { challenge_token: challenge, code })
+      if (response.data.recovery_codes) {
+        // shown once, the user continues after saving them
+        setRecoveryCodes(response.data.recovery_codes)
+        setChallenge("")
+        setEnrollment({ done: true, token: response.data.token, refresh_token: response.data.refresh_token })
+        return
+      }
+      login(response.data.token, response.data.refresh_token)
     } catch (error) {
       console.log(error)
+      setError(error.response?.data?.error || "Invalid code")
     }
     finally{
       setIsLoading(false)
@@ -46,6 +96,61 @@ export default function LoginForm() {
 
         {/* Form Card */}
         <div className="bg-black/60 backdrop-blur-md border border-gray-800 rounded-2xl p-8 shadow-2xl animate-scale-in">
+          {recoveryCodes.length > 0 ? (
+            <div className="space-y-6">
+              <p className="text-sm text-gray-300">
+                Two-factor authentication is on. Save these recovery codes somewhere safe, each can be used once if you lose your authenticator.
+              </p>
+              <ul className="grid grid-cols-2 gap-2 font-mono text-sm text-white">
+                {recoveryCodes.map((c) => <li key={c}>{c}</li>)}
+              </ul>
+              <button
+                onClick={() => login(enrollment.token, enrollment.refresh_token)}
+                className="w-full py-3 px-4 text-white font-semibold rounded-lg border border-gray-700 bg-gradient-to-r from-blue-600 to-blue-700 hover:from-blue-700 hover:to-blue-800"
+              >
+                I have saved them
+              </button>
+            </div>
+          ) : challenge ? (
+            <form onSubmit={handleCode} className="space-y-6">
+              {enrollment && (
+                <div className="text-sm text-gray-300 space-y-2">
+                  <p>Two-factor authentication is required. Add this key to your authenticator app:</p>
+                  <p className="font-mono text-white break-all">{enrollment.secret}</p>
+                  <a href={enrollment.otpauth_uri} className="text-xs text-gray-400 hover:underline">Open in authenticator</a>
+                </div>
+              )}
+              <div>
+                <label htmlFor="code" className="block text-sm font-semibold text-gray-200 mb-3">
+                  Authentication code
+                </label>
+                <input
+                  id="code"
+                  type="text"
+                  autoComplete="one-time-code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  placeholder="6 digit code or recovery code"
+                  className="w-full px-4 py-3 bg-gray-900/70 border border-gray-700 rounded-lg text-white placeholder-gray-500 focus:outline-none focus:ring-2 focus:ring-gray-500 focus:border-transparent transition-all duration-300 hover:border-gray-600"
+                />
+              </div>
+              {error && (
+                <div className="animate-shake p-4 bg-red-900/20 border border-red-800/50 rounded-lg">
+                  <p className="text-sm text-red-400 flex items-center gap-2">
+                    <span>⚠️</span>
+                    {error}
+                  </p>
+                </div>
+              )}
+              <button
+                type="submit"
+                disabled={isLoading}
+                className="w-full py-3 px-4 text-white font-semibold rounded-lg border border-gray-700 bg-gradient-to-r from-blue-600 to-blue-700 hover:from-blue-700 hover:to-blue-800 disabled:cursor-not-allowed"
+              >
+                {isLoading ? "Verifying..." : "Verify"}
+              </button>
+            </form>
+          ) : (
           <form onSubmit={handleSubmit} className="space-y-6">
             {/* Email Input */}
             <div className="animate-slide-up" style={{ animationDelay: "0.1s" }}>
@@ -112,7 +217,15 @@ export default function LoginForm() {
               )}
             </button>
           </form>
-         
+          )}
+          {!challenge && recoveryCodes.length === 0 && (
+            <a
+              href="http://localhost:8082/api/oidc/login"
+              className="mt-4 block w-full py-3 px-4 text-center text-white font-semibold rounded-lg border border-gray-700 hover:border-gray-600 transition-all duration-300"
+            >
+              Sign in with SSO
+            </a>
+          )}
         </div>
       </div>
 
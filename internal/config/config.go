@@ -2,9 +2,9 @@ package config
 
 import (
 	"flag"
-	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -13,10 +13,125 @@ type HttpServer struct {
 	Address string `yaml:"address" env-default:"localhost:8080"`
 }
 
+// JWTKey is a single signing key, identified in tokens by its kid header
+type JWTKey struct {
+	ID             string `yaml:"id"`
+	Algorithm      string `yaml:"algorithm" env-default:"HS256"` // HS256, RS256 or EdDSA
+	Secret         string `yaml:"secret"`                        // HS256 only
+	SecretFile     string `yaml:"secret_file"`                   // HS256 only
+	PrivateKeyPath string `yaml:"private_key_path"`              // PEM, RS256/EdDSA
+	PublicKeyPath  string `yaml:"public_key_path"`               // PEM, verify-only RS256/EdDSA keys
+}
+
+type JWT struct {
+	Issuer     string        `yaml:"issuer" env-default:"go-app"`
+	SigningKey string        `yaml:"signing_key" env:"JWT_SIGNING_KEY"` // kid of the key used for new tokens
+	Keys       []JWTKey      `yaml:"keys"`
+	AccessTTL  time.Duration `yaml:"access_ttl" env-default:"15m"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env-default:"720h"`
+}
+
+// Mail picks how outgoing email is delivered; the outbox driver writes .eml files for local testing
+type Mail struct {
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER" env-default:"outbox"` // outbox or smtp
+	From         string `yaml:"from" env-default:"TalentFlow <no-reply@talentflow.local>"`
+	OutboxDir    string `yaml:"outbox_dir" env-default:"storage/outbox"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+}
+
+// LoginThrottle limits password guessing; failures older than Window are forgotten
+type LoginThrottle struct {
+	MaxFailures     int           `yaml:"max_failures" env-default:"5"` // per email, then the email is locked
+	IPMaxFailures   int           `yaml:"ip_max_failures" env-default:"50"`
+	BaseDelay       time.Duration `yaml:"base_delay" env-default:"1s"` // doubles after every failure for the same email
+	MaxDelay        time.Duration `yaml:"max_delay" env-default:"1m"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	Window          time.Duration `yaml:"window" env-default:"1h"`
+	TrustProxy      bool          `yaml:"trust_proxy"` // take the client ip from X-Forwarded-For
+}
+
+// TwoFactor configures TOTP for admin accounts
+type TwoFactor struct {
+	Required     bool          `yaml:"required" env:"REQUIRE_2FA"`      // admins must enroll before they get a session
+	Issuer       string        `yaml:"issuer" env-default:"TalentFlow"` // name shown in authenticator apps
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`  // time to enter the code after the password
+}
+
+// OIDC enables single sign-on through the corporate identity provider
+type OIDC struct {
+	Enabled              bool          `yaml:"enabled" env:"OIDC_ENABLED"`
+	IssuerURL            string        `yaml:"issuer_url" env:"OIDC_ISSUER_URL"`
+	ClientID             string        `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret         string        `yaml:"client_secret" env:"OIDC_CLIENT_SECRET"`
+	RedirectURL          string        `yaml:"redirect_url" env-default:"http://localhost:8082/api/oidc/callback"` // this server's callback, registered at the provider
+	Scopes               []string      `yaml:"scopes" env-default:"openid,email,profile"`
+	Roles                []string      `yaml:"roles" env-default:"admin,mentor"` // local roles that may sign in through the provider
+	DisablePasswordLogin bool          `yaml:"disable_password_login"`           // refuse password logins for those roles
+	TrustProviderMFA     bool          `yaml:"trust_provider_mfa"`               // admins signing in through the provider skip the local two-factor challenge
+	StateTTL             time.Duration `yaml:"state_ttl" env-default:"10m"`      // time to finish signing in at the provider
+}
+
+// Password is the policy new passwords must meet and how they are hashed. Stored hashes made
+// with a weaker setting are upgraded on the next successful login.
+type Password struct {
+	MinLength     int    `yaml:"min_length" env-default:"12"`
+	MaxLength     int    `yaml:"max_length" env-default:"72"` // bcrypt ignores anything past 72 bytes
+	RequireUpper  bool   `yaml:"require_upper"`
+	RequireLower  bool   `yaml:"require_lower"`
+	RequireDigit  bool   `yaml:"require_digit"`
+	RequireSymbol bool   `yaml:"require_symbol"`
+	AllowCommon   bool   `yaml:"allow_common"`              // accept passwords from the bundled common password list
+	Hash          string `yaml:"hash" env-default:"bcrypt"` // bcrypt or argon2id
+	BcryptCost    int    `yaml:"bcrypt_cost" env-default:"10"`
+	Argon2Memory  uint32 `yaml:"argon2_memory_kib" env-default:"65536"`
+	Argon2Time    uint32 `yaml:"argon2_iterations" env-default:"3"`
+	Argon2Threads uint8  `yaml:"argon2_threads" env-default:"2"`
+}
+
+// Database picks the storage backend. Several replicas can share one postgres database, sqlite
+// uses storage_path when dsn is empty. The server applies pending migrations on startup, with
+// manual_migrate it refuses to start until `migrate up` brought the schema up to date.
+type Database struct {
+	Driver        string `yaml:"driver" env:"DB_DRIVER" env-default:"sqlite"` // sqlite or postgres
+	DSN           string `yaml:"dsn" env:"DB_DSN"`
+	ManualMigrate bool   `yaml:"manual_migrate" env:"DB_MANUAL_MIGRATE"`
+	// QueryTimeout bounds the statements a request runs through one storage handle, 0 for no limit
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" env-default:"5s"`
+}
+
+// Trash keeps deleted mentors, interns, projects and assignments restorable for Retention, they
+// are purged for good afterwards. A Retention of 0 keeps them until they are restored.
+type Trash struct {
+	Retention     time.Duration `yaml:"retention" env:"TRASH_RETENTION" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"` // how often the server looks for rows to purge
+}
+
+// Concurrency decides whether updates and deletes have to name the version they change
+type Concurrency struct {
+	RequireIfMatch bool `yaml:"require_if_match" env:"REQUIRE_IF_MATCH"` // answer 428 to updates and deletes without If-Match
+}
+
 type Config struct {
-	Environment string `yaml:"environment" env:"ENV" env-required:"true"`
-	StoragePath string `yaml:"storage_path" env:"STORAGE_PATH" env-required:"true"`
-	HttpServer  `yaml:"http_server"`
+	Environment   string `yaml:"environment" env:"ENV" env-required:"true"`
+	StoragePath   string `yaml:"storage_path" env:"STORAGE_PATH"` // sqlite database file, unless database.dsn is set
+	HttpServer    `yaml:"http_server"`
+	JWT           JWT           `yaml:"jwt"`
+	AppURL        string        `yaml:"app_url" env:"APP_URL" env-default:"http://localhost:5173"` // frontend base url used to build links sent to users
+	ActivationTTL time.Duration `yaml:"activation_ttl" env-default:"72h"`
+	InvitationTTL time.Duration `yaml:"invitation_ttl" env-default:"168h"`
+	ResetTTL      time.Duration `yaml:"password_reset_ttl" env-default:"1h"`
+	AllowSignup   bool          `yaml:"allow_signup" env:"ALLOW_SIGNUP"` // open admin signup, otherwise only the first admin may sign up
+	Mail          Mail          `yaml:"mail"`
+	LoginThrottle LoginThrottle `yaml:"login_throttle"`
+	TwoFactor     TwoFactor     `yaml:"two_factor"`
+	OIDC          OIDC          `yaml:"oidc"`
+	Password      Password      `yaml:"password"`
+	Database      Database      `yaml:"database"`
+	Trash         Trash         `yaml:"trash"`
+	Concurrency   Concurrency   `yaml:"concurrency"`
 }
 
 func MustLoad() *Config {
@@ -40,7 +155,8 @@ func MustLoad() *Config {
 		log.Fatalf("Failed to read config file: %v", er)
 	}
 
-	fmt.Println("Config loaded successfully:", cfg)
+	// the config holds secrets, so only say where it came from
+	log.Printf("Config loaded from %s", configPath)
 	return &cfg
 
 }
@@ -0,0 +1,76 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// ActivationLink points the user at the frontend page that calls POST /api/accounts/activate
+func ActivationLink(cfg *config.Config, token string) string {
+	return cfg.AppURL + "/activate?token=" + url.QueryEscape(token)
+}
+
+func CreateAccount(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.CreateAccount
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		token, err1 := repos.Accounts(r.Context()).CreateAccount(&details.Role, &details.EntityId, cfg.ActivationTTL)
+		if errors.Is(err1, storage.ErrAccountExists) {
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"activation_link": ActivationLink(cfg, token)})
+	}
+}
+
+func ActivateAccount(storage *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.ActivateAccount
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		err1 := storage.WithContext(r.Context()).ActivateAccount(&details.Token, &details.Password)
+		if response.WriteValidationError(w, err1) {
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Account activated, you can now log in"})
+	}
+}
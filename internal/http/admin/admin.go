@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+func ListAdmins(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admins, err := repos.Admins(r.Context()).GetAdmins()
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, admins)
+	}
+}
+
+func GetAdmin(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := adminId(w, r)
+		if !ok {
+			return
+		}
+		admin, err := repos.Admins(r.Context()).GetAdmin(&id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, admin)
+	}
+}
+
+// CreateAdmin adds an admin with a password directly, invitations are the alternative that
+// lets the new admin choose it
+func CreateAdmin(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.Signup
+		if !decode(w, r, &details) {
+			return
+		}
+		id, err := repos.Admins(r.Context()).CreateAdmin(&details.Username, &details.Email, &details.Password)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id)})
+	}
+}
+
+func UpdateAdmin(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := adminId(w, r)
+		if !ok {
+			return
+		}
+		var details types.UpdateAdmin
+		if !decode(w, r, &details) {
+			return
+		}
+		if err := repos.Admins(r.Context()).UpdateAdmin(&id, &details.Username, &details.Email); err != nil {
+			writeError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Admin updated successfully"})
+	}
+}
+
+// SetActive activates or deactivates an admin, a deactivated admin can't log in and their
+// tokens stop working
+func SetActive(repos storage.Repositories, active bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := adminId(w, r)
+		if !ok {
+			return
+		}
+		if err := repos.Admins(r.Context()).SetAdminActive(&id, active); err != nil {
+			writeError(w, err)
+			return
+		}
+		message := "Admin deactivated successfully"
+		if active {
+			message = "Admin activated successfully"
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": message})
+	}
+}
+
+func SetPassword(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := adminId(w, r)
+		if !ok {
+			return
+		}
+		var details types.SetPassword
+		if !decode(w, r, &details) {
+			return
+		}
+		if err := repos.Admins(r.Context()).SetAdminPassword(&id, &details.Password); err != nil {
+			writeError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Password changed, the admin was logged out everywhere"})
+	}
+}
+
+func DeleteAdmin(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := adminId(w, r)
+		if !ok {
+			return
+		}
+		if err := repos.Admins(r.Context()).DeleteAdmin(&id); err != nil {
+			writeError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Admin deleted successfully"})
+	}
+}
+
+func adminId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("adminId"), 10, 64)
+	if err != nil {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid adminId"})
+		return 0, false
+	}
+	return id, true
+}
+
+func decode(w http.ResponseWriter, r *http.Request, details any) bool {
+	err := json.NewDecoder(r.Body).Decode(details)
+	if errors.Is(err, io.EOF) {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+		return false
+	}
+	if err != nil {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if response.WriteValidationError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, storage.ErrAdminNotFound):
+		response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, storage.ErrAdminExists), errors.Is(err, storage.ErrLastActiveAdmin), errors.Is(err, storage.ErrAdminShared):
+		response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case response.WriteCanceled(w, err):
+	default:
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
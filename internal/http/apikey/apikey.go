@@ -0,0 +1,98 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/middleware/rbac"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// CreateAPIKey returns the key once; afterwards only its prefix is shown
+func CreateAPIKey(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		var details types.CreateAPIKey
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		for _, scope := range details.Scopes {
+			if !rbac.ValidScope(scope) {
+				response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid scope %q, use <resource>:read or <resource>:write", scope)})
+				return
+			}
+		}
+		var expiresAt *time.Time
+		if details.ExpiresAt != "" {
+			t, err1 := time.Parse(time.RFC3339, details.ExpiresAt)
+			if err1 != nil {
+				response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "expires_at must be an RFC3339 timestamp"})
+				return
+			}
+			if !t.After(time.Now()) {
+				response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+				return
+			}
+			expiresAt = &t
+		}
+
+		id, key, err2 := instance.Tenant(r.Context()).CreateAPIKey(&details.Name, details.Scopes, expiresAt, claims.ID)
+		if response.WriteCanceled(w, err2) {
+			return
+		}
+		if err2 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err2.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id), "key": key})
+	}
+}
+
+func ListAPIKeys(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := instance.Tenant(r.Context()).GetAPIKeys()
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, keys)
+	}
+}
+
+func RevokeAPIKey(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("apiKeyId"), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid apiKeyId"})
+			return
+		}
+		err1 := instance.Tenant(r.Context()).RevokeAPIKey(&id)
+		if errors.Is(err1, storage.ErrAPIKeyNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err1 != nil {
+			response.WriteError(w, err1)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "API key revoked successfully"})
+	}
+}
@@ -0,0 +1,29 @@
+// Package asof reads the ?as_of= parameter, which asks for interns and assignments as they were at
+// a time in the past instead of as they are now.
+package asof
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/response"
+)
+
+// Parse returns the time ?as_of= asks for, nil when the request wants the current state. A date
+// without a time, such as 2025-03-01, means the start of that day in UTC. It answers the request
+// itself and reports false when the value is neither.
+func Parse(w http.ResponseWriter, r *http.Request) (*time.Time, bool) {
+	value := r.URL.Query().Get("as_of")
+	if value == "" {
+		return nil, true
+	}
+	asOf, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		asOf, err = time.Parse(time.DateOnly, value)
+	}
+	if err != nil {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "as_of must be an RFC3339 timestamp or a date like 2025-03-01"})
+		return nil, false
+	}
+	return &asOf, true
+}
@@ -0,0 +1,43 @@
+package asof
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        *time.Time
+		ok          bool
+	}{
+		{"absent", "", nil, true},
+		{"timestamp", "2025-03-01T12:30:00+02:00", ptr(time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)), true},
+		{"date", "2025-03-01", ptr(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)), true},
+		{"not a time", "yesterday", nil, false},
+		{"date with a bad day", "2025-02-30", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/all-intern?as_of="+url.QueryEscape(tt.value), nil)
+			got, ok := Parse(w, r)
+			if ok != tt.ok {
+				t.Fatalf("Parse(%q) reported %v, want %v", tt.value, ok, tt.ok)
+			}
+			if !ok && w.Code != http.StatusBadRequest {
+				t.Fatalf("Parse(%q) answered %d, want 400", tt.value, w.Code)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && !got.Equal(*tt.want) {
+				t.Fatalf("Parse(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -8,12 +8,16 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/http/asof"
+	"github.com/Aytaditya/slotwise/internal/http/precondition"
+	"github.com/Aytaditya/slotwise/internal/middleware/rbac"
 	"github.com/Aytaditya/slotwise/internal/response"
 	"github.com/Aytaditya/slotwise/internal/storage"
 	"github.com/Aytaditya/slotwise/internal/types"
 )
 
-func AddAssignment(storage *storage.Sqlite) http.HandlerFunc {
+func AddAssignment(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var details types.Assignment
 		err := json.NewDecoder(r.Body).Decode(&details)
@@ -25,33 +29,87 @@ func AddAssignment(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": "Empty Json Body"})
 			return
 		}
-		id, err1 := storage.AddAssignment(&details.InternId, &details.ProjectId, &details.Remarks)
+		id, err1 := repos.Assignments(r.Context()).AddAssignment(&details.InternId, &details.ProjectId, &details.Remarks)
+		if response.WriteValidationError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id)})
 	}
 }
 
-func AllAssignments(storage *storage.Sqlite) http.HandlerFunc {
+// AllAssignments answers GET /api/all-assignment, ?as_of= lists the assignments as they were then
+func AllAssignments(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		assignments, err := storage.GetAssignmets()
+		asOf, ok := asof.Parse(w, r)
+		if !ok {
+			return
+		}
+		var assignments []types.ReturnAssignment
+		var err error
+		if asOf != nil {
+			assignments, err = repos.Assignments(r.Context()).GetAssignmentsAsOf(rbac.ScopeFromContext(r.Context()), *asOf)
+		} else {
+			assignments, err = repos.Assignments(r.Context()).GetAssignmets(rbac.ScopeFromContext(r.Context()))
+		}
 		if err != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, assignments)
 	}
 }
 
-func UpdateAssignment(storage *storage.Sqlite) http.HandlerFunc {
+// GetAssignment answers GET /api/assignments/{assignmentId}, ?as_of= returns the assignment as it
+// was then. Past states get no ETag, they can't be updated.
+func GetAssignment(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conId, convErr := strconv.ParseInt(r.PathValue("assignmentId"), 10, 64)
+		if convErr != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid assignmentId"})
+			return
+		}
+		asOf, ok := asof.Parse(w, r)
+		if !ok {
+			return
+		}
+		var assignment *types.ReturnAssignment
+		var err error
+		if asOf != nil {
+			assignment, err = repos.Assignments(r.Context()).GetAssignmentAsOf(&conId, *asOf)
+		} else {
+			assignment, err = repos.Assignments(r.Context()).GetAssignment(&conId)
+		}
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		if asOf == nil {
+			precondition.SetETag(w, assignment.Version)
+		}
+		response.WriteResponse(w, http.StatusOK, assignment)
+	}
+}
+
+func UpdateAssignment(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("assignmentId")
 		if id == "" {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "assignmentId is required"})
 			return
 		}
+		conId, convErr := strconv.ParseInt(id, 10, 64)
+		if convErr != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid assignmentId"})
+			return
+		}
 		var details types.UpdateAssignment
 		err := json.NewDecoder(r.Body).Decode(&details)
 		if err != nil {
@@ -62,16 +120,26 @@ func UpdateAssignment(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": "Empty Json Body"})
 			return
 		}
-		err1 := storage.UpdateAssignment(&details.InternId, &details.ProjectId, &details.Progress, &details.Remarks)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		err1 := repos.Assignments(r.Context()).UpdateAssignment(&conId, &details.InternId, &details.ProjectId, &details.Progress, &details.Remarks, version)
+		if precondition.WriteFailed(w, r, repos, "assignment", conId, err1) {
+			return
+		}
+		if response.WriteValidationError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Assignment updated successfully"})
 	}
 }
 
-func DeleteAssignment(storage *storage.Sqlite) http.HandlerFunc {
+func DeleteAssignment(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("assignmentId")
 		if id == "" {
@@ -83,9 +151,16 @@ func DeleteAssignment(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid assignmentId"})
 			return
 		}
-		err := storage.DeleteAssignment(&conId)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		err := repos.Assignments(r.Context()).DeleteAssignment(&conId, version)
+		if precondition.WriteFailed(w, r, repos, "assignment", conId, err) {
+			return
+		}
 		if err != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Assignment deleted successfully"})
@@ -0,0 +1,104 @@
+// Package audit lists the changes made to the mentors, interns, projects and assignments of an
+// organization: who made them, when, from which request and what they changed.
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+// ListAudit answers GET /api/audit, newest first. ?entity=, ?entity_id=, ?actor_id= and
+// ?actor_role= narrow it down, ?since= and ?until= take RFC3339 timestamps, ?limit= defaults to 100.
+func ListAudit(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := parseFilter(query)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		filter.Entity = query.Get("entity")
+		filter.ActorRole = query.Get("actor_role")
+		if filter.EntityId, err = parseId(query, "entity_id"); err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if filter.ActorId, err = parseId(query, "actor_id"); err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		list(w, r, repos, filter)
+	}
+}
+
+// History answers GET /api/{entities}/{id}/history with the audit entries of one record, param
+// is the path parameter with the id. It takes ?since=, ?until= and ?limit= like ListAudit.
+func History(repos storage.Repositories, entity string, param string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue(param), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid " + param})
+			return
+		}
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		filter.Entity, filter.EntityId = entity, id
+		list(w, r, repos, filter)
+	}
+}
+
+func list(w http.ResponseWriter, r *http.Request, repos storage.Repositories, filter *storage.AuditFilter) {
+	entries, err := repos.Audit(r.Context()).GetAudit(filter)
+	if errors.Is(err, storage.ErrNotAudited) {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+	response.WriteResponse(w, http.StatusOK, entries)
+}
+
+// parseFilter reads the parameters both routes take
+func parseFilter(query url.Values) (*storage.AuditFilter, error) {
+	filter := &storage.AuditFilter{}
+	for name, field := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.New(name + " must be an RFC3339 timestamp")
+			}
+			*field = t
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("invalid limit")
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+func parseId(query url.Values, name string) (int64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid " + name)
+	}
+	return id, nil
+}
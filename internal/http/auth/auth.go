@@ -5,19 +5,30 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/mail"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
 	"github.com/Aytaditya/slotwise/internal/response"
 	"github.com/Aytaditya/slotwise/internal/storage"
 	"github.com/Aytaditya/slotwise/internal/types"
 )
 
-func Signup(storage *storage.Sqlite) http.HandlerFunc {
+// Signup is closed unless allow_signup is set, except for creating the very first admin
+func Signup(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var details types.Signup
 		err := json.NewDecoder(r.Body).Decode(&details)
 		if errors.Is(err, io.EOF) {
-			fmt.Println("Empty body")
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
 			return
 		}
@@ -25,22 +36,226 @@ func Signup(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
 			return
 		}
-		fmt.Println(details)
-		id, token, err1 := storage.Signup(&details.Username, &details.Email, &details.Password)
+		id, err1 := instance.WithContext(r.Context()).Signup(&details.Username, &details.Email, &details.Password, cfg.AllowSignup)
+		if errors.Is(err1, storage.ErrSignupClosed) {
+			response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteValidationError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
-		response.WriteResponse(w, 200, map[string]string{"id": fmt.Sprint(id), "token": token})
+		CompleteLogin(w, r, instance, cfg, &types.Principal{ID: id, Email: details.Email, Role: types.RoleAdmin, OrgID: storage.DefaultOrgID})
 	}
 }
 
-func Login(instance *storage.Sqlite) http.HandlerFunc {
+func Login(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var details types.Login
 		err := json.NewDecoder(r.Body).Decode(&details)
 		if errors.Is(err, io.EOF) {
-			fmt.Println("Empty body")
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		// counters are per normalized email so case variations share one budget
+		email := strings.ToLower(strings.TrimSpace(details.Email))
+		ip := clientIP(r, cfg.LoginThrottle.TrustProxy)
+
+		wait, err0 := instance.WithContext(r.Context()).ReserveLoginAttempt(&email, &ip, &cfg.LoginThrottle)
+		if err0 != nil {
+			response.WriteError(w, err0)
+			return
+		}
+		if wait > 0 {
+			if err := instance.WithContext(r.Context()).RecordLoginFailure(&email, &ip, storage.ReasonLockedOut); err != nil {
+				log.Printf("recording failed login failed: %v", err)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.WriteResponse(w, http.StatusTooManyRequests, map[string]string{"error": "too many failed attempts, try again later"})
+			return
+		}
+
+		principal, _, err1 := instance.WithContext(r.Context()).Login(&details.Email, &details.Password)
+		if errors.Is(err1, storage.ErrInvalidCredentials) {
+			if err := instance.WithContext(r.Context()).RecordLoginFailure(&email, &ip, storage.ReasonInvalidCredentials); err != nil {
+				log.Printf("recording failed login failed: %v", err)
+			}
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err1 != nil {
+			response.WriteError(w, err1)
+			return
+		}
+		if err := instance.WithContext(r.Context()).RecordLoginSuccess(&email, &ip); err != nil {
+			log.Printf("clearing login throttle failed: %v", err)
+		}
+		if cfg.OIDC.Enabled && cfg.OIDC.DisablePasswordLogin && slices.Contains(cfg.OIDC.Roles, principal.Role) {
+			response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": "password login is disabled, sign in with single sign-on"})
+			return
+		}
+		CompleteLogin(w, r, instance, cfg, principal)
+	}
+}
+
+// clientIP uses the connection address unless the server sits behind a trusted proxy
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func LoginAttempts(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := types.LoginAttemptFilter{
+			Email: strings.ToLower(query.Get("email")),
+			IP:    query.Get("ip"),
+		}
+		if since := query.Get("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC3339 timestamp"})
+				return
+			}
+			filter.Since = t.Unix()
+		}
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+				return
+			}
+			filter.Limit = n
+		}
+
+		attempts, err := instance.Tenant(r.Context()).GetLoginAttempts(&filter)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, attempts)
+	}
+}
+
+func UnlockLogin(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.UnlockLogin
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		details.Email = strings.ToLower(strings.TrimSpace(details.Email))
+
+		n, err1 := instance.Tenant(r.Context()).UnlockLogin(&details.Email, &details.IP)
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		if n == 0 {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": "no lockout found"})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Login unlocked successfully"})
+	}
+}
+
+func Refresh(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.RefreshToken
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		principal, refresh, err1 := instance.WithContext(r.Context()).RotateRefreshToken(&details.RefreshToken)
+		if errors.Is(err1, storage.ErrInvalidRefreshToken) || errors.Is(err1, storage.ErrRefreshTokenReused) {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err1 != nil {
+			response.WriteError(w, err1)
+			return
+		}
+		token, err2 := jwt.CreateToken(principal)
+		if err2 != nil {
+			response.WriteError(w, err2)
+			return
+		}
+
+		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(principal.ID), "role": principal.Role, "token": token, "refresh_token": refresh})
+	}
+}
+
+// Logout revokes the refresh token family and denylists the access token used for the call
+func Logout(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		if claims.Role == types.RoleAPIKey {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "api keys are revoked through /api/api-keys"})
+			return
+		}
+		var details types.RefreshToken
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if err != nil && !errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		if details.RefreshToken != "" {
+			err1 := instance.WithContext(r.Context()).RevokeRefreshFamily(jwt.Principal(claims), &details.RefreshToken)
+			if err1 != nil && !errors.Is(err1, storage.ErrInvalidRefreshToken) {
+				response.WriteError(w, err1)
+				return
+			}
+		}
+		err2 := instance.WithContext(r.Context()).RevokeToken(&claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+		if err2 != nil {
+			response.WriteError(w, err2)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
+	}
+}
+
+// ForgotPassword always answers the same way so it can't be used to discover emails
+func ForgotPassword(instance *storage.Sqlite, cfg *config.Config, sender mail.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.ForgotPassword
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
 			return
 		}
@@ -49,12 +264,139 @@ func Login(instance *storage.Sqlite) http.HandlerFunc {
 			return
 		}
 
-		id, token, err1 := instance.Login(&details.Email, &details.Password)
+		principal, token, err1 := instance.WithContext(r.Context()).CreatePasswordReset(&details.Email, cfg.ResetTTL)
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			log.Printf("password reset for %q failed: %v", details.Email, err1)
+		}
+		if principal != nil {
+			link := cfg.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+			msg := &mail.Message{
+				To:      principal.Email,
+				Subject: "Reset your TalentFlow password",
+				Body: fmt.Sprintf("Someone asked to reset the password of your TalentFlow account.\n\nChoose a new password here:\n%s\n\nThe link expires in %s and can only be used once. If you did not ask for this, you can ignore this email.\n",
+					link, cfg.ResetTTL),
+			}
+			// sent in the background so the response time doesn't depend on the email existing
+			go func() {
+				if err := sender.Send(msg); err != nil {
+					log.Printf("sending password reset email failed: %v", err)
+				}
+			}()
+		}
+
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "If an account exists for this email, a reset link has been sent"})
+	}
+}
+
+func ResetPassword(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.ResetPassword
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
 			return
 		}
 
-		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id), "token": token})
+		err1 := instance.WithContext(r.Context()).ResetPassword(&details.Token, &details.Password)
+		if response.WriteValidationError(w, err1) {
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Password has been reset, please log in again"})
+	}
+}
+
+// ChangePassword needs the current password; wrong guesses count against the login throttle
+// so a stolen access token can't be used to find it out
+func ChangePassword(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		if claims.Role == types.RoleAPIKey {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "api keys have no password"})
+			return
+		}
+		var details types.ChangePassword
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		email := strings.ToLower(claims.Email)
+		ip := clientIP(r, cfg.LoginThrottle.TrustProxy)
+
+		wait, err0 := instance.WithContext(r.Context()).ReserveLoginAttempt(&email, &ip, &cfg.LoginThrottle)
+		if err0 != nil {
+			response.WriteError(w, err0)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.WriteResponse(w, http.StatusTooManyRequests, map[string]string{"error": "too many failed attempts, try again later"})
+			return
+		}
+
+		err1 := instance.WithContext(r.Context()).ChangePassword(jwt.Principal(claims), &details.CurrentPassword, &details.NewPassword)
+		if errors.Is(err1, storage.ErrWrongPassword) {
+			if err := instance.WithContext(r.Context()).RecordLoginFailure(&email, &ip, storage.ReasonInvalidCredentials); err != nil {
+				log.Printf("recording failed password change failed: %v", err)
+			}
+			response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteValidationError(w, err1) {
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err := instance.WithContext(r.Context()).RecordLoginSuccess(&email, &ip); err != nil {
+			log.Printf("clearing login throttle failed: %v", err)
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Password changed, other sessions were logged out"})
+	}
+}
+
+func Me() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{
+			"id":        fmt.Sprint(claims.ID),
+			"email":     claims.Email,
+			"role":      claims.Role,
+			"entity_id": fmt.Sprint(claims.EntityID),
+			"org_id":    fmt.Sprint(claims.OrgID),
+		})
+	}
+}
+
+func JWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.WriteResponse(w, http.StatusOK, jwt.PublicKeys())
 	}
 }
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+// openStorage connects to a fresh sqlite database configured like the tests of the storage package
+func openStorage(t *testing.T) (*storage.Sqlite, *config.Config) {
+	t.Helper()
+	cfg := &config.Config{StoragePath: filepath.Join(t.TempDir(), "auth.db")}
+	cfg.Password = config.Password{MinLength: 12, MaxLength: 72, Hash: "bcrypt", BcryptCost: 4}
+	cfg.LoginThrottle = config.LoginThrottle{MaxFailures: 5, IPMaxFailures: 100, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour, LockoutDuration: time.Hour}
+	instance, err := storage.ConnectDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { instance.DB.Close() })
+	return instance, cfg
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestLoginRetryAfter(t *testing.T) {
+	instance, cfg := openStorage(t)
+	login := Login(instance, cfg)
+	attempt := func(email string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"`+email+`","password":"not the password"}`))
+		w := httptest.NewRecorder()
+		login(w, r)
+		return w
+	}
+
+	if w := attempt("Ada@example.com"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", w.Code)
+	}
+	// the email is throttled however it is spelled
+	w := attempt(" ada@example.com")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("got %d with Retry-After %q, want 429 after the base delay", w.Code, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/oidc"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+// stateCookie binds a sign-in to the browser that started it, so a callback link can't be
+// used to log someone else into the attacker's account
+const stateCookie = "oidc_state"
+
+// OIDCLogin sends the browser to the identity provider
+func OIDCLogin(instance *storage.Sqlite, cfg *config.Config, provider *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := oidc.RandomString()
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		nonce, err := oidc.RandomString()
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		verifier, err := oidc.RandomString()
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		authURL, err1 := provider.AuthURL(state, nonce, verifier)
+		if err1 != nil {
+			log.Printf("starting sso sign-in failed: %v", err1)
+			response.WriteResponse(w, http.StatusBadGateway, map[string]string{"error": "identity provider is unavailable"})
+			return
+		}
+		if err2 := instance.WithContext(r.Context()).SaveOIDCState(state, nonce, verifier, cfg.OIDC.StateTTL); err2 != nil {
+			response.WriteError(w, err2)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     stateCookie,
+			Value:    state,
+			Path:     "/api/oidc",
+			MaxAge:   int(cfg.OIDC.StateTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode, // sent on the provider's top level redirect back
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCCallback finishes the sign-in and hands the tokens, or the two-factor challenge of an
+// admin, to the frontend in the url fragment, which browsers don't send to servers
+func OIDCCallback(instance *storage.Sqlite, cfg *config.Config, provider *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/api/oidc", MaxAge: -1, HttpOnly: true})
+
+		fail := func(msg string) {
+			http.Redirect(w, r, cfg.AppURL+"/login#"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+		}
+
+		query := r.URL.Query()
+		if idpError := query.Get("error"); idpError != "" {
+			fail("sign-in was cancelled or refused by the identity provider: " + idpError)
+			return
+		}
+		state := query.Get("state")
+		cookie, err := r.Cookie(stateCookie)
+		if err != nil || state == "" || cookie.Value != state {
+			fail(storage.ErrInvalidOIDCState.Error())
+			return
+		}
+		nonce, verifier, err1 := instance.WithContext(r.Context()).ConsumeOIDCState(state)
+		if err1 != nil {
+			fail(storage.ErrInvalidOIDCState.Error())
+			return
+		}
+
+		claims, err2 := provider.Exchange(query.Get("code"), verifier, nonce)
+		if err2 != nil {
+			log.Printf("sso sign-in failed: %v", err2)
+			fail("sign-in with the identity provider failed")
+			return
+		}
+
+		principal, err3 := instance.WithContext(r.Context()).OIDCPrincipal(claims.Issuer, claims.Subject, claims.Email, claims.EmailVerified, cfg.OIDC.Roles)
+		if errors.Is(err3, storage.ErrNoSSOAccount) {
+			fail(err3.Error())
+			return
+		}
+		if err3 != nil {
+			log.Printf("sso sign-in failed: %v", err3)
+			fail("sign-in failed")
+			return
+		}
+
+		// admins still answer the local two-factor challenge unless the provider's MFA is trusted
+		var tokens map[string]string
+		var err4 error
+		if cfg.OIDC.TrustProviderMFA {
+			tokens, err4 = sessionTokens(r, instance, cfg, principal)
+		} else {
+			tokens, err4 = loginTokens(r, instance, cfg, principal)
+		}
+		if err4 != nil {
+			log.Printf("sso sign-in failed: %v", err4)
+			fail("sign-in failed")
+			return
+		}
+		fragment := url.Values{}
+		for k, v := range tokens {
+			fragment.Set(k, v)
+		}
+		http.Redirect(w, r, cfg.AppURL+"/login#"+fragment.Encode(), http.StatusFound)
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/oidc"
+	"github.com/Aytaditya/slotwise/internal/oidc/oidctest"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/storage/storagetest"
+)
+
+func TestOIDCCallback(t *testing.T) {
+	err := jwt.LoadKeys(&config.JWT{SigningKey: "test", Keys: []config.JWTKey{{ID: "test", Algorithm: "HS256", Secret: "a secret only tests use"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance, cfg := openStorage(t)
+	if _, err := instance.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase")); err != nil {
+		t.Fatal(err)
+	}
+
+	idp, err := oidctest.New("", "talentflow", "dev-secret", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(idp.Handler())
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+	cfg.AppURL = "http://app.example.com"
+	cfg.OIDC = config.OIDC{
+		Enabled:          true,
+		IssuerURL:        server.URL,
+		ClientID:         "talentflow",
+		ClientSecret:     "dev-secret",
+		RedirectURL:      "http://app.example.com/api/oidc/callback",
+		Scopes:           []string{"openid", "email"},
+		Roles:            []string{"admin", "mentor"},
+		TrustProviderMFA: true,
+		StateTTL:         time.Minute,
+	}
+	provider, err := oidc.New(&cfg.OIDC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, callback := OIDCLogin(instance, cfg, provider), OIDCCallback(instance, cfg, provider)
+
+	// signIn starts a sign-in, lets the provider sign ada in and returns the callback request with
+	// the state cookie the browser got
+	signIn := func() (*http.Request, *http.Cookie) {
+		w := httptest.NewRecorder()
+		login(w, httptest.NewRequest("GET", "/api/oidc/login", nil))
+		cookies := w.Result().Cookies()
+		if w.Code != http.StatusFound || len(cookies) != 1 {
+			t.Fatalf("the sign-in didn't start: %d %v", w.Code, cookies)
+		}
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		res, err := client.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return httptest.NewRequest("GET", res.Header.Get("Location"), nil), cookies[0]
+	}
+	// finish runs the callback and returns the fragment the frontend gets
+	finish := func(r *http.Request) url.Values {
+		w := httptest.NewRecorder()
+		callback(w, r)
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || w.Code != http.StatusFound {
+			t.Fatalf("expected a redirect to the app, got %d %s", w.Code, w.Header().Get("Location"))
+		}
+		fragment, err := url.ParseQuery(location.Fragment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fragment
+	}
+
+	r, cookie := signIn()
+	r.AddCookie(cookie)
+	if fragment := finish(r); fragment.Get("token") == "" || fragment.Get("role") != "admin" {
+		t.Fatalf("expected ada to be signed in, got %v", fragment)
+	}
+	// the state was consumed
+	if fragment := finish(r); fragment.Get("error") != storage.ErrInvalidOIDCState.Error() {
+		t.Fatalf("a callback was accepted twice: %v", fragment)
+	}
+
+	// a callback link started in another browser
+	r, _ = signIn()
+	_, other := signIn()
+	r.AddCookie(other)
+	if fragment := finish(r); fragment.Get("error") != storage.ErrInvalidOIDCState.Error() || fragment.Get("token") != "" {
+		t.Fatalf("a callback with another browser's state was accepted: %v", fragment)
+	}
+	r, _ = signIn()
+	if fragment := finish(r); fragment.Get("error") != storage.ErrInvalidOIDCState.Error() {
+		t.Fatalf("a callback without the state cookie was accepted: %v", fragment)
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// ListSessions shows the caller's own active logins
+func ListSessions(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		sessions, err := instance.WithContext(r.Context()).GetSessions(claims.Role, claims.ID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].Id == claims.SessionID
+		}
+		response.WriteResponse(w, http.StatusOK, sessions)
+	}
+}
+
+// RevokeSession logs one of the caller's own sessions out, e.g. a lost laptop
+func RevokeSession(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		sessionId := r.PathValue("sessionId")
+		err := instance.WithContext(r.Context()).RevokeSession(claims.Role, claims.ID, &sessionId)
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Session revoked successfully"})
+	}
+}
+
+// RevokeAllSessions lets an admin log every device of any admin or account out
+func RevokeAllSessions(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.RevokeSessions
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		err1 := instance.Tenant(r.Context()).RevokeAllSessions(&details.Role, &details.UserId, &details.EntityId)
+		if errors.Is(err1, storage.ErrUserNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "All sessions revoked successfully"})
+	}
+}
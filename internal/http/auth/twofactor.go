@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/totp"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// sessionTokens starts a session for a fully authenticated principal and issues its access
+// and refresh token pair
+func sessionTokens(r *http.Request, instance *storage.Sqlite, cfg *config.Config, principal *types.Principal) (map[string]string, error) {
+	sessionId, refresh, err := instance.WithContext(r.Context()).StartSession(principal, r.UserAgent(), clientIP(r, cfg.LoginThrottle.TrustProxy))
+	if err != nil {
+		return nil, err
+	}
+	principal.SessionID = sessionId
+	token, err := jwt.CreateToken(principal)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"id": fmt.Sprint(principal.ID), "role": principal.Role, "token": token, "refresh_token": refresh}, nil
+}
+
+// CompleteLogin answers a successful password check with loginTokens
+func CompleteLogin(w http.ResponseWriter, r *http.Request, instance *storage.Sqlite, cfg *config.Config, principal *types.Principal) {
+	tokens, err := loginTokens(r, instance, cfg, principal)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+	response.WriteResponse(w, http.StatusOK, tokens)
+}
+
+// loginTokens starts a session for a principal whose first factor checked out. Admins with
+// two-factor enabled, or all admins when it is required, get a challenge token instead.
+func loginTokens(r *http.Request, instance *storage.Sqlite, cfg *config.Config, principal *types.Principal) (map[string]string, error) {
+	if principal.Role == types.RoleAdmin {
+		status, err := instance.WithContext(r.Context()).GetTwoFactorStatus(principal.ID)
+		if err != nil {
+			return nil, err
+		}
+		if status.Enabled || cfg.TwoFactor.Required {
+			challenge, err := jwt.CreateChallengeToken(principal, cfg.TwoFactor.ChallengeTTL)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{
+				"two_factor_required": "true",
+				"enrollment_required": fmt.Sprint(!status.Enabled),
+				"challenge_token":     challenge,
+			}, nil
+		}
+	}
+	return sessionTokens(r, instance, cfg, principal)
+}
+
+// challengePrincipal validates a challenge token that hasn't been exchanged yet
+func challengePrincipal(ctx context.Context, instance *storage.Sqlite, tokenString string) (*types.CustomClaims, error) {
+	claims, err := jwt.ValidateChallengeToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := instance.IsTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("challenge token has already been used")
+	}
+	return claims, nil
+}
+
+// LoginTwoFactorEnroll lets an admin who must use two-factor but hasn't set it up yet get a
+// secret with their challenge token
+func LoginTwoFactorEnroll(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.TwoFactorLogin
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		claims, err1 := challengePrincipal(r.Context(), instance, details.ChallengeToken)
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired challenge token"})
+			return
+		}
+		beginEnrollment(w, instance.WithContext(r.Context()), cfg, jwt.Principal(claims))
+	}
+}
+
+// LoginTwoFactor exchanges a challenge token and a TOTP or recovery code for a session. When
+// the admin is enrolling during login the code confirms the new secret and the response also
+// carries the recovery codes.
+func LoginTwoFactor(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var details types.TwoFactorLogin
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		claims, err1 := challengePrincipal(r.Context(), instance, details.ChallengeToken)
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired challenge token"})
+			return
+		}
+		principal := jwt.Principal(claims)
+
+		// codes are guessed against the same budget as passwords
+		email := strings.ToLower(principal.Email)
+		ip := clientIP(r, cfg.LoginThrottle.TrustProxy)
+		wait, err2 := instance.WithContext(r.Context()).ReserveLoginAttempt(&email, &ip, &cfg.LoginThrottle)
+		if err2 != nil {
+			response.WriteError(w, err2)
+			return
+		}
+		if wait > 0 {
+			if err := instance.WithContext(r.Context()).RecordLoginFailure(&email, &ip, storage.ReasonLockedOut); err != nil {
+				log.Printf("recording failed login failed: %v", err)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.WriteResponse(w, http.StatusTooManyRequests, map[string]string{"error": "too many failed attempts, try again later"})
+			return
+		}
+
+		status, err3 := instance.WithContext(r.Context()).GetTwoFactorStatus(principal.ID)
+		if err3 != nil {
+			response.WriteError(w, err3)
+			return
+		}
+		var recoveryCodes []string
+		if status.Enabled {
+			err3 = instance.WithContext(r.Context()).VerifyTwoFactor(principal.ID, &details.Code)
+		} else {
+			recoveryCodes, err3 = instance.WithContext(r.Context()).ConfirmTwoFactor(principal.ID, &details.Code)
+		}
+		if errors.Is(err3, storage.ErrInvalidTwoFactorCode) {
+			if err := instance.WithContext(r.Context()).RecordLoginFailure(&email, &ip, storage.ReasonInvalidTwoFactorCode); err != nil {
+				log.Printf("recording failed login failed: %v", err)
+			}
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": err3.Error()})
+			return
+		}
+		if errors.Is(err3, storage.ErrTwoFactorNotEnrolled) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "two-factor authentication has not been set up, call /api/login/2fa/enroll first"})
+			return
+		}
+		if err3 != nil {
+			response.WriteError(w, err3)
+			return
+		}
+		if err := instance.WithContext(r.Context()).RecordLoginSuccess(&email, &ip); err != nil {
+			log.Printf("clearing login throttle failed: %v", err)
+		}
+		// a challenge token is good for one session only
+		if err := instance.WithContext(r.Context()).RevokeToken(&claims.RegisteredClaims.ID, claims.ExpiresAt.Time); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		tokens, err4 := sessionTokens(r, instance, cfg, principal)
+		if err4 != nil {
+			response.WriteError(w, err4)
+			return
+		}
+		if recoveryCodes == nil {
+			response.WriteResponse(w, http.StatusOK, tokens)
+			return
+		}
+		body := map[string]any{"recovery_codes": recoveryCodes}
+		for k, v := range tokens {
+			body[k] = v
+		}
+		response.WriteResponse(w, http.StatusOK, body)
+	}
+}
+
+func beginEnrollment(w http.ResponseWriter, instance *storage.Sqlite, cfg *config.Config, principal *types.Principal) {
+	secret, err := instance.BeginTwoFactor(principal.ID)
+	if errors.Is(err, storage.ErrTwoFactorEnabled) {
+		response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+	response.WriteResponse(w, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(cfg.TwoFactor.Issuer, principal.Email, secret),
+	})
+}
+
+func TwoFactorStatus(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		status, err := instance.WithContext(r.Context()).GetTwoFactorStatus(claims.ID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		status.Required = cfg.TwoFactor.Required
+		response.WriteResponse(w, http.StatusOK, status)
+	}
+}
+
+// EnrollTwoFactor starts enrollment for the logged in admin; it only takes effect once a code
+// from the app is confirmed
+func EnrollTwoFactor(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		beginEnrollment(w, instance.WithContext(r.Context()), cfg, jwt.Principal(claims))
+	}
+}
+
+func ConfirmTwoFactor(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, details, ok := twoFactorRequest(w, r)
+		if !ok {
+			return
+		}
+		codes, err := instance.WithContext(r.Context()).ConfirmTwoFactor(claims.ID, &details.Code)
+		if errors.Is(err, storage.ErrInvalidTwoFactorCode) || errors.Is(err, storage.ErrTwoFactorNotEnrolled) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, storage.ErrTwoFactorEnabled) {
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+	}
+}
+
+func RegenerateRecoveryCodes(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, details, ok := twoFactorRequest(w, r)
+		if !ok {
+			return
+		}
+		if !verifyCode(w, instance.WithContext(r.Context()), claims.ID, &details.Code) {
+			return
+		}
+		codes, err := instance.WithContext(r.Context()).RegenerateRecoveryCodes(claims.ID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+	}
+}
+
+func DisableTwoFactor(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.TwoFactor.Required {
+			response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": "two-factor authentication is required for all admins"})
+			return
+		}
+		claims, details, ok := twoFactorRequest(w, r)
+		if !ok {
+			return
+		}
+		if !verifyCode(w, instance.WithContext(r.Context()), claims.ID, &details.Code) {
+			return
+		}
+		if err := instance.WithContext(r.Context()).DisableTwoFactor(claims.ID); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+	}
+}
+
+func twoFactorRequest(w http.ResponseWriter, r *http.Request) (*types.CustomClaims, *types.TwoFactorCode, bool) {
+	claims, ok := jwt.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+		return nil, nil, false
+	}
+	var details types.TwoFactorCode
+	err := json.NewDecoder(r.Body).Decode(&details)
+	if errors.Is(err, io.EOF) {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+		return nil, nil, false
+	}
+	if err != nil {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+		return nil, nil, false
+	}
+	return claims, &details, true
+}
+
+// verifyCode guards changes to an existing enrollment with a fresh code
+func verifyCode(w http.ResponseWriter, instance *storage.Sqlite, adminId int64, code *string) bool {
+	err := instance.VerifyTwoFactor(adminId, code)
+	if errors.Is(err, storage.ErrInvalidTwoFactorCode) {
+		response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return false
+	}
+	if errors.Is(err, storage.ErrTwoFactorNotEnrolled) {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		response.WriteError(w, err)
+		return false
+	}
+	return true
+}
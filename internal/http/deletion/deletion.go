@@ -0,0 +1,87 @@
+// Package deletion previews what deleting a mentor, intern or project does to the records that
+// point at it, and deletes one after moving those records to another mentor, intern or project.
+package deletion
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aytaditya/slotwise/internal/http/precondition"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+// Dependents answers GET /api/{entities}/{id}/dependents, param is the path parameter with the
+// id. With ?reassign_to= it previews moving the dependents to that record instead.
+func Dependents(repos storage.Repositories, entity string, param string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue(param), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid " + param})
+			return
+		}
+		reassignTo, err := reassignTarget(r)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		impact, err := repos.Deletions(r.Context()).DeleteImpact(entity, id, reassignTo)
+		if writeError(w, err) {
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, impact)
+	}
+}
+
+// Reassign carries out a delete route called with ?reassign_to=, moving everything that points
+// at the record to the target before deleting it. It reports false, without writing anything,
+// when the parameter is absent and the route should delete as usual. version is what If-Match
+// asked for, nil for any.
+func Reassign(w http.ResponseWriter, r *http.Request, repos storage.Repositories, entity string, id int64, version *int64) bool {
+	reassignTo, err := reassignTarget(r)
+	if err != nil {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return true
+	}
+	if reassignTo == nil {
+		return false
+	}
+	err = repos.Deletions(r.Context()).DeleteReassigning(entity, id, *reassignTo, version)
+	if precondition.WriteFailed(w, r, repos, entity, id, err) || writeError(w, err) {
+		return true
+	}
+	response.WriteResponse(w, http.StatusOK, map[string]string{
+		"message": strings.ToUpper(entity[:1]) + entity[1:] + " deleted successfully, its dependents were moved to " + entity + " " + strconv.FormatInt(*reassignTo, 10),
+	})
+	return true
+}
+
+func reassignTarget(r *http.Request) (*int64, error) {
+	value := r.URL.Query().Get("reassign_to")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid reassign_to")
+	}
+	return &id, nil
+}
+
+// writeError answers a failed preview or delete and reports whether there was an error
+func writeError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, storage.ErrRecordNotFound):
+		response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, storage.ErrReassignToSelf), errors.Is(err, storage.ErrReassignMissing), errors.Is(err, storage.ErrInvalidEntity):
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case response.WriteDependentsError(w, err):
+	default:
+		response.WriteError(w, err)
+	}
+	return true
+}
@@ -8,12 +8,18 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/http/account"
+	"github.com/Aytaditya/slotwise/internal/http/asof"
+	"github.com/Aytaditya/slotwise/internal/http/deletion"
+	"github.com/Aytaditya/slotwise/internal/http/precondition"
+	"github.com/Aytaditya/slotwise/internal/middleware/rbac"
 	"github.com/Aytaditya/slotwise/internal/response"
 	"github.com/Aytaditya/slotwise/internal/storage"
 	"github.com/Aytaditya/slotwise/internal/types"
 )
 
-func AddIntern(storage *storage.Sqlite) http.HandlerFunc {
+func AddIntern(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var details types.Intern
 		err := json.NewDecoder(r.Body).Decode(&details)
@@ -26,32 +32,91 @@ func AddIntern(storage *storage.Sqlite) http.HandlerFunc {
 			return
 		}
 
-		id, err1 := storage.AddIntern(&details.Name, &details.Email, &details.MentorId)
+		id, err1 := repos.Interns(r.Context()).AddIntern(&details.Name, &details.Email, &details.MentorId)
+		if response.WriteValidationError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 
-		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id)})
+		res := map[string]string{"id": fmt.Sprint(id)}
+		if details.CreateAccount {
+			role := types.RoleIntern
+			token, err2 := repos.Accounts(r.Context()).CreateAccount(&role, &id, cfg.ActivationTTL)
+			if err2 != nil {
+				response.WriteError(w, err2)
+				return
+			}
+			res["activation_link"] = account.ActivationLink(cfg, token)
+		}
+
+		response.WriteResponse(w, http.StatusOK, res)
 
 	}
 }
 
-func FetchInterns(storage *storage.Sqlite) http.HandlerFunc {
+// FetchInterns answers GET /api/all-intern, ?as_of= lists the interns as they were then
+func FetchInterns(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		interns, err := storage.GetInterns()
+		asOf, ok := asof.Parse(w, r)
+		if !ok {
+			return
+		}
+		var interns []types.ReturnIntern
+		var err error
+		if asOf != nil {
+			interns, err = repos.Interns(r.Context()).GetInternsAsOf(rbac.ScopeFromContext(r.Context()), *asOf)
+		} else {
+			interns, err = repos.Interns(r.Context()).GetInterns(rbac.ScopeFromContext(r.Context()))
+		}
 		if err != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, interns)
 	}
 }
 
-func UpdateIntern(storage *storage.Sqlite) http.HandlerFunc {
+// GetIntern answers GET /api/interns/{internId}, ?as_of= returns the intern as it was then. Past
+// states get no ETag, they can't be updated.
+func GetIntern(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		InternId, convErr := strconv.ParseInt(r.PathValue("internId"), 10, 64)
+		if convErr != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid intern ID"})
+			return
+		}
+		asOf, ok := asof.Parse(w, r)
+		if !ok {
+			return
+		}
+		var intern *types.ReturnIntern
+		var err error
+		if asOf != nil {
+			intern, err = repos.Interns(r.Context()).GetInternAsOf(&InternId, *asOf)
+		} else {
+			intern, err = repos.Interns(r.Context()).GetIntern(&InternId)
+		}
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		if asOf == nil {
+			precondition.SetETag(w, intern.Version)
+		}
+		response.WriteResponse(w, http.StatusOK, intern)
+	}
+}
+
+func UpdateIntern(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("internId")
-		fmt.Println(id)
 		var details types.UpdateIntern
 		err := json.NewDecoder(r.Body).Decode(&details)
 		if errors.Is(err, io.EOF) {
@@ -69,16 +134,26 @@ func UpdateIntern(storage *storage.Sqlite) http.HandlerFunc {
 			return
 		}
 
-		err1 := storage.UpdateIntern(&InternId, &details.Name, &details.Email, &details.MentorId, &details.Status)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		err1 := repos.Interns(r.Context()).UpdateIntern(&InternId, &details.Name, &details.Email, &details.MentorId, &details.Status, version)
+		if precondition.WriteFailed(w, r, repos, "intern", InternId, err1) {
+			return
+		}
+		if response.WriteValidationError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Intern updated successfully"})
 	}
 }
 
-func DeleteIntern(storage *storage.Sqlite) http.HandlerFunc {
+func DeleteIntern(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("internId")
 		InternId, convErr := strconv.ParseInt(id, 10, 64)
@@ -86,9 +161,19 @@ func DeleteIntern(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid note ID"})
 			return
 		}
-		err := storage.DeleteIntern(&InternId)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		if deletion.Reassign(w, r, repos, "intern", InternId, version) {
+			return
+		}
+		err := repos.Interns(r.Context()).DeleteIntern(&InternId, version)
+		if precondition.WriteFailed(w, r, repos, "intern", InternId, err) || response.WriteDependentsError(w, err) {
+			return
+		}
 		if err != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Intern deleted successfully"})
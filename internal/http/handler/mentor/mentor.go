@@ -8,12 +8,16 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/http/deletion"
+	"github.com/Aytaditya/slotwise/internal/http/precondition"
+	"github.com/Aytaditya/slotwise/internal/middleware/rbac"
 	"github.com/Aytaditya/slotwise/internal/response"
 	"github.com/Aytaditya/slotwise/internal/storage"
 	"github.com/Aytaditya/slotwise/internal/types"
 )
 
-func AddMentor(storage *storage.Sqlite) http.HandlerFunc {
+func AddMentor(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var details types.Mentor
 		err := json.NewDecoder(r.Body).Decode(&details)
@@ -25,9 +29,9 @@ func AddMentor(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": "Empty Json Body"})
 			return
 		}
-		id, err1 := storage.AddMentor(&details.Name, &details.Email, &details.Department)
+		id, err1 := repos.Mentors(r.Context()).AddMentor(&details.Name, &details.Email, &details.Department)
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 
@@ -36,18 +40,39 @@ func AddMentor(storage *storage.Sqlite) http.HandlerFunc {
 	}
 }
 
-func FetchMentors(storage *storage.Sqlite) http.HandlerFunc {
+func FetchMentors(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		mentors, err := storage.GetMentors()
+		mentors, err := repos.Mentors(r.Context()).GetMentors(rbac.ScopeFromContext(r.Context()))
 		if err != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, mentors)
 	}
 }
 
-func UpdateMentor(storage *storage.Sqlite) http.HandlerFunc {
+func GetMentor(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		strConId, err := strconv.ParseInt(r.PathValue("mentorId"), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid mentorId"})
+			return
+		}
+		mentor, err1 := repos.Mentors(r.Context()).GetMentor(&strConId)
+		if errors.Is(err1, storage.ErrRecordNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err1 != nil {
+			response.WriteError(w, err1)
+			return
+		}
+		precondition.SetETag(w, mentor.Version)
+		response.WriteResponse(w, http.StatusOK, mentor)
+	}
+}
+
+func UpdateMentor(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("mentorId")
 		if id == "" {
@@ -69,16 +94,23 @@ func UpdateMentor(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid mentorId"})
 			return
 		}
-		err2 := storage.UpdateMentor(&strConId, &details.Name, &details.Email, &details.Department)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		err2 := repos.Mentors(r.Context()).UpdateMentor(&strConId, &details.Name, &details.Email, &details.Department, version)
+		if precondition.WriteFailed(w, r, repos, "mentor", strConId, err2) {
+			return
+		}
 		if err2 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err2.Error()})
+			response.WriteError(w, err2)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Mentor updated successfully"})
 	}
 }
 
-func DeleteMentor(storage *storage.Sqlite) http.HandlerFunc {
+func DeleteMentor(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("mentorId")
 		if id == "" {
@@ -90,9 +122,19 @@ func DeleteMentor(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid mentorId"})
 			return
 		}
-		err1 := storage.DeleteMentor(&strConId)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		if deletion.Reassign(w, r, repos, "mentor", strConId, version) {
+			return
+		}
+		err1 := repos.Mentors(r.Context()).DeleteMentor(&strConId, version)
+		if precondition.WriteFailed(w, r, repos, "mentor", strConId, err1) || response.WriteDependentsError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Mentor deleted successfully"})
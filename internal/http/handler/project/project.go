@@ -8,12 +8,15 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/http/deletion"
+	"github.com/Aytaditya/slotwise/internal/http/precondition"
 	"github.com/Aytaditya/slotwise/internal/response"
 	"github.com/Aytaditya/slotwise/internal/storage"
 	"github.com/Aytaditya/slotwise/internal/types"
 )
 
-func AddProject(storage *storage.Sqlite) http.HandlerFunc {
+func AddProject(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var details types.Project
 		err := json.NewDecoder(r.Body).Decode(&details)
@@ -24,26 +27,47 @@ func AddProject(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": "Empty Json Body"})
 			return
 		}
-		id, err1 := storage.AddProject(&details.Name, &details.Description, &details.StartDate, &details.EndDate)
+		id, err1 := repos.Projects(r.Context()).AddProject(&details.Name, &details.Description, &details.StartDate, &details.EndDate)
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id)})
 	}
 }
 
-func AllProjects(storage *storage.Sqlite) http.HandlerFunc {
+func AllProjects(repos storage.Repositories) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		projects, err := storage.GetProjects()
+		projects, err := repos.Projects(r.Context()).GetProjects()
 		if err != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, projects)
 	}
 }
 
-func UpdateProject(storage *storage.Sqlite) http.HandlerFunc {
+func GetProject(repos storage.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conId, err := strconv.ParseInt(r.PathValue("projectId"), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid projectId"})
+			return
+		}
+		project, err1 := repos.Projects(r.Context()).GetProject(&conId)
+		if errors.Is(err1, storage.ErrRecordNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err1 != nil {
+			response.WriteError(w, err1)
+			return
+		}
+		precondition.SetETag(w, project.Version)
+		response.WriteResponse(w, http.StatusOK, project)
+	}
+}
+
+func UpdateProject(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("projectId")
 		if id == "" {
@@ -66,10 +90,16 @@ func UpdateProject(storage *storage.Sqlite) http.HandlerFunc {
 			return
 		}
 
-		err2 := storage.UpdateProject(&conId, &details.Name, &details.Description, &details.Status, &details.StartDate, &details.EndDate)
-
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		err2 := repos.Projects(r.Context()).UpdateProject(&conId, &details.Name, &details.Description, &details.Status, &details.StartDate, &details.EndDate, version)
+		if precondition.WriteFailed(w, r, repos, "project", conId, err2) {
+			return
+		}
 		if err2 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err2.Error()})
+			response.WriteError(w, err2)
 			return
 		}
 
@@ -78,7 +108,7 @@ func UpdateProject(storage *storage.Sqlite) http.HandlerFunc {
 	}
 }
 
-func DeleteProject(storage *storage.Sqlite) http.HandlerFunc {
+func DeleteProject(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("projectId")
 		if id == "" {
@@ -90,9 +120,19 @@ func DeleteProject(storage *storage.Sqlite) http.HandlerFunc {
 			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid projectId"})
 			return
 		}
-		err1 := storage.DeleteProject(&conId)
+		version, ok := precondition.IfMatch(w, r, cfg)
+		if !ok {
+			return
+		}
+		if deletion.Reassign(w, r, repos, "project", conId, version) {
+			return
+		}
+		err1 := repos.Projects(r.Context()).DeleteProject(&conId, version)
+		if precondition.WriteFailed(w, r, repos, "project", conId, err1) || response.WriteDependentsError(w, err1) {
+			return
+		}
 		if err1 != nil {
-			response.WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err1.Error()})
+			response.WriteError(w, err1)
 			return
 		}
 		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Project deleted successfully"})
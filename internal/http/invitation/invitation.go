@@ -0,0 +1,137 @@
+package invitation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strconv"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/http/auth"
+	"github.com/Aytaditya/slotwise/internal/mail"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+func CreateInvitation(instance *storage.Sqlite, cfg *config.Config, sender mail.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		var details types.CreateInvitation
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+		if addr, err1 := netmail.ParseAddress(details.Email); err1 != nil || addr.Address != details.Email {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid email address"})
+			return
+		}
+
+		id, token, err2 := instance.Tenant(r.Context()).CreateInvitation(&details.Email, &details.Role, &details.EntityId, claims.ID, cfg.InvitationTTL)
+		if response.WriteCanceled(w, err2) {
+			return
+		}
+		if err2 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err2.Error()})
+			return
+		}
+
+		link := cfg.AppURL + "/invitations/" + url.PathEscape(token)
+		err3 := sender.Send(&mail.Message{
+			To:      details.Email,
+			Subject: "You have been invited to TalentFlow",
+			Body: fmt.Sprintf("%s has invited you to join TalentFlow as %s.\n\nAccept the invitation here:\n%s\n\nThe link expires in %s.\n",
+				claims.Email, details.Role, link, cfg.InvitationTTL),
+		})
+		if err3 != nil {
+			response.WriteResponse(w, http.StatusBadGateway, map[string]string{"id": fmt.Sprint(id), "error": "invitation created but the email could not be sent: " + err3.Error()})
+			return
+		}
+
+		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id)})
+	}
+}
+
+func ListInvitations(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invitations, err := instance.Tenant(r.Context()).GetInvitations()
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, invitations)
+	}
+}
+
+func RevokeInvitation(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("invitationId"), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid invitationId"})
+			return
+		}
+		err1 := instance.Tenant(r.Context()).RevokeInvitation(&id)
+		if errors.Is(err1, storage.ErrInvitationNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if err1 != nil {
+			response.WriteError(w, err1)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Invitation revoked successfully"})
+	}
+}
+
+// AcceptInvitation completes registration and logs the new user in
+func AcceptInvitation(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+		var details types.AcceptInvitation
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		principal, err1 := instance.WithContext(r.Context()).AcceptInvitation(&token, &details.Username, &details.Password)
+		if errors.Is(err1, storage.ErrInvalidInvitation) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if errors.Is(err1, storage.ErrAccountExists) {
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteValidationError(w, err1) {
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+
+		auth.CompleteLogin(w, r, instance, cfg, principal)
+	}
+}
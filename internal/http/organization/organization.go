@@ -0,0 +1,237 @@
+package organization
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// ListOrganizations shows the organizations the admin belongs to and which one they work in
+func ListOrganizations(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		orgs, err := instance.Tenant(r.Context()).GetOrganizations(claims.ID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, orgs)
+	}
+}
+
+func CreateOrganization(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		var details types.CreateOrganization
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		id, err1 := instance.WithContext(r.Context()).CreateOrganization(&details.Name, claims.ID)
+		if errors.Is(err1, storage.ErrOrgExists) {
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id)})
+	}
+}
+
+// SwitchOrganization returns an access token for another organization of the admin. The
+// refresh token keeps working and stays in the new organization.
+func SwitchOrganization(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		var details types.SwitchOrganization
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		principal := jwt.Principal(claims)
+		err1 := instance.WithContext(r.Context()).SwitchOrganization(principal, &details.OrgId)
+		if errors.Is(err1, storage.ErrNotOrgMember) {
+			response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		token, err2 := jwt.CreateToken(principal)
+		if err2 != nil {
+			response.WriteError(w, err2)
+			return
+		}
+		// the old token would keep reaching the previous organization until it expires
+		if err3 := instance.WithContext(r.Context()).RevokeToken(&claims.RegisteredClaims.ID, claims.ExpiresAt.Time); err3 != nil {
+			response.WriteError(w, err3)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"org_id": fmt.Sprint(principal.OrgID), "token": token})
+	}
+}
+
+// InviteMember invites another admin into the caller's current organization, they join once
+// they accept
+func InviteMember(instance *storage.Sqlite, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		var details types.OrgMember
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if errors.Is(err, io.EOF) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Empty request body"})
+			return
+		}
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+			return
+		}
+
+		id, err1 := instance.Tenant(r.Context()).InviteOrgMember(&details.AdminId, claims.ID, cfg.InvitationTTL)
+		if errors.Is(err1, storage.ErrAdminNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if errors.Is(err1, storage.ErrAlreadyOrgMember) {
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"id": fmt.Sprint(id), "message": "Invitation sent, the admin joins once they accept it"})
+	}
+}
+
+// ListOrgInvitations shows the caller's pending invitations into other organizations
+func ListOrgInvitations(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		invitations, err := instance.WithContext(r.Context()).GetOrgInvitations(claims.ID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, invitations)
+	}
+}
+
+// AnswerOrgInvitation accepts or declines one of the caller's invitations, accepting makes them a
+// member without switching to the organization
+func AnswerOrgInvitation(instance *storage.Sqlite, accept bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("invitationId"), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid invitationId"})
+			return
+		}
+
+		message := "Invitation declined"
+		var err1 error
+		if accept {
+			var orgId int64
+			orgId, err1 = instance.WithContext(r.Context()).AcceptOrgInvitation(claims.ID, &id)
+			message = fmt.Sprintf("You joined organization %d, switch to it with /api/orgs/switch", orgId)
+		} else {
+			err1 = instance.WithContext(r.Context()).DeclineOrgInvitation(claims.ID, &id)
+		}
+		if errors.Is(err1, storage.ErrOrgInvitationNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": message})
+	}
+}
+
+func RemoveMember(instance *storage.Sqlite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("adminId"), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid adminId"})
+			return
+		}
+		err1 := instance.Tenant(r.Context()).RemoveOrgMember(&id)
+		if errors.Is(err1, storage.ErrMemberNotFound) {
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err1.Error()})
+			return
+		}
+		if errors.Is(err1, storage.ErrLastActiveAdmin) {
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err1.Error()})
+			return
+		}
+		if response.WriteCanceled(w, err1) {
+			return
+		}
+		if err1 != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err1.Error()})
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]string{"message": "Admin removed from the organization"})
+	}
+}
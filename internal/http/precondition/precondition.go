@@ -0,0 +1,104 @@
+// Package precondition carries the version of mentors, interns, projects and assignments in ETag
+// and If-Match headers, so an update or delete made from a stale read is refused instead of
+// overwriting a change the client never saw.
+package precondition
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+// SetETag sends the version of the record that is being answered with
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// IfMatch returns the version the request expects the record to be at, nil when it sent no
+// If-Match or If-Match: * and any version will do. It answers the request itself and reports
+// false when the header is malformed, or missing while the config requires it.
+func IfMatch(w http.ResponseWriter, r *http.Request, cfg *config.Config) (*int64, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		if cfg.Concurrency.RequireIfMatch {
+			response.WriteResponse(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match is required, send the ETag of the record"})
+			return nil, false
+		}
+		return nil, true
+	}
+	if value == "*" {
+		return nil, true
+	}
+	// versions are compared as they are, so a weak ETag is as good as a strong one
+	value = strings.TrimPrefix(value, "W/")
+	version, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid If-Match, send the ETag of the record"})
+		return nil, false
+	}
+	return &version, true
+}
+
+// WriteFailed answers an update or delete that was refused because the record changed since the
+// client read it, with 412 and the record as it is now, or 404 when it's gone. It reports whether
+// err was one of those.
+func WriteFailed(w http.ResponseWriter, r *http.Request, repos storage.Repositories, entity string, id int64, err error) bool {
+	switch {
+	case errors.Is(err, storage.ErrVersionMismatch):
+	case errors.Is(err, storage.ErrRecordNotFound):
+		response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return true
+	default:
+		return false
+	}
+
+	current, version, loadErr := load(r, repos, entity, id)
+	if errors.Is(loadErr, storage.ErrRecordNotFound) {
+		// changed and then deleted before it could be read again
+		response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": loadErr.Error()})
+		return true
+	}
+	if loadErr != nil {
+		response.WriteError(w, loadErr)
+		return true
+	}
+	SetETag(w, version)
+	response.WriteResponse(w, http.StatusPreconditionFailed, map[string]any{"error": err.Error(), "current": current})
+	return true
+}
+
+// load reads the record as it is now along with its version
+func load(r *http.Request, repos storage.Repositories, entity string, id int64) (any, int64, error) {
+	switch entity {
+	case "mentor":
+		mentor, err := repos.Mentors(r.Context()).GetMentor(&id)
+		if err != nil {
+			return nil, 0, err
+		}
+		return mentor, mentor.Version, nil
+	case "intern":
+		intern, err := repos.Interns(r.Context()).GetIntern(&id)
+		if err != nil {
+			return nil, 0, err
+		}
+		return intern, intern.Version, nil
+	case "project":
+		project, err := repos.Projects(r.Context()).GetProject(&id)
+		if err != nil {
+			return nil, 0, err
+		}
+		return project, project.Version, nil
+	case "assignment":
+		assignment, err := repos.Assignments(r.Context()).GetAssignment(&id)
+		if err != nil {
+			return nil, 0, err
+		}
+		return assignment, assignment.Version, nil
+	}
+	return nil, 0, storage.ErrInvalidEntity
+}
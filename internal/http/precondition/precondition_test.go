@@ -0,0 +1,108 @@
+package precondition
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/storage/memory"
+	"github.com/Aytaditya/slotwise/internal/storage/storagetest"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		require bool
+		want    int64 // -1 when any version will do
+		status  int   // 0 when the request goes on
+	}{
+		{"missing", "", false, -1, 0},
+		{"missing while required", "", true, -1, http.StatusPreconditionRequired},
+		{"any version", "*", true, -1, 0},
+		{"strong", `"3"`, false, 3, 0},
+		{"weak", `W/"3"`, false, 3, 0},
+		{"padded", ` "3" `, false, 3, 0},
+		{"unquoted", "3", false, -1, http.StatusBadRequest},
+		{"half quoted", `"3`, false, -1, http.StatusBadRequest},
+		{"not a version", `"x"`, false, -1, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/api/mentors/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+			version, ok := IfMatch(w, r, &config.Config{Concurrency: config.Concurrency{RequireIfMatch: tt.require}})
+			if ok != (tt.status == 0) {
+				t.Fatalf("got ok=%v with %d", ok, w.Code)
+			}
+			if tt.status != 0 && w.Code != tt.status {
+				t.Fatalf("got %d, want %d", w.Code, tt.status)
+			}
+			if tt.want == -1 && version != nil || tt.want != -1 && (version == nil || *version != tt.want) {
+				t.Fatalf("got version %v, want %d", version, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFailed(t *testing.T) {
+	store, err := memory.New(&config.Password{MinLength: 12, MaxLength: 72, Hash: "bcrypt", BcryptCost: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := storagetest.Org(1)
+	mentors := store.Mentors(ctx)
+	name, email, department := "Ada", "ada@example.com", "Engineering"
+	id, err := mentors.AddMentor(&name, &email, &department)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := int64(1)
+	if err := mentors.UpdateMentor(&id, &name, &email, &department, &stale); err != nil {
+		t.Fatal(err)
+	}
+	mismatch := mentors.UpdateMentor(&id, &name, &email, &department, &stale)
+	if !errors.Is(mismatch, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", mismatch)
+	}
+
+	write := func(id int64, err error) (*httptest.ResponseRecorder, bool) {
+		r := httptest.NewRequest("PUT", "/api/mentors/1", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		return w, WriteFailed(w, r, store, "mentor", id, err)
+	}
+
+	w, handled := write(id, mismatch)
+	if !handled || w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("got %d (handled=%v), want 412", w.Code, handled)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Fatalf("got ETag %s, want the current version", etag)
+	}
+	var body struct {
+		Current struct {
+			Version int64 `json:"version"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Current.Version != 2 {
+		t.Fatalf("the current record wasn't sent: %v %+v", err, body)
+	}
+
+	// changed and then deleted before it could be read again
+	if w, handled := write(id+1, mismatch); !handled || w.Code != http.StatusNotFound {
+		t.Fatalf("got %d (handled=%v), want 404", w.Code, handled)
+	}
+	if w, handled := write(id, storage.ErrRecordNotFound); !handled || w.Code != http.StatusNotFound {
+		t.Fatalf("got %d (handled=%v), want 404", w.Code, handled)
+	}
+	if _, handled := write(id, errors.New("boom")); handled {
+		t.Fatal("an unrelated error was answered")
+	}
+}
@@ -0,0 +1,93 @@
+// Package trash lists the deleted mentors, interns, projects and assignments of an organization
+// and restores them until they are purged.
+package trash
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+type trashedRecord struct {
+	storage.TrashedRecord
+	PurgeAt *time.Time `json:"purge_at,omitempty"` // absent when the trash is kept until restored
+}
+
+// ListTrash answers GET /api/trash, ?entity=intern lists only interns
+func ListTrash(repos storage.Repositories, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		records, err := repos.Trash(r.Context()).GetTrash(r.URL.Query().Get("entity"))
+		if errors.Is(err, storage.ErrNotTrashable) {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		list := make([]trashedRecord, 0, len(records))
+		for _, record := range records {
+			item := trashedRecord{TrashedRecord: record}
+			if cfg.Trash.Retention > 0 {
+				purgeAt := record.DeletedAt.Add(cfg.Trash.Retention)
+				item.PurgeAt = &purgeAt
+			}
+			list = append(list, item)
+		}
+		response.WriteResponse(w, http.StatusOK, list)
+	}
+}
+
+// Restore answers POST /api/{entities}/{id}/restore, param is the path parameter with the id
+func Restore(repos storage.Repositories, entity string, param string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue(param), 10, 64)
+		if err != nil {
+			response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid " + param})
+			return
+		}
+		restored, err := repos.Trash(r.Context()).Restore(entity, id)
+		switch {
+		case errors.Is(err, storage.ErrRecordNotFound):
+			response.WriteResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		case response.WriteDependentsError(w, err):
+			return
+		case errors.Is(err, storage.ErrEmailTaken):
+			response.WriteResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteResponse(w, http.StatusOK, map[string]any{
+			"message":  strings.ToUpper(entity[:1]) + entity[1:] + " restored successfully",
+			"restored": restored,
+		})
+	}
+}
+
+// Purge deletes what has been in the trash longer than the retention every purge interval,
+// until the process exits
+func Purge(store storage.TrashPurger, cfg *config.Trash) {
+	if cfg.Retention <= 0 || cfg.PurgeInterval <= 0 {
+		return
+	}
+	for {
+		purged, err := store.PurgeTrash(context.Background(), time.Now().Add(-cfg.Retention))
+		if err != nil {
+			log.Printf("purging the trash failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d records from the trash", purged)
+		}
+		time.Sleep(cfg.PurgeInterval)
+	}
+}
@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a message, implementations are picked by mail.driver in the config
+type Sender interface {
+	Send(msg *Message) error
+}
+
+func New(cfg *config.Mail) (Sender, error) {
+	switch cfg.Driver {
+	case "outbox", "":
+		if err := os.MkdirAll(cfg.OutboxDir, 0o755); err != nil {
+			return nil, err
+		}
+		return &Outbox{Dir: cfg.OutboxDir, From: cfg.From}, nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("smtp_host is required for the smtp mail driver")
+		}
+		return &SMTP{
+			Addr:     fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+			Host:     cfg.SMTPHost,
+			From:     cfg.From,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+}
+
+// header drops line breaks so user supplied values can't inject extra headers
+func header(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+func format(from string, msg *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// Outbox writes every message to its own .eml file instead of sending it
+type Outbox struct {
+	Dir  string
+	From string
+}
+
+func (o *Outbox) Send(msg *Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(o.Dir, name), format(o.From, msg), 0o600)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '@' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+type SMTP struct {
+	Addr     string
+	Host     string
+	From     string
+	Username string
+	Password string
+}
+
+func (s *SMTP) Send(msg *Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	return smtp.SendMail(s.Addr, auth, envelope(s.From), []string{msg.To}, format(s.From, msg))
+}
+
+// envelope extracts the bare address from "Name <addr>"
+func envelope(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
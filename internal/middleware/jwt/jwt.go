@@ -1,29 +1,217 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Aytaditya/slotwise/internal/response"
 	"github.com/Aytaditya/slotwise/internal/types"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("AdityaIsGoodBoy")
+type contextKey string
 
-func CreateToken(userId int64, email string) (string, error) {
+const claimsKey contextKey = "claims"
+
+// challengeAudience marks tokens that only prove the password step of a two-factor login
+const challengeAudience = "2fa-challenge"
+
+func init() {
+	// iat is compared with the time the user's tokens were cut off, seconds would refuse a token
+	// issued in the same second as a password reset
+	jwt.TimePrecision = time.Millisecond
+}
+
+// TokenStore is the part of the storage layer the middleware needs to reject revoked tokens
+// and look up api keys
+type TokenStore interface {
+	IsTokenRevoked(ctx context.Context, claims *types.CustomClaims) (bool, error)
+	// TouchSession records that the session is still in use
+	TouchSession(ctx context.Context, sessionId string) error
+	// APIKeyClaims returns nil claims for unknown, revoked or expired keys
+	APIKeyClaims(ctx context.Context, key string) (*types.CustomClaims, error)
+}
+
+// RefreshTTL is how long a refresh token stays valid
+func RefreshTTL() time.Duration {
+	return refreshTTL
+}
+
+// NewTokenID returns a random identifier used for jti claims and opaque tokens
+func NewTokenID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Principal rebuilds the principal a token was issued to
+func Principal(claims *types.CustomClaims) *types.Principal {
+	return &types.Principal{ID: claims.ID, Email: claims.Email, Role: claims.Role, EntityID: claims.EntityID, OrgID: claims.OrgID, SessionID: claims.SessionID}
+}
+
+func CreateToken(principal *types.Principal) (string, error) {
+	if activeKey == nil {
+		return "", fmt.Errorf("jwt keys not loaded")
+	}
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
 	claims := types.CustomClaims{
-		ID:    userId,
-		Email: email,
+		ID:        principal.ID,
+		Email:     principal.Email,
+		Role:      principal.Role,
+		EntityID:  principal.EntityID,
+		SessionID: principal.SessionID,
+		OrgID:     principal.OrgID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 1 day expiry
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTTL)), // short lived, renewed with a refresh token
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "go-app",
+			Issuer:    issuer,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(activeKey.method, claims)
+	token.Header["kid"] = activeKey.id
+
+	return token.SignedString(activeKey.signKey)
+}
 
-	return token.SignedString(jwtSecret)
+// ValidateToken parses the token and checks its signature, expiry and issuer
+func ValidateToken(tokenString string) (*types.CustomClaims, error) {
+	var claims types.CustomClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, keyFunc,
+		jwt.WithValidMethods(validAlgos),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	// challenge tokens carry an audience, access tokens never do
+	if len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("not an access token")
+	}
+	return &claims, nil
 }
 
-func ValidateToken(token string) {
+// CreateChallengeToken issues a short lived token that can only be exchanged for a session
+// together with a valid two-factor code
+func CreateChallengeToken(principal *types.Principal, ttl time.Duration) (string, error) {
+	if activeKey == nil {
+		return "", fmt.Errorf("jwt keys not loaded")
+	}
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	claims := types.CustomClaims{
+		ID:       principal.ID,
+		Email:    principal.Email,
+		Role:     principal.Role,
+		EntityID: principal.EntityID,
+		OrgID:    principal.OrgID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+		},
+	}
+	token := jwt.NewWithClaims(activeKey.method, claims)
+	token.Header["kid"] = activeKey.id
+
+	return token.SignedString(activeKey.signKey)
+}
+
+func ValidateChallengeToken(tokenString string) (*types.CustomClaims, error) {
+	var claims types.CustomClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, keyFunc,
+		jwt.WithValidMethods(validAlgos),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(challengeAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return &claims, nil
+}
+
+// Authenticate rejects requests without a valid, unrevoked bearer token or api key and stores
+// the claims in the request context
+func Authenticate(store TokenStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if key, found := strings.CutPrefix(header, "ApiKey "); found {
+			claims, err := store.APIKeyClaims(r.Context(), strings.TrimSpace(key))
+			if err != nil {
+				response.WriteError(w, err)
+				return
+			}
+			if claims == nil {
+				response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "invalid, revoked or expired api key"})
+				return
+			}
+			ctx := ContextWithClaims(r.Context(), claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "missing bearer token or api key"})
+			return
+		}
+
+		claims, err := ValidateToken(tokenString)
+		if err != nil {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired token"})
+			return
+		}
+
+		revoked, err := store.IsTokenRevoked(r.Context(), claims)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		if revoked {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "token has been revoked"})
+			return
+		}
+		if claims.SessionID != "" {
+			if err := store.TouchSession(r.Context(), claims.SessionID); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+
+		ctx := ContextWithClaims(r.Context(), claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ContextWithClaims returns a context carrying the claims, as Authenticate leaves them for handlers
+func ContextWithClaims(ctx context.Context, claims *types.CustomClaims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
 
+// ClaimsFromContext returns the claims of the authenticated principal or api key, if any
+func ClaimsFromContext(ctx context.Context) (*types.CustomClaims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*types.CustomClaims)
+	return claims, ok
 }
@@ -0,0 +1,211 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/types"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for verify-only keys
+	verifyKey interface{}
+}
+
+var (
+	keys       = map[string]*signingKey{}
+	activeKey  *signingKey
+	issuer     = "go-app"
+	accessTTL  = 15 * time.Minute
+	refreshTTL = 30 * 24 * time.Hour
+	validAlgos = []string{"HS256", "RS256", "EdDSA"}
+)
+
+// LoadKeys reads every configured key; the one named by signing_key signs new tokens,
+// the rest are only used to verify tokens issued before a rotation
+func LoadKeys(cfg *config.JWT) error {
+	if len(cfg.Keys) == 0 {
+		return fmt.Errorf("no jwt keys configured")
+	}
+
+	loaded := map[string]*signingKey{}
+	for _, k := range cfg.Keys {
+		if k.ID == "" {
+			return fmt.Errorf("jwt key without id")
+		}
+		if _, dup := loaded[k.ID]; dup {
+			return fmt.Errorf("duplicate jwt key id %q", k.ID)
+		}
+		key, err := loadKey(&k)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %v", k.ID, err)
+		}
+		loaded[k.ID] = key
+	}
+
+	active, ok := loaded[cfg.SigningKey]
+	if !ok {
+		return fmt.Errorf("signing key %q is not among the configured keys", cfg.SigningKey)
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("signing key %q has no private key", cfg.SigningKey)
+	}
+
+	keys = loaded
+	activeKey = active
+	if cfg.Issuer != "" {
+		issuer = cfg.Issuer
+	}
+	if cfg.AccessTTL > 0 {
+		accessTTL = cfg.AccessTTL
+	}
+	if cfg.RefreshTTL > 0 {
+		refreshTTL = cfg.RefreshTTL
+	}
+	return nil
+}
+
+func loadKey(k *config.JWTKey) (*signingKey, error) {
+	switch k.Algorithm {
+	case "HS256", "":
+		secret := []byte(k.Secret)
+		if k.SecretFile != "" {
+			data, err := os.ReadFile(k.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = []byte(strings.TrimSpace(string(data)))
+		}
+		if len(secret) < 16 {
+			return nil, fmt.Errorf("HS256 secret must be at least 16 bytes")
+		}
+		return &signingKey{id: k.ID, method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+
+	case "RS256", "EdDSA":
+		key := &signingKey{id: k.ID, method: jwt.GetSigningMethod(k.Algorithm)}
+		if k.PrivateKeyPath != "" {
+			priv, err := readPrivateKey(k.PrivateKeyPath)
+			if err != nil {
+				return nil, err
+			}
+			switch p := priv.(type) {
+			case *rsa.PrivateKey:
+				key.signKey, key.verifyKey = p, &p.PublicKey
+			case ed25519.PrivateKey:
+				key.signKey, key.verifyKey = p, p.Public()
+			}
+		} else if k.PublicKeyPath != "" {
+			pub, err := readPublicKey(k.PublicKeyPath)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = pub
+		} else {
+			return nil, fmt.Errorf("private_key_path or public_key_path is required")
+		}
+
+		switch key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			if k.Algorithm != "RS256" {
+				return nil, fmt.Errorf("RSA key used with %s", k.Algorithm)
+			}
+		case ed25519.PublicKey:
+			if k.Algorithm != "EdDSA" {
+				return nil, fmt.Errorf("Ed25519 key used with %s", k.Algorithm)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported key type")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q, expected one of %v", k.Algorithm, validAlgos)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (interface{}, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func readPublicKey(path string) (interface{}, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// keyFunc picks the verification key from the kid header and checks it matches the algorithm
+func keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+// PublicKeys returns the asymmetric verification keys; HMAC secrets are never published
+func PublicKeys() types.JWKSet {
+	set := types.JWKSet{Keys: []types.JWK{}}
+	for _, key := range keys {
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, types.JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, types.JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
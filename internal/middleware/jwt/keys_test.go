@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/types"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writePEM stores a key the way keys are configured, as a PEM file
+func writePEM(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testKeys writes an RSA key pair and an Ed25519 public key and returns their configuration
+// along with an HS256 one, and the PEM of the RSA public key
+func testKeys(t *testing.T) ([]config.JWTKey, []byte) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKIXPublicKey(edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []config.JWTKey{
+		{ID: "old", Algorithm: "HS256", Secret: "a secret only tests use"},
+		{ID: "new", Algorithm: "RS256", PrivateKeyPath: writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))},
+		{ID: "partner", Algorithm: "EdDSA", PublicKeyPath: writePEM(t, "ed25519.pub", "PUBLIC KEY", edDER)},
+	}, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPublic})
+}
+
+// sign issues an access token like CreateToken does, with any method, kid and key
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, types.CustomClaims{ID: 1, Role: types.RoleAdmin, RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    issuer,
+	}})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyRotation(t *testing.T) {
+	configured, _ := testKeys(t)
+	if err := LoadKeys(&config.JWT{SigningKey: "old", Keys: configured}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := CreateToken(&types.Principal{ID: 1, Role: types.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new key signs from now on, the old one still verifies what it signed
+	if err := LoadKeys(&config.JWT{SigningKey: "new", Keys: configured}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := CreateToken(&types.Principal{ID: 1, Role: types.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(after, &types.CustomClaims{})
+	if err != nil || parsed.Header["kid"] != "new" || parsed.Method.Alg() != "RS256" {
+		t.Fatalf("a new token wasn't signed with the active key: %v %v", parsed.Header, err)
+	}
+	for _, token := range []string{before, after} {
+		if _, err := ValidateToken(token); err != nil {
+			t.Fatalf("a token of a configured key was refused: %v", err)
+		}
+	}
+
+	// once the old key is dropped its tokens are refused
+	if err := LoadKeys(&config.JWT{SigningKey: "new", Keys: configured[1:]}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(before); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+		t.Fatalf("a token of a removed key was accepted: %v", err)
+	}
+}
+
+func TestValidateTokenRefused(t *testing.T) {
+	configured, rsaPublic := testKeys(t)
+	if err := LoadKeys(&config.JWT{SigningKey: "new", Keys: configured}); err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte(configured[0].Secret)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", sign(t, jwt.SigningMethodHS256, "unknown", secret)},
+		{"no kid", sign(t, jwt.SigningMethodHS256, "", secret)},
+		{"another algorithm than the key's", sign(t, jwt.SigningMethodHS384, "old", secret)},
+		// the RSA public key is no secret, a token "signed" with it must not pass as HS256
+		{"HS256 with the public key of an RSA key", sign(t, jwt.SigningMethodHS256, "new", rsaPublic)},
+		{"unsigned", sign(t, jwt.SigningMethodNone, "old", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateToken(tt.token); err == nil {
+				t.Fatal("the token was accepted")
+			}
+		})
+	}
+}
+
+func TestLoadKeysRefused(t *testing.T) {
+	configured, _ := testKeys(t)
+	tests := []struct {
+		name string
+		cfg  config.JWT
+	}{
+		{"no keys", config.JWT{SigningKey: "old"}},
+		{"signing key not configured", config.JWT{SigningKey: "missing", Keys: configured}},
+		{"signing key without a private key", config.JWT{SigningKey: "partner", Keys: configured}},
+		{"short secret", config.JWT{SigningKey: "old", Keys: []config.JWTKey{{ID: "old", Algorithm: "HS256", Secret: "short"}}}},
+		{"duplicate kid", config.JWT{SigningKey: "old", Keys: []config.JWTKey{configured[0], configured[0]}}},
+		{"RSA key used as EdDSA", config.JWT{SigningKey: "new", Keys: []config.JWTKey{{ID: "new", Algorithm: "EdDSA", PrivateKeyPath: configured[1].PrivateKeyPath}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := LoadKeys(&tt.cfg); err == nil {
+				t.Fatal("the configuration was accepted")
+			}
+		})
+	}
+}
+
+func TestPublicKeys(t *testing.T) {
+	configured, _ := testKeys(t)
+	if err := LoadKeys(&config.JWT{SigningKey: "new", Keys: configured}); err != nil {
+		t.Fatal(err)
+	}
+	set := PublicKeys()
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "new" || set.Keys[0].Kty != "RSA" || set.Keys[1].Kid != "partner" || set.Keys[1].Crv != "Ed25519" {
+		t.Fatalf("expected the RSA and Ed25519 keys only, got %+v", set.Keys)
+	}
+}
@@ -0,0 +1,282 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/response"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+type Resource string
+
+const (
+	Mentors       Resource = "mentors"
+	Interns       Resource = "interns"
+	Projects      Resource = "projects"
+	Assignments   Resource = "assignments"
+	Accounts      Resource = "accounts"
+	Invitations   Resource = "invitations"
+	Security      Resource = "security"   // login attempts and lockouts
+	TwoFactor     Resource = "two_factor" // the caller's own TOTP enrollment
+	APIKeys       Resource = "api_keys"
+	Admins        Resource = "admins"
+	Organizations Resource = "organizations"
+	Trash         Resource = "trash" // deleted records of every kind, restoring one needs delete on its own resource
+	Audit         Resource = "audit" // the audit log and the history of each record
+)
+
+type Action string
+
+const (
+	Read   Action = "read"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Grant says how much of a resource a role may touch
+type Grant int
+
+const (
+	None Grant = iota
+	Own        // only rows linked to the principal's mentor or intern record
+	All
+)
+
+var allActions = map[Action]Grant{Read: All, Create: All, Update: All, Delete: All}
+
+// matrix is the single source of truth for who may do what
+var matrix = map[string]map[Resource]map[Action]Grant{
+	types.RoleAdmin: {
+		Mentors:       allActions,
+		Interns:       allActions,
+		Projects:      allActions,
+		Assignments:   allActions,
+		Accounts:      allActions,
+		Invitations:   allActions,
+		Security:      allActions,
+		TwoFactor:     allActions,
+		APIKeys:       allActions,
+		Admins:        allActions,
+		Organizations: allActions,
+		Trash:         allActions,
+		Audit:         {Read: All},
+	},
+	types.RoleMentor: {
+		Mentors:     {Read: Own},
+		Interns:     {Read: Own, Update: Own},
+		Assignments: {Read: Own, Update: Own},
+	},
+	types.RoleIntern: {
+		Interns:     {Read: Own},
+		Assignments: {Read: Own},
+	},
+}
+
+// scopable lists the resources api keys can be granted; api keys aren't in the matrix,
+// their scopes grant read or write (create, update, delete) on a whole resource
+var scopable = map[Resource]bool{
+	Mentors:     true,
+	Interns:     true,
+	Projects:    true,
+	Assignments: true,
+	Accounts:    true,
+}
+
+// ScopeName is the api key scope needed for an action, e.g. interns:read or interns:write
+func ScopeName(resource Resource, action Action) string {
+	if action == Read {
+		return string(resource) + ":read"
+	}
+	return string(resource) + ":write"
+}
+
+// ValidScope reports whether an api key may be given the scope
+func ValidScope(scope string) bool {
+	resource, access, found := strings.Cut(scope, ":")
+	return found && scopable[Resource(resource)] && (access == "read" || access == "write")
+}
+
+// pathParams names the route parameter carrying the row id for each resource
+var pathParams = map[Resource]string{
+	Mentors:     "mentorId",
+	Interns:     "internId",
+	Projects:    "projectId",
+	Assignments: "assignmentId",
+}
+
+// OwnerStore resolves who owns a row so Own grants can be checked
+type OwnerStore interface {
+	InternMentor(ctx context.Context, internId int64) (int64, error)
+	AssignmentOwner(ctx context.Context, assignmentId int64) (int64, int64, error)
+}
+
+type contextKey string
+
+const scopeKey contextKey = "scope"
+
+func GrantFor(role string, resource Resource, action Action) Grant {
+	return matrix[role][resource][action]
+}
+
+func grantForClaims(claims *types.CustomClaims, resource Resource, action Action) Grant {
+	if claims.Role != types.RoleAPIKey {
+		return GrantFor(claims.Role, resource, action)
+	}
+	if scopable[resource] && slices.Contains(claims.Scopes, ScopeName(resource, action)) {
+		return All
+	}
+	return None
+}
+
+// Require allows the request only if the authenticated principal holds the permission.
+// Routes with a row id are checked against ownership, list routes get a Scope in the context.
+func Require(store OwnerStore, resource Resource, action Action, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.ClaimsFromContext(r.Context())
+		if !ok {
+			response.WriteResponse(w, http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+			return
+		}
+
+		grant := grantForClaims(claims, resource, action)
+		if grant == None {
+			forbidden(w)
+			return
+		}
+
+		if grant == Own {
+			if id := r.PathValue(pathParams[resource]); id != "" {
+				rowId, err := strconv.ParseInt(id, 10, 64)
+				if err != nil {
+					response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid " + pathParams[resource]})
+					return
+				}
+				owned, err := owns(r.Context(), store, claims, resource, rowId)
+				if err != nil {
+					response.WriteError(w, err)
+					return
+				}
+				if !owned {
+					forbidden(w)
+					return
+				}
+			}
+
+			if action == Create || action == Update {
+				owned, err := bodyStaysOwned(r, store, claims, resource)
+				if err != nil {
+					response.WriteResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid Json Format"})
+					return
+				}
+				if !owned {
+					forbidden(w)
+					return
+				}
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), scopeKey, scopeFor(claims, grant))
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// ScopeFromContext returns the rows the caller may list, set by Require
+func ScopeFromContext(ctx context.Context) *types.Scope {
+	scope, ok := ctx.Value(scopeKey).(*types.Scope)
+	if !ok {
+		// deny by default if a route was registered without Require
+		return &types.Scope{MentorId: -1, InternId: -1}
+	}
+	return scope
+}
+
+func scopeFor(claims *types.CustomClaims, grant Grant) *types.Scope {
+	if grant == All {
+		return &types.Scope{}
+	}
+	switch claims.Role {
+	case types.RoleMentor:
+		return &types.Scope{MentorId: claims.EntityID}
+	case types.RoleIntern:
+		return &types.Scope{InternId: claims.EntityID}
+	}
+	return &types.Scope{MentorId: -1, InternId: -1}
+}
+
+func owns(ctx context.Context, store OwnerStore, claims *types.CustomClaims, resource Resource, id int64) (bool, error) {
+	if claims.EntityID == 0 {
+		return false, nil
+	}
+	switch resource {
+	case Mentors:
+		return claims.Role == types.RoleMentor && id == claims.EntityID, nil
+
+	case Interns:
+		if claims.Role == types.RoleIntern {
+			return id == claims.EntityID, nil
+		}
+		mentorId, err := store.InternMentor(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return claims.Role == types.RoleMentor && mentorId == claims.EntityID, err
+
+	case Assignments:
+		internId, mentorId, err := store.AssignmentOwner(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if claims.Role == types.RoleIntern {
+			return internId == claims.EntityID, err
+		}
+		return claims.Role == types.RoleMentor && mentorId == claims.EntityID, err
+	}
+	return false, nil
+}
+
+// bodyStaysOwned stops an Own grant from moving a row out of the principal's reach,
+// e.g. a mentor reassigning their intern to another mentor
+func bodyStaysOwned(r *http.Request, store OwnerStore, claims *types.CustomClaims, resource Resource) (bool, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var fields struct {
+		MentorId *int64 `json:"mentor_id"`
+		InternId *int64 `json:"intern_id"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return false, err
+		}
+	}
+
+	switch resource {
+	case Interns:
+		if fields.MentorId != nil && claims.Role == types.RoleMentor {
+			return *fields.MentorId == claims.EntityID, nil
+		}
+	case Assignments:
+		if fields.InternId != nil {
+			return owns(r.Context(), store, claims, Interns, *fields.InternId)
+		}
+	}
+	return true, nil
+}
+
+func forbidden(w http.ResponseWriter) {
+	response.WriteResponse(w, http.StatusForbidden, map[string]string{"error": "you do not have permission to perform this action"})
+}
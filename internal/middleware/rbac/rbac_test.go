@@ -0,0 +1,143 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// owners is an OwnerStore with interns 1 and 2 under mentors 10 and 20, and assignment 100 of
+// intern 1
+type owners struct{}
+
+func (owners) InternMentor(ctx context.Context, internId int64) (int64, error) {
+	switch internId {
+	case 1:
+		return 10, nil
+	case 2:
+		return 20, nil
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (owners) AssignmentOwner(ctx context.Context, assignmentId int64) (int64, int64, error) {
+	if assignmentId == 100 {
+		return 1, 10, nil
+	}
+	return 0, 0, sql.ErrNoRows
+}
+
+// serve routes the request through Require like cmd/main.go does and returns the status and the
+// scope the handler saw
+func serve(t *testing.T, claims *types.CustomClaims, pattern string, resource Resource, action Action, method string, target string, body string) (int, *types.Scope) {
+	t.Helper()
+	var scope *types.Scope
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, Require(owners{}, resource, action, func(w http.ResponseWriter, r *http.Request) {
+		scope = ScopeFromContext(r.Context())
+	}))
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if claims != nil {
+		r = r.WithContext(jwt.ContextWithClaims(r.Context(), claims))
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w.Code, scope
+}
+
+func TestRequire(t *testing.T) {
+	admin := &types.CustomClaims{ID: 1, Role: types.RoleAdmin, OrgID: 1}
+	mentor := &types.CustomClaims{ID: 2, Role: types.RoleMentor, EntityID: 10, OrgID: 1}
+	intern := &types.CustomClaims{ID: 3, Role: types.RoleIntern, EntityID: 1, OrgID: 1}
+	unlinked := &types.CustomClaims{ID: 4, Role: types.RoleMentor, OrgID: 1}
+	apiKey := &types.CustomClaims{ID: 5, Role: types.RoleAPIKey, OrgID: 1, Scopes: []string{"interns:read"}}
+
+	tests := []struct {
+		name           string
+		claims         *types.CustomClaims
+		pattern        string
+		resource       Resource
+		action         Action
+		method, target string
+		body           string
+		want           int
+	}{
+		{"no token", nil, "GET /api/all-intern", Interns, Read, "GET", "/api/all-intern", "", http.StatusUnauthorized},
+		{"admin", admin, "DELETE /api/admins/{adminId}", Admins, Delete, "DELETE", "/api/admins/9", "", http.StatusOK},
+		{"admin audit is read only", admin, "DELETE /api/audit", Audit, Delete, "DELETE", "/api/audit", "", http.StatusForbidden},
+		{"mentor without a grant", mentor, "GET /api/projects", Projects, Read, "GET", "/api/projects", "", http.StatusForbidden},
+		{"mentor reads their intern", mentor, "GET /api/interns/{internId}", Interns, Read, "GET", "/api/interns/1", "", http.StatusOK},
+		{"mentor reads another mentor's intern", mentor, "GET /api/interns/{internId}", Interns, Read, "GET", "/api/interns/2", "", http.StatusForbidden},
+		{"mentor reads a missing intern", mentor, "GET /api/interns/{internId}", Interns, Read, "GET", "/api/interns/3", "", http.StatusForbidden},
+		{"mentor with a bad id", mentor, "GET /api/interns/{internId}", Interns, Read, "GET", "/api/interns/x", "", http.StatusBadRequest},
+		{"mentor keeps their intern", mentor, "PUT /api/interns/{internId}", Interns, Update, "PUT", "/api/interns/1", `{"mentor_id":10}`, http.StatusOK},
+		{"mentor hands their intern away", mentor, "PUT /api/interns/{internId}", Interns, Update, "PUT", "/api/interns/1", `{"mentor_id":20}`, http.StatusForbidden},
+		{"mentor with a broken body", mentor, "PUT /api/interns/{internId}", Interns, Update, "PUT", "/api/interns/1", `{`, http.StatusBadRequest},
+		{"mentor can't delete", mentor, "DELETE /api/interns/{internId}", Interns, Delete, "DELETE", "/api/interns/1", "", http.StatusForbidden},
+		{"mentor moves an assignment to another mentor's intern", mentor, "PUT /api/assignments/{assignmentId}", Assignments, Update, "PUT", "/api/assignments/100", `{"intern_id":2}`, http.StatusForbidden},
+		{"intern reads their assignment", intern, "GET /api/assignments/{assignmentId}", Assignments, Read, "GET", "/api/assignments/100", "", http.StatusOK},
+		{"intern reads another intern", intern, "GET /api/interns/{internId}", Interns, Read, "GET", "/api/interns/2", "", http.StatusForbidden},
+		{"intern can't update", intern, "PUT /api/assignments/{assignmentId}", Assignments, Update, "PUT", "/api/assignments/100", "", http.StatusForbidden},
+		{"account without a linked row", unlinked, "GET /api/interns/{internId}", Interns, Read, "GET", "/api/interns/1", "", http.StatusForbidden},
+		{"api key with the scope", apiKey, "GET /api/all-intern", Interns, Read, "GET", "/api/all-intern", "", http.StatusOK},
+		{"api key without the scope", apiKey, "POST /api/interns", Interns, Create, "POST", "/api/interns", "", http.StatusForbidden},
+		{"api key outside the scopable resources", apiKey, "GET /api/admins", Admins, Read, "GET", "/api/admins", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := serve(t, tt.claims, tt.pattern, tt.resource, tt.action, tt.method, tt.target, tt.body); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims *types.CustomClaims
+		want   types.Scope
+	}{
+		{"admin", &types.CustomClaims{Role: types.RoleAdmin}, types.Scope{}},
+		{"mentor", &types.CustomClaims{Role: types.RoleMentor, EntityID: 10}, types.Scope{MentorId: 10}},
+		{"intern", &types.CustomClaims{Role: types.RoleIntern, EntityID: 1}, types.Scope{InternId: 1}},
+		{"api key", &types.CustomClaims{Role: types.RoleAPIKey, Scopes: []string{"interns:read"}}, types.Scope{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, scope := serve(t, tt.claims, "GET /api/all-intern", Interns, Read, "GET", "/api/all-intern", "")
+			if status != http.StatusOK || scope == nil || *scope != tt.want {
+				t.Fatalf("got %d with %+v, want %+v", status, scope, tt.want)
+			}
+		})
+	}
+
+	// a route registered without Require lists nothing
+	if scope := ScopeFromContext(context.Background()); scope.MentorId != -1 || scope.InternId != -1 {
+		t.Fatalf("expected the deny scope, got %+v", scope)
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for scope, want := range map[string]bool{
+		"interns:read":   true,
+		"accounts:write": true,
+		"interns:delete": false,
+		"admins:read":    false,
+		"interns":        false,
+		"":               false,
+	} {
+		if got := ValidScope(scope); got != want {
+			t.Errorf("ValidScope(%q) = %v, want %v", scope, got, want)
+		}
+	}
+	if got := ScopeName(Interns, Update); got != "interns:write" {
+		t.Errorf("ScopeName(interns, update) = %s", got)
+	}
+}
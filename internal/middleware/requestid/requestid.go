@@ -0,0 +1,50 @@
+// Package requestid gives every request an id that ends up in its audit entries, so a change can
+// be matched with the request and the logs that made it.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// Header carries the id, clients may send their own and every response echoes it
+const Header = "X-Request-ID"
+
+type contextKey string
+
+const idKey contextKey = "request_id"
+
+// ids from clients are kept when they look like one, anything else is replaced
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// Middleware stores the request id in the request context and the response header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !validID.MatchString(id) {
+			id = newID()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// NewContext returns a context carrying the request id, as Middleware leaves it for handlers
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey, id)
+}
+
+// FromContext returns the id of the request, empty outside of one
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+	return id
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,136 @@
+package oidc_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/oidc"
+	"github.com/Aytaditya/slotwise/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// startProvider serves the mock identity provider and returns it with a relying party for it
+func startProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	idp, err := oidctest.New("", "talentflow", "dev-secret", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(idp.Handler())
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+
+	provider, err := oidc.New(&config.OIDC{
+		IssuerURL:    server.URL,
+		ClientID:     "talentflow",
+		ClientSecret: "dev-secret",
+		RedirectURL:  "http://app.example.com/api/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idp, provider
+}
+
+// authorize runs the browser's part of the flow and returns the code the provider redirected back
+// with, along with the nonce and verifier it was started with
+func authorize(t *testing.T, provider *oidc.Provider) (string, string, string) {
+	t.Helper()
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthURL("state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	back, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || back.Query().Get("state") != "state" || back.Query().Get("code") == "" {
+		t.Fatalf("the provider didn't redirect back with a code: %s", res.Header.Get("Location"))
+	}
+	return back.Query().Get("code"), nonce, verifier
+}
+
+func TestExchange(t *testing.T) {
+	idp, provider := startProvider(t)
+	code, nonce, verifier := authorize(t, provider)
+	claims, err := provider.Exchange(code, verifier, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "ada@example.com" || !claims.EmailVerified || claims.Subject != "mock|ada@example.com" || claims.Issuer != idp.Issuer {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if _, err := provider.Exchange(code, verifier, nonce); err == nil {
+		t.Fatal("a code was exchanged twice")
+	}
+
+	// a second audience is fine when the token was issued to us
+	idp.Tamper = func(claims jwt.MapClaims, header map[string]any) {
+		claims["aud"] = []string{"talentflow", "another-client"}
+		claims["azp"] = "talentflow"
+	}
+	code, nonce, verifier = authorize(t, provider)
+	if _, err := provider.Exchange(code, verifier, nonce); err != nil {
+		t.Fatalf("a token authorized for us was refused: %v", err)
+	}
+}
+
+func TestExchangeRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(claims jwt.MapClaims, header map[string]any)
+		nonce  string // replaces the nonce the flow was started with
+		want   string
+	}{
+		{name: "nonce mismatch", nonce: "another nonce", want: "nonce mismatch"},
+		{name: "wrong audience", tamper: func(claims jwt.MapClaims, header map[string]any) { claims["aud"] = "another-client" }, want: "audience"},
+		{name: "issued to another client", tamper: func(claims jwt.MapClaims, header map[string]any) {
+			claims["aud"] = []string{"talentflow", "another-client"}
+			claims["azp"] = "another-client"
+		}, want: "azp mismatch"},
+		{name: "no azp with several audiences", tamper: func(claims jwt.MapClaims, header map[string]any) {
+			claims["aud"] = []string{"talentflow", "another-client"}
+		}, want: "azp mismatch"},
+		{name: "unknown kid", tamper: func(claims jwt.MapClaims, header map[string]any) { header["kid"] = "rotated-away" }, want: "unknown signing key"},
+		{name: "wrong issuer", tamper: func(claims jwt.MapClaims, header map[string]any) { claims["iss"] = "https://evil.example.com" }, want: "issuer"},
+		{name: "expired", tamper: func(claims jwt.MapClaims, header map[string]any) { claims["exp"] = 1 }, want: "expired"},
+		{name: "no subject", tamper: func(claims jwt.MapClaims, header map[string]any) { delete(claims, "sub") }, want: "missing subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp, provider := startProvider(t)
+			idp.Tamper = tt.tamper
+			code, nonce, verifier := authorize(t, provider)
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			_, err := provider.Exchange(code, verifier, nonce)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error about %s, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// PKCE: a stolen code is useless without the verifier
+	_, provider := startProvider(t)
+	code, nonce, _ := authorize(t, provider)
+	if _, err := provider.Exchange(code, "another verifier", nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("a code was exchanged with the wrong verifier: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+var ErrUnknownHash = errors.New("stored password hash has an unknown format")
+
+// Hasher hashes new passwords with the configured algorithm and verifies stored hashes of
+// either algorithm, so a switch doesn't lock anyone out
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	memory     uint32
+	time       uint32
+	threads    uint8
+	dummy      string
+}
+
+func NewHasher(cfg *config.Password) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:  cfg.Hash,
+		bcryptCost: cfg.BcryptCost,
+		memory:     cfg.Argon2Memory,
+		time:       cfg.Argon2Time,
+		threads:    cfg.Argon2Threads,
+	}
+	switch h.algorithm {
+	case "", Bcrypt:
+		h.algorithm = Bcrypt
+		if h.bcryptCost == 0 {
+			h.bcryptCost = bcrypt.DefaultCost
+		}
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("password bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if h.memory == 0 || h.time == 0 || h.threads == 0 {
+			return nil, fmt.Errorf("password argon2 memory, iterations and threads must be set")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash %q, use bcrypt or argon2id", cfg.Hash)
+	}
+
+	// unknown users are checked against this so a login takes as long whether or not they exist
+	dummy, err := h.Hash("dummy password for timing")
+	if err != nil {
+		return nil, err
+	}
+	h.dummy = dummy
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == Argon2id {
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed to hash password: %v", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.time, h.threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hashed), nil
+}
+
+// Verify checks a password against a stored hash of either algorithm
+func (h *Hasher) Verify(hash string, password string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// VerifyDummy spends the time of a real check for logins of unknown users
+func (h *Hasher) VerifyDummy(password string) {
+	h.Verify(h.dummy, password)
+}
+
+// NeedsRehash reports whether a stored hash is weaker than, or of another algorithm than, the
+// configured one
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		if h.algorithm != Argon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2(hash)
+		return err != nil || params.memory < h.memory || params.time < h.time || params.threads < h.threads
+	}
+	if h.algorithm != Bcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.bcryptCost
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// decodeArgon2 reads the $argon2id$v=19$m=..,t=..,p=..$salt$key format
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	return params, salt, key, nil
+}
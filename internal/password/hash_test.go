@@ -0,0 +1,79 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+)
+
+func newHasher(t *testing.T, cfg *config.Password) *Hasher {
+	t.Helper()
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNeedsRehash(t *testing.T) {
+	const password = "a long enough passphrase"
+	cheap := newHasher(t, &config.Password{Hash: Bcrypt, BcryptCost: 4})
+	costly := newHasher(t, &config.Password{Hash: Bcrypt, BcryptCost: 5})
+	argon := newHasher(t, &config.Password{Hash: Argon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1})
+	stronger := newHasher(t, &config.Password{Hash: Argon2id, Argon2Memory: 128, Argon2Time: 1, Argon2Threads: 1})
+
+	bcryptHash, err := cheap.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := argon.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected argon2id hash: %s", argonHash)
+	}
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   string
+		want   bool
+	}{
+		{"same bcrypt cost", cheap, bcryptHash, false},
+		{"bcrypt cost raised", costly, bcryptHash, true},
+		{"bcrypt cost lowered", cheap, mustHash(t, costly, password), false},
+		{"bcrypt to argon2id", argon, bcryptHash, true},
+		{"argon2id to bcrypt", cheap, argonHash, true},
+		{"same argon2id parameters", argon, argonHash, false},
+		{"argon2id memory raised", stronger, argonHash, true},
+		{"unreadable hash", argon, "$argon2id$garbage", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// either algorithm verifies hashes of the other, so switching locks nobody out
+	for _, h := range []*Hasher{cheap, argon} {
+		if !h.Verify(bcryptHash, password) || !h.Verify(argonHash, password) {
+			t.Fatalf("a %s hasher refused a stored hash", h.algorithm)
+		}
+		if h.Verify(bcryptHash, "wrong") || h.Verify(argonHash, "wrong") {
+			t.Fatalf("a %s hasher accepted a wrong password", h.algorithm)
+		}
+	}
+}
+
+func mustHash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
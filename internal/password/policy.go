@@ -0,0 +1,120 @@
+// Package password checks new passwords against the configured policy and hashes them with
+// bcrypt or argon2id.
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+)
+
+//go:embed common.txt
+var commonList string
+
+// common holds the bundled list of passwords that show up first in guessing attacks
+var common = func() map[string]bool {
+	set := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(commonList))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}()
+
+// ValidationError lists what is wrong with each field of a request
+type ValidationError struct {
+	Fields map[string][]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	var parts []string
+	for _, field := range fields {
+		parts = append(parts, field+" "+strings.Join(e.Fields[field], ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) FieldErrors() map[string][]string {
+	return e.Fields
+}
+
+type Policy struct {
+	cfg config.Password
+}
+
+func NewPolicy(cfg *config.Password) *Policy {
+	return &Policy{cfg: *cfg}
+}
+
+// Validate checks a new password. field names the request field it came in, email and
+// username are what the password may not be equal to (either can be empty).
+func (p *Policy) Validate(field string, password string, email string, username string) error {
+	var problems []string
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters", p.cfg.MinLength))
+	}
+	if p.cfg.MaxLength > 0 && len(password) > p.cfg.MaxLength {
+		problems = append(problems, fmt.Sprintf("must be at most %d bytes", p.cfg.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		problems = append(problems, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if !p.cfg.AllowCommon && common[lowered] {
+		problems = append(problems, "is too common")
+	}
+	if email != "" && (lowered == strings.ToLower(email) || lowered == strings.ToLower(localPart(email))) {
+		problems = append(problems, "must not be your email")
+	}
+	if username != "" && lowered == strings.ToLower(username) {
+		problems = append(problems, "must not be your username")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Fields: map[string][]string{field: problems}}
+	}
+	return nil
+}
+
+func localPart(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	return local
+}
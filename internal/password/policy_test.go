@@ -0,0 +1,54 @@
+package password
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+)
+
+func TestValidate(t *testing.T) {
+	strict := &config.Password{MinLength: 12, MaxLength: 72, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	lenient := &config.Password{MinLength: 3}
+	tests := []struct {
+		name     string
+		cfg      *config.Password
+		password string
+		want     []string
+	}{
+		{"meets every rule", strict, "Correct-Horse-9", nil},
+		{"too short", strict, "Sh0rt!", []string{"must be at least 12 characters"}},
+		// the length is counted in characters, the limit in bytes since bcrypt cuts at 72 bytes
+		{"short in bytes", &config.Password{MinLength: 4}, "äöüß", nil},
+		{"too long", &config.Password{MinLength: 4, MaxLength: 8}, "ääääää", []string{"must be at most 8 bytes"}},
+		{"missing classes", strict, "all lowercase words", []string{"must contain an uppercase letter", "must contain a digit", "must contain a symbol"}},
+		{"only symbols and digits", strict, "1234567890-=!@", []string{"must contain an uppercase letter", "must contain a lowercase letter"}},
+		{"common", lenient, "password", []string{"is too common"}},
+		{"common in another case", lenient, "LetMeIn", []string{"is too common"}},
+		{"common allowed", &config.Password{MinLength: 4, AllowCommon: true}, "password", nil},
+		{"the email", lenient, "ADA@example.com", []string{"must not be your email"}},
+		{"the local part of the email", lenient, "ada", []string{"must not be your email"}},
+		{"the username", lenient, "lovelace", []string{"must not be your username"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPolicy(tt.cfg).Validate("new_password", tt.password, "ada@example.com", "Lovelace")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("the password was refused: %v", err)
+				}
+				return
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) || !reflect.DeepEqual(invalid.Fields, map[string][]string{"new_password": tt.want}) {
+				t.Fatalf("got %v, want new_password %v", err, tt.want)
+			}
+		})
+	}
+
+	// without an email or username there's nothing to compare with
+	if err := NewPolicy(lenient).Validate("password", "anything else", "", ""); err != nil {
+		t.Fatalf("the password was refused: %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package response
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -11,3 +13,64 @@ func WriteResponse(w http.ResponseWriter, status int, data interface{}) error {
 
 	return json.NewEncoder(w).Encode(data)
 }
+
+// StatusClientClosedRequest is what nginx logs for a request the client gave up on, there's no
+// one left to read the response but it tells the logs apart from server errors
+const StatusClientClosedRequest = 499
+
+// WriteError answers a request that failed for a reason the client can't fix, 500 unless it
+// was canceled or ran out of time
+func WriteError(w http.ResponseWriter, err error) {
+	if !WriteCanceled(w, err) {
+		WriteResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// WriteCanceled answers 504 when the storage ran out of time and 499 when the client went away,
+// and reports whether err was either
+func WriteCanceled(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		WriteResponse(w, http.StatusGatewayTimeout, map[string]string{"error": "the request took too long, try again"})
+	case errors.Is(err, context.Canceled):
+		WriteResponse(w, StatusClientClosedRequest, map[string]string{"error": "the request was canceled"})
+	default:
+		return false
+	}
+	return true
+}
+
+// fieldErrors is implemented by validation errors that say what is wrong with each field
+type fieldErrors interface {
+	error
+	FieldErrors() map[string][]string
+}
+
+// WriteValidationError answers 400 with the problems of each field when err carries them and
+// reports whether it did
+func WriteValidationError(w http.ResponseWriter, err error) bool {
+	var invalid fieldErrors
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	WriteResponse(w, http.StatusBadRequest, map[string]any{"error": invalid.Error(), "fields": invalid.FieldErrors()})
+	return true
+}
+
+// blockingDependents is implemented by errors that refuse a delete because of the records that
+// still depend on the deleted one
+type blockingDependents interface {
+	error
+	BlockingDependents() any
+}
+
+// WriteDependentsError answers 409 with the records blocking a delete when err lists them and
+// reports whether it did
+func WriteDependentsError(w http.ResponseWriter, err error) bool {
+	var blocked blockingDependents
+	if !errors.As(err, &blocked) {
+		return false
+	}
+	WriteResponse(w, http.StatusConflict, map[string]any{"error": blocked.Error(), "dependents": blocked.BlockingDependents()})
+	return true
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var (
+	ErrAccountExists          = errors.New("an active account already exists for this record")
+	ErrInvalidActivationToken = errors.New("invalid or expired activation token")
+)
+
+// CreateAccount links a login account to a mentor or intern row and returns a single use
+// activation token. Calling it again for an account that was never activated issues a new token.
+func (sq *Sqlite) CreateAccount(role *string, entityId *int64, ttl time.Duration) (string, error) {
+	if role == nil || entityId == nil {
+		return "", fmt.Errorf("role and entity_id are required")
+	}
+
+	var table string
+	switch *role {
+	case types.RoleMentor:
+		table = "Mentors"
+	case types.RoleIntern:
+		table = "Interns"
+	default:
+		return "", fmt.Errorf("role must be mentor or intern")
+	}
+
+	var found int
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT 1 FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", entityId, sq.orgId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no %s found with id %d", *role, *entityId)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token, err := jwt.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	res, err := sq.DB.ExecContext(sq.ctx, `INSERT INTO Accounts (role,entity_id,activation_hash,activation_expires_at,created_at) VALUES (?,?,?,?,?)
+		ON CONFLICT (role,entity_id) DO UPDATE SET activation_hash=excluded.activation_hash, activation_expires_at=excluded.activation_expires_at
+		WHERE Accounts.password IS NULL`,
+		role, entityId, hashToken(token), now.Add(ttl).Unix(), now.Unix())
+	if err != nil {
+		return "", err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", ErrAccountExists
+	}
+	return token, nil
+}
+
+// ActivateAccount sets the password of the account the activation token was issued for
+func (sq *Sqlite) ActivateAccount(token *string, password *string) error {
+	if token == nil || *token == "" {
+		return ErrInvalidActivationToken
+	}
+	if password == nil || *password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	var id int64
+	var role string
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT id,role FROM Accounts WHERE activation_hash=? AND activation_expires_at>=?",
+		hashToken(*token), time.Now().Unix()).Scan(&id, &role)
+	if err == sql.ErrNoRows {
+		return ErrInvalidActivationToken
+	}
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := sq.hashUserPassword(sq.DB, role, id, "password", *password)
+	if err != nil {
+		return err
+	}
+
+	res, err := sq.DB.ExecContext(sq.ctx, `UPDATE Accounts SET password=?, activation_hash=NULL, activation_expires_at=NULL
+		WHERE id=? AND activation_hash=? AND activation_expires_at>=?`,
+		hashedPassword, id, hashToken(*token), time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidActivationToken
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/types"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrAdminNotFound   = errors.New("no admin found with the given id")
+	ErrAdminExists     = errors.New("an admin with this username or email already exists")
+	ErrLastActiveAdmin = errors.New("the last active admin of the organization can't be deleted, deactivated or removed")
+	ErrAdminShared     = errors.New("the admin also belongs to other organizations, remove them from this one instead")
+	ErrWrongPassword   = errors.New("current password is incorrect")
+)
+
+// isUniqueViolation reports whether an insert or update hit a UNIQUE constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// GetAdmins lists the admins of the handle's organization
+func (sq *Sqlite) GetAdmins() ([]types.ReturnAdmin, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT a.id,a.username,a.email,a.active,t.enabled_at IS NOT NULL FROM Admin as a
+		INNER JOIN AdminOrganizations as m on m.admin_id=a.id AND m.org_id=?
+		LEFT JOIN TwoFactor as t on t.admin_id=a.id ORDER BY a.id`, sq.orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := []types.ReturnAdmin{}
+	for rows.Next() {
+		var admin types.ReturnAdmin
+		err1 := rows.Scan(&admin.Id, &admin.Username, &admin.Email, &admin.Active, &admin.TwoFactorEnabled)
+		if err1 != nil {
+			return nil, err1
+		}
+		admins = append(admins, admin)
+	}
+	return admins, rows.Err()
+}
+
+func (sq *Sqlite) GetAdmin(id *int64) (*types.ReturnAdmin, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	var admin types.ReturnAdmin
+	err := sq.DB.QueryRowContext(sq.ctx, `SELECT a.id,a.username,a.email,a.active,t.enabled_at IS NOT NULL FROM Admin as a
+		INNER JOIN AdminOrganizations as m on m.admin_id=a.id AND m.org_id=?
+		LEFT JOIN TwoFactor as t on t.admin_id=a.id WHERE a.id=?`, sq.orgId, id).
+		Scan(&admin.Id, &admin.Username, &admin.Email, &admin.Active, &admin.TwoFactorEnabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrAdminNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// CreateAdmin adds an admin to the handle's organization
+func (sq *Sqlite) CreateAdmin(username *string, email *string, password *string) (int64, error) {
+	if username == nil || *username == "" || email == nil || *email == "" || password == nil || *password == "" {
+		return 0, fmt.Errorf("username, email and password are required")
+	}
+	if err := sq.requireOrg(); err != nil {
+		return 0, err
+	}
+	hashedPassword, err := sq.hashNewPassword("password", *password, strings.TrimSpace(*email), *username)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err1 := tx.QueryRowContext(sq.ctx, "INSERT INTO Admin (username,email,password) VALUES (?,?,?) RETURNING id", username, strings.TrimSpace(*email), hashedPassword).Scan(&id)
+	if isUniqueViolation(err1) {
+		return 0, ErrAdminExists
+	}
+	if err1 != nil {
+		return 0, err1
+	}
+	_, err1 = tx.ExecContext(sq.ctx, "INSERT INTO AdminOrganizations (admin_id,org_id,created_at) VALUES (?,?,?)", id, sq.orgId, time.Now().Unix())
+	if err1 != nil {
+		return 0, err1
+	}
+	return id, tx.Commit()
+}
+
+func (sq *Sqlite) UpdateAdmin(id *int64, username *string, email *string) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if username == nil || *username == "" || email == nil || *email == "" {
+		return fmt.Errorf("username and email are required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = sq.requireSoleMember(tx, *id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(sq.ctx, "UPDATE Admin SET username=?, email=? WHERE id=?", username, strings.TrimSpace(*email), id)
+	if isUniqueViolation(err) {
+		return ErrAdminExists
+	}
+	if err != nil {
+		return err
+	}
+	if err = requireAdminRow(res); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAdminActive turns the login of an admin of the handle's organization on or off.
+// Deactivating ends every session right away and is refused for the last active admin of the
+// organization.
+func (sq *Sqlite) SetAdminActive(id *int64, active bool) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = sq.requireSoleMember(tx, *id); err != nil {
+		return err
+	}
+	if active {
+		res, err1 := tx.ExecContext(sq.ctx, "UPDATE Admin SET active=1 WHERE id=?", id)
+		if err1 != nil {
+			return err1
+		}
+		if err1 = requireAdminRow(res); err1 != nil {
+			return err1
+		}
+		return tx.Commit()
+	}
+
+	if err = sq.lockOrgAdmins(tx); err != nil {
+		return err
+	}
+	res, err1 := tx.ExecContext(sq.ctx, "UPDATE Admin SET active=0 WHERE id=? AND "+otherActiveMember, id, sq.orgId, id)
+	if err1 != nil {
+		return err1
+	}
+	if err1 = lastAdminCheck(sq.ctx, tx, res, *id); err1 != nil {
+		return err1
+	}
+	if err1 = revokeAllTokens(sq.ctx, tx, types.RoleAdmin, *id); err1 != nil {
+		return err1
+	}
+	return tx.Commit()
+}
+
+// DeleteAdmin removes an admin who only belongs to the handle's organization, with their
+// two-factor setup, sso links and membership. It is refused for the last active admin of the
+// organization.
+func (sq *Sqlite) DeleteAdmin(id *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = sq.requireSoleMember(tx, *id); err != nil {
+		return err
+	}
+	if err = sq.lockOrgAdmins(tx); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(sq.ctx, "DELETE FROM Admin WHERE id=? AND (active=0 OR "+otherActiveMember+")", id, sq.orgId, id)
+	if err != nil {
+		return err
+	}
+	if err = lastAdminCheck(sq.ctx, tx, res, *id); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM TwoFactor WHERE admin_id=?",
+		"DELETE FROM RecoveryCodes WHERE admin_id=?",
+		"DELETE FROM OIDCIdentities WHERE role='admin' AND user_id=?",
+		"DELETE FROM PasswordResets WHERE role='admin' AND user_id=?",
+		"DELETE FROM AdminOrganizations WHERE admin_id=?",
+	} {
+		if _, err = tx.ExecContext(sq.ctx, query, id); err != nil {
+			return err
+		}
+	}
+	if err = revokeAllTokens(sq.ctx, tx, types.RoleAdmin, *id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAdminPassword lets an admin replace a colleague's password, e.g. after it leaked. The
+// colleague is logged out everywhere.
+func (sq *Sqlite) SetAdminPassword(id *int64, password *string) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if password == nil || *password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = sq.requireSoleMember(tx, *id); err != nil {
+		return err
+	}
+	hashedPassword, err := sq.hashUserPassword(tx, types.RoleAdmin, *id, "password", *password)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(sq.ctx, "UPDATE Admin SET password=? WHERE id=?", hashedPassword, id)
+	if err != nil {
+		return err
+	}
+	if err = requireAdminRow(res); err != nil {
+		return err
+	}
+	if err = revokeAllTokens(sq.ctx, tx, types.RoleAdmin, *id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ChangePassword replaces the caller's own password after checking the current one. Other
+// sessions are logged out, the one making the change stays signed in.
+func (sq *Sqlite) ChangePassword(principal *types.Principal, current *string, password *string) error {
+	if principal == nil {
+		return fmt.Errorf("principal is required")
+	}
+	if current == nil || password == nil || *password == "" {
+		return fmt.Errorf("current_password and new_password are required")
+	}
+
+	table := "Accounts"
+	if principal.Role == types.RoleAdmin {
+		table = "Admin"
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var dbPassword sql.NullString
+	err = tx.QueryRowContext(sq.ctx, "SELECT password FROM "+table+" WHERE id=?", principal.ID).Scan(&dbPassword)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// sso-only accounts have no password to check against, they set one with a reset link
+	if !dbPassword.Valid || !sq.hasher.Verify(dbPassword.String, *current) {
+		return ErrWrongPassword
+	}
+
+	hashedPassword, err := sq.hashUserPassword(tx, principal.Role, principal.ID, "new_password", *password)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(sq.ctx, "UPDATE "+table+" SET password=? WHERE id=?", hashedPassword, principal.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	_, err = tx.ExecContext(sq.ctx, `UPDATE RefreshTokens SET revoked_at=? WHERE role=? AND user_id=? AND family_id<>? AND revoked_at IS NULL`,
+		now, principal.Role, principal.ID, principal.SessionID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(sq.ctx, `UPDATE Sessions SET revoked_at=? WHERE role=? AND user_id=? AND id<>? AND revoked_at IS NULL`,
+		now, principal.Role, principal.ID, principal.SessionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// requireMember hides admins outside the handle's organization
+func (sq *Sqlite) requireMember(db querier, adminId int64) error {
+	member, err := isOrgMember(sq.ctx, db, adminId, sq.orgId)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrAdminNotFound
+	}
+	return nil
+}
+
+// requireSoleMember hides admins outside the handle's organization and refuses ones that also
+// belong to another organization, whose account is not the organization's to change
+func (sq *Sqlite) requireSoleMember(db querier, adminId int64) error {
+	if err := sq.requireMember(db, adminId); err != nil {
+		return err
+	}
+	var found int
+	err := db.QueryRowContext(sq.ctx, "SELECT 1 FROM AdminOrganizations WHERE admin_id=? AND org_id<>?", adminId, sq.orgId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrAdminShared
+}
+
+// lockOrgAdmins makes changes to the admins of the handle's organization wait for each other, so
+// two admins deactivating or removing each other at once can't both count the other as the
+// active one left. Postgres checks otherActiveMember against what was committed when the
+// statement started, sqlite transactions already write one at a time.
+func (sq *Sqlite) lockOrgAdmins(tx *sql.Tx) error {
+	if !sq.postgres {
+		return nil
+	}
+	_, err := tx.ExecContext(sq.ctx, "SELECT o.id FROM Admin as o INNER JOIN AdminOrganizations as om on om.admin_id=o.id WHERE om.org_id=? FOR UPDATE", sq.orgId)
+	return err
+}
+
+// otherActiveMember holds when another admin of the organization is active, it takes the
+// organization and the admin
+const otherActiveMember = "EXISTS (SELECT 1 FROM Admin as o INNER JOIN AdminOrganizations as om on om.admin_id=o.id WHERE om.org_id=? AND o.active=1 AND o.id<>?)"
+
+func requireAdminRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAdminNotFound
+	}
+	return nil
+}
+
+// lastAdminCheck tells apart a missing admin from a refused change to the last active one
+func lastAdminCheck(ctx context.Context, tx *sql.Tx, res sql.Result, id int64) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	var found int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM Admin WHERE id=?", id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrAdminNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrLastActiveAdmin
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var ErrAPIKeyNotFound = errors.New("no active api key found with the given id")
+
+const apiKeyPrefix = "tf_"
+
+// lastUsedResolution limits how often last_used_at of a busy key or last_seen_at of a busy
+// session is written
+const lastUsedResolution = time.Minute
+
+// CreateAPIKey stores a new key and returns it; only its hash is kept so it can't be shown again
+func (sq *Sqlite) CreateAPIKey(name *string, scopes []string, expiresAt *time.Time, createdBy int64) (int64, string, error) {
+	if name == nil || *name == "" {
+		return 0, "", fmt.Errorf("name is required")
+	}
+	if len(scopes) == 0 {
+		return 0, "", fmt.Errorf("at least one scope is required")
+	}
+	if err := sq.requireOrg(); err != nil {
+		return 0, "", err
+	}
+
+	secret, err := jwt.NewTokenID()
+	if err != nil {
+		return 0, "", err
+	}
+	key := apiKeyPrefix + secret
+
+	var expires sql.NullInt64
+	if expiresAt != nil {
+		expires = sql.NullInt64{Int64: expiresAt.Unix(), Valid: true}
+	}
+	var id int64
+	err = sq.DB.QueryRowContext(sq.ctx, `INSERT INTO ApiKeys (name,prefix,key_hash,scopes,created_by,org_id,created_at,expires_at) VALUES (?,?,?,?,?,?,?,?) RETURNING id`,
+		name, key[:len(apiKeyPrefix)+8], hashToken(key), strings.Join(scopes, ","), createdBy, sq.orgId, time.Now().Unix(), expires).Scan(&id)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, key, nil
+}
+
+func (sq *Sqlite) GetAPIKeys() ([]types.ReturnAPIKey, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT id,name,prefix,scopes,created_by,created_at,expires_at,last_used_at,revoked_at
+		FROM ApiKeys WHERE org_id=? ORDER BY id DESC`, sq.orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now().Unix()
+	keys := []types.ReturnAPIKey{}
+	for rows.Next() {
+		var key types.ReturnAPIKey
+		var scopes string
+		var createdAt int64
+		var expiresAt, lastUsedAt, revokedAt sql.NullInt64
+		err1 := rows.Scan(&key.Id, &key.Name, &key.Prefix, &scopes, &key.CreatedBy, &createdAt, &expiresAt, &lastUsedAt, &revokedAt)
+		if err1 != nil {
+			return nil, err1
+		}
+		key.Scopes = strings.Split(scopes, ",")
+		key.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
+		if expiresAt.Valid {
+			key.ExpiresAt = time.Unix(expiresAt.Int64, 0).UTC().Format(time.RFC3339)
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = time.Unix(lastUsedAt.Int64, 0).UTC().Format(time.RFC3339)
+		}
+		switch {
+		case revokedAt.Valid:
+			key.Status = "revoked"
+		case expiresAt.Valid && expiresAt.Int64 < now:
+			key.Status = "expired"
+		default:
+			key.Status = "active"
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (sq *Sqlite) RevokeAPIKey(id *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	res, err := sq.DB.ExecContext(sq.ctx, "UPDATE ApiKeys SET revoked_at=? WHERE id=? AND org_id=? AND revoked_at IS NULL", time.Now().Unix(), id, sq.orgId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// APIKeyClaims looks up an active key and records that it was used. Keys work in the
+// organization they were created in.
+func (sq *Sqlite) APIKeyClaims(ctx context.Context, key string) (*types.CustomClaims, error) {
+	sq = sq.WithContext(ctx)
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil
+	}
+	now := time.Now()
+	claims := types.CustomClaims{Role: types.RoleAPIKey}
+	var scopes string
+	err := sq.DB.QueryRowContext(sq.ctx, `SELECT id,scopes,org_id FROM ApiKeys
+		WHERE key_hash=? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at>=?)`,
+		hashToken(key), now.Unix()).Scan(&claims.ID, &scopes, &claims.OrgID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims.Scopes = strings.Split(scopes, ",")
+
+	_, err = sq.DB.ExecContext(sq.ctx, "UPDATE ApiKeys SET last_used_at=? WHERE id=? AND (last_used_at IS NULL OR last_used_at<?)",
+		now.Unix(), claims.ID, now.Add(-lastUsedResolution).Unix())
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
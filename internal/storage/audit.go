@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/middleware/requestid"
+)
+
+// What an audit entry says happened to a record
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete" // moved to the trash
+	AuditRestore = "restore"
+)
+
+var ErrNotAudited = errors.New("only mentors, interns, projects and assignments are audited")
+
+// AuditEntry is one change to a mentor, intern, project or assignment. Changes maps every field
+// the change touched to {"old":...,"new":...}, old is null for created records and new is null
+// for deleted ones. ActorId is 0 when no one signed in made the change.
+type AuditEntry struct {
+	Id         int64           `json:"id"`
+	Entity     string          `json:"entity"`
+	EntityId   int64           `json:"entity_id"`
+	Action     string          `json:"action"`
+	ActorId    int64           `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	ActorEmail string          `json:"actor_email"`
+	RequestId  string          `json:"request_id"`
+	Changes    json.RawMessage `json:"changes"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows down the audit log, zero fields match everything
+type AuditFilter struct {
+	Entity    string // mentor, intern, project or assignment
+	EntityId  int64
+	ActorId   int64
+	ActorRole string
+	Since     time.Time
+	Until     time.Time // exclusive
+	Limit     int       // 100 unless between 1 and 1000
+}
+
+// PageSize is how many entries a listing with the filter returns at most
+func (f *AuditFilter) PageSize() int {
+	if f.Limit <= 0 || f.Limit > 1000 {
+		return 100
+	}
+	return f.Limit
+}
+
+// FieldChange is a field before and after a change
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// auditedColumns are the fields of a record an audit entry compares
+var auditedColumns = map[string][]string{
+	"Mentors":     {"name", "email", "department"},
+	"Interns":     {"name", "email", "status", "mentor_id"},
+	"Projects":    {"name", "description", "status", "start_date", "end_date"},
+	"Assignments": {"intern_id", "project_id", "progress", "remarks"},
+}
+
+// AuditChanges lists the fields that differ between two snapshots of a record, a nil snapshot
+// is a record that doesn't exist. Values are strings, int64s or nil. It returns nil when nothing
+// changed.
+func AuditChanges(before, after map[string]any) (json.RawMessage, error) {
+	changes := map[string]FieldChange{}
+	for field, old := range before {
+		if value := after[field]; value != old {
+			changes[field] = FieldChange{Old: old, New: value}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok && value != nil {
+			changes[field] = FieldChange{New: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+// NewAuditEntry describes a change made on behalf of the request ctx belongs to
+func NewAuditEntry(ctx context.Context, table string, id int64, action string, changes json.RawMessage) AuditEntry {
+	entry := AuditEntry{
+		Entity:    entityName(table),
+		EntityId:  id,
+		Action:    action,
+		RequestId: requestid.FromContext(ctx),
+		Changes:   changes,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if claims, ok := jwt.ClaimsFromContext(ctx); ok {
+		entry.ActorId, entry.ActorRole, entry.ActorEmail = claims.ID, claims.Role, claims.Email
+	}
+	return entry
+}
+
+// snapshot reads the audited columns of a row of the organization, nil when it doesn't exist or
+// is in the trash
+func (sq *Sqlite) snapshot(db querier, table string, id int64) (map[string]any, error) {
+	columns := auditedColumns[table]
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	err := db.QueryRowContext(sq.ctx, "SELECT "+strings.Join(columns, ",")+" FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", id, sq.orgId).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]any, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+// audit records what the transaction did to the row since before was taken with snapshot, and
+// adds the new state of interns and assignments to their history. No audit entry is added when
+// none of the audited columns changed.
+func (sq *Sqlite) audit(tx *sql.Tx, table string, id int64, action string, before map[string]any) error {
+	if err := sq.writeHistory(tx, table, id); err != nil {
+		return err
+	}
+	after, err := sq.snapshot(tx, table, id)
+	if err != nil {
+		return err
+	}
+	changes, err := AuditChanges(before, after)
+	if err != nil || changes == nil {
+		return err
+	}
+	entry := NewAuditEntry(sq.ctx, table, id, action, changes)
+	_, err = tx.ExecContext(sq.ctx, `INSERT INTO AuditLog (org_id,entity,entity_id,action,actor_id,actor_role,actor_email,request_id,changes,created_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?)`, sq.orgId, entry.Entity, entry.EntityId, entry.Action, entry.ActorId, entry.ActorRole, entry.ActorEmail,
+		entry.RequestId, string(entry.Changes), entry.CreatedAt.Unix())
+	return err
+}
+
+// GetAudit lists the audit entries of the organization that match the filter, newest first
+func (sq *Sqlite) GetAudit(filter *AuditFilter) ([]AuditEntry, error) {
+	query := "SELECT id,entity,entity_id,action,actor_id,actor_role,actor_email,request_id,changes,created_at FROM AuditLog WHERE org_id=?"
+	args := []any{sq.orgId}
+	if filter.Entity != "" {
+		if _, ok := TrashTables[filter.Entity]; !ok {
+			return nil, ErrNotAudited
+		}
+		query += " AND entity=?"
+		args = append(args, filter.Entity)
+	}
+	if filter.EntityId != 0 {
+		query += " AND entity_id=?"
+		args = append(args, filter.EntityId)
+	}
+	if filter.ActorId != 0 {
+		query += " AND actor_id=?"
+		args = append(args, filter.ActorId)
+	}
+	if filter.ActorRole != "" {
+		query += " AND actor_role=?"
+		args = append(args, filter.ActorRole)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at>=?"
+		args = append(args, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at<?"
+		args = append(args, filter.Until.Unix())
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.PageSize())
+
+	rows, err := sq.DB.QueryContext(sq.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var changes string
+		var createdAt int64
+		err := rows.Scan(&entry.Id, &entry.Entity, &entry.EntityId, &entry.Action, &entry.ActorId, &entry.ActorRole, &entry.ActorEmail,
+			&entry.RequestId, &changes, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Changes = json.RawMessage(changes)
+		entry.CreatedAt = time.Unix(createdAt, 0).UTC()
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// insert adds a row with a query that returns its id and records its creation, in one transaction
+func (sq *Sqlite) insert(table string, query string, args ...any) (int64, error) {
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(sq.ctx, query, args...).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := sq.audit(tx, table, id, AuditCreate, nil); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// update runs a versioned UPDATE of the row and records what it changed, in one transaction
+func (sq *Sqlite) update(table string, id int64, query string, args []any, version *int64) error {
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := sq.snapshot(tx, table, id)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(sq.ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := sq.checkUpdated(tx, res, table, id, version); err != nil {
+		return err
+	}
+	if err := sq.audit(tx, table, id, AuditUpdate, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OnDelete is what deleting a row does to the rows that point at it
+type OnDelete string
+
+const (
+	Restrict OnDelete = "restrict" // the delete is refused while they exist
+	Cascade  OnDelete = "cascade"  // they are deleted with it
+	SetNull  OnDelete = "set null" // they stay and point at nothing
+)
+
+// Relationship is a foreign key from Child.Column to Parent.id
+type Relationship struct {
+	Child    string
+	Column   string
+	Parent   string
+	OnDelete OnDelete
+}
+
+// Relationships are the foreign keys between mentors, interns, projects and assignments. The
+// migrations declare the same ON DELETE actions, memory.Store follows them too.
+var Relationships = []Relationship{
+	// interns are moved to another mentor first, they'd be left without one otherwise
+	{Child: "Interns", Column: "mentor_id", Parent: "Mentors", OnDelete: Restrict},
+	// an assignment is the intern's work, it goes with them
+	{Child: "Assignments", Column: "intern_id", Parent: "Interns", OnDelete: Cascade},
+	// deleting a project would erase the progress of everyone on it
+	{Child: "Assignments", Column: "project_id", Parent: "Projects", OnDelete: Restrict},
+}
+
+// ErrHasDependents matches every DependentsError
+var ErrHasDependents = errors.New("other records still depend on this one")
+
+// Dependent is a row that points at another
+type Dependent struct {
+	Entity string `json:"entity"`
+	Id     int64  `json:"id"`
+	Name   string `json:"name"`
+}
+
+// DependentsError refuses a delete and lists the rows that restrict it
+type DependentsError struct {
+	Entity     string
+	Id         int64
+	Dependents []Dependent
+}
+
+func (e *DependentsError) Error() string {
+	if len(e.Dependents) == 1 {
+		return fmt.Sprintf("%s %d can't be deleted, 1 record still depends on it", e.Entity, e.Id)
+	}
+	return fmt.Sprintf("%s %d can't be deleted, %d records still depend on it", e.Entity, e.Id, len(e.Dependents))
+}
+
+func (e *DependentsError) Is(target error) bool {
+	return target == ErrHasDependents
+}
+
+// BlockingDependents is what response.WriteDependentsError lists
+func (e *DependentsError) BlockingDependents() any {
+	return e.Dependents
+}
+
+// entityName is how errors and dependents call the rows of a table
+func entityName(table string) string {
+	return strings.ToLower(strings.TrimSuffix(table, "s"))
+}
+
+// DeleteImpact is what deleting a row does to the rows that point at it, directly or through
+// rows deleted along with it
+type DeleteImpact struct {
+	Entity     string      `json:"entity"`
+	Id         int64       `json:"id"`
+	Name       string      `json:"name"`
+	ReassignTo *int64      `json:"reassign_to,omitempty"`
+	Blocked    []Dependent `json:"blocked"` // the delete is refused while there are any
+	Deleted    []Dependent `json:"deleted"`
+	Nulled     []Dependent `json:"nulled"`
+	Reassigned []Dependent `json:"reassigned"` // moved to ReassignTo instead
+}
+
+// NewDeleteImpact is an impact with nothing filed under it yet
+func NewDeleteImpact(entity string, id int64, reassignTo *int64) *DeleteImpact {
+	return &DeleteImpact{Entity: entity, Id: id, ReassignTo: reassignTo,
+		Blocked: []Dependent{}, Deleted: []Dependent{}, Nulled: []Dependent{}, Reassigned: []Dependent{}}
+}
+
+// Add files a dependent under what its relationship does to it, reassigned ones all move
+func (d *DeleteImpact) Add(rel Relationship, dependent Dependent) {
+	switch {
+	case d.ReassignTo != nil:
+		d.Reassigned = append(d.Reassigned, dependent)
+	case rel.OnDelete == Restrict:
+		d.Blocked = append(d.Blocked, dependent)
+	case rel.OnDelete == SetNull:
+		d.Nulled = append(d.Nulled, dependent)
+	default:
+		d.Deleted = append(d.Deleted, dependent)
+	}
+}
+
+var (
+	ErrRecordNotFound  = errors.New("record not found")
+	ErrInvalidEntity   = errors.New("only mentors, interns and projects have dependents")
+	ErrReassignToSelf  = errors.New("dependents can't be reassigned to the record being deleted")
+	ErrReassignMissing = errors.New("the record to reassign dependents to doesn't exist")
+)
+
+// DeletableTables are the tables of the entities DeletionRepository takes
+var DeletableTables = map[string]string{"mentor": "Mentors", "intern": "Interns", "project": "Projects"}
+
+// rowLabels name a row in a dependents list or delete preview
+var rowLabels = map[string]string{
+	"Mentors":     "Mentors.name",
+	"Interns":     "Interns.name",
+	"Projects":    "Projects.name",
+	"Assignments": "(SELECT name FROM Interns WHERE Interns.id=Assignments.intern_id) || ' on ' || (SELECT name FROM Projects WHERE Projects.id=Assignments.project_id)",
+}
+
+type rowReader interface {
+	querier
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// children lists the rows of the organization that point at the row through rel, rows in the
+// trash don't count
+func (sq *Sqlite) children(db rowReader, rel Relationship, id int64) ([]Dependent, error) {
+	return sq.dependents(db, rel, id, "deleted_at IS NULL")
+}
+
+// dependents lists the rows of the organization that point at the row through rel and meet the
+// condition, args fill its placeholders
+func (sq *Sqlite) dependents(db rowReader, rel Relationship, id int64, condition string, args ...any) ([]Dependent, error) {
+	rows, err := db.QueryContext(sq.ctx, "SELECT id,COALESCE("+rowLabels[rel.Child]+",'') FROM "+rel.Child+" WHERE "+rel.Column+"=? AND org_id=? AND "+condition+" ORDER BY id",
+		append([]any{id, sq.orgId}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Dependent{}
+	for rows.Next() {
+		dependent := Dependent{Entity: entityName(rel.Child)}
+		if err := rows.Scan(&dependent.Id, &dependent.Name); err != nil {
+			return nil, err
+		}
+		list = append(list, dependent)
+	}
+	return list, rows.Err()
+}
+
+// impact works out what deleting the row of the organization would do, reassigning its
+// dependents to another row of the table when reassignTo is set. Rows in the trash are treated
+// as gone.
+func (sq *Sqlite) impact(db rowReader, table string, id int64, reassignTo *int64) (*DeleteImpact, error) {
+	impact := NewDeleteImpact(entityName(table), id, reassignTo)
+	err := db.QueryRowContext(sq.ctx, "SELECT COALESCE("+rowLabels[table]+",'') FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", id, sq.orgId).Scan(&impact.Name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: no %s with id %d", ErrRecordNotFound, impact.Entity, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reassignTo != nil {
+		if *reassignTo == id {
+			return nil, ErrReassignToSelf
+		}
+		var found int
+		err := db.QueryRowContext(sq.ctx, "SELECT 1 FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", *reassignTo, sq.orgId).Scan(&found)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: no %s with id %d", ErrReassignMissing, impact.Entity, *reassignTo)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return impact, sq.collectImpact(db, impact, table, id)
+}
+
+func (sq *Sqlite) collectImpact(db rowReader, impact *DeleteImpact, table string, id int64) error {
+	for _, rel := range Relationships {
+		if rel.Parent != table {
+			continue
+		}
+		children, err := sq.children(db, rel, id)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			impact.Add(rel, child)
+			// rows deleted along with this one take their own dependents with them
+			if impact.ReassignTo == nil && rel.OnDelete == Cascade {
+				if err := sq.collectImpact(db, impact, rel.Child, child.Id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteImpact previews deleting a mentor, intern or project without changing anything
+func (sq *Sqlite) DeleteImpact(entity string, id int64, reassignTo *int64) (*DeleteImpact, error) {
+	table, ok := DeletableTables[entity]
+	if !ok {
+		return nil, ErrInvalidEntity
+	}
+	return sq.impact(sq.DB, table, id, reassignTo)
+}
+
+// DeleteReassigning points everything that points at the row at reassignTo instead and moves
+// the row to the trash, in one transaction
+func (sq *Sqlite) DeleteReassigning(entity string, id int64, reassignTo int64, version *int64) error {
+	table, ok := DeletableTables[entity]
+	if !ok {
+		return ErrInvalidEntity
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := sq.impact(tx, table, id, &reassignTo); err != nil {
+		return err
+	}
+	if err := sq.requireVersion(tx, table, id, version); err != nil {
+		return err
+	}
+	for _, rel := range Relationships {
+		if rel.Parent != table {
+			continue
+		}
+		if err := sq.repoint(tx, rel, id, &reassignTo); err != nil {
+			return err
+		}
+	}
+	if err := sq.trashRow(tx, table, id, time.Now().Unix(), version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteRow moves a row of the organization to the trash unless a restricting relationship still
+// points at it or at a row the delete cascades to. Deleting a row that isn't there does nothing,
+// unless the caller expected a version of it.
+func (sq *Sqlite) deleteRow(table string, id int64, version *int64) error {
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	impact, err := sq.impact(tx, table, id, nil)
+	if errors.Is(err, ErrRecordNotFound) && version == nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := sq.requireVersion(tx, table, id, version); err != nil {
+		return err
+	}
+	if len(impact.Blocked) > 0 {
+		return &DependentsError{Entity: impact.Entity, Id: id, Dependents: impact.Blocked}
+	}
+	if err := sq.trashRow(tx, table, id, time.Now().Unix(), version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// trashRow sets deleted_at on the row and applies Relationships to the rows that point at it,
+// the ones it cascades to are trashed at the same time so they can be restored with it. Each
+// row it changes gets an audit entry. The update itself checks the version and the restricting
+// relationships again, which catches changes made since they were looked at.
+func (sq *Sqlite) trashRow(tx *sql.Tx, table string, id int64, now int64, version *int64) error {
+	query := "UPDATE " + table + " SET deleted_at=?, version=version+1 WHERE id=? AND org_id=? AND deleted_at IS NULL"
+	for _, rel := range Relationships {
+		if rel.Parent == table && rel.OnDelete == Restrict {
+			query += " AND NOT EXISTS (SELECT 1 FROM " + rel.Child + " WHERE " + rel.Child + "." + rel.Column + "=" + table + ".id AND " + rel.Child + ".deleted_at IS NULL)"
+		}
+	}
+	before, err := sq.snapshot(tx, table, id)
+	if err != nil {
+		return err
+	}
+	query, args := versioned(query, []any{now, id, sq.orgId}, version)
+	res, err := tx.ExecContext(sq.ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if err := sq.requireVersion(tx, table, id, version); err != nil {
+			return err
+		}
+		return &DependentsError{Entity: entityName(table), Id: id, Dependents: []Dependent{}}
+	}
+	if err := sq.audit(tx, table, id, AuditDelete, before); err != nil {
+		return err
+	}
+
+	for _, rel := range Relationships {
+		if rel.Parent != table {
+			continue
+		}
+		switch rel.OnDelete {
+		case Cascade:
+			children, err := sq.children(tx, rel, id)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := sq.trashRow(tx, rel.Child, child.Id, now, nil); err != nil {
+					return err
+				}
+			}
+		case SetNull:
+			if err := sq.repoint(tx, rel, id, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// repoint points the rows that point at the row through rel at another row, nil for none, and
+// records the change of each
+func (sq *Sqlite) repoint(tx *sql.Tx, rel Relationship, id int64, to *int64) error {
+	children, err := sq.children(tx, rel, id)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		before, err := sq.snapshot(tx, rel.Child, child.Id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(sq.ctx, "UPDATE "+rel.Child+" SET "+rel.Column+"=?, version=version+1 WHERE id=? AND org_id=?", to, child.Id, sq.orgId)
+		if err != nil {
+			return err
+		}
+		if err := sq.audit(tx, rel.Child, child.Id, AuditUpdate, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
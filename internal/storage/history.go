@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// historyTables keep every state of a row of the table, valid from valid_from until valid_to
+var historyTables = map[string]struct {
+	table, column string
+	columns       []string
+}{
+	"Interns":     {"InternHistory", "intern_id", []string{"name", "email", "status", "mentor_id", "version"}},
+	"Assignments": {"AssignmentHistory", "assignment_id", []string{"intern_id", "project_id", "progress", "remarks", "version"}},
+}
+
+// writeHistory closes the current history row of an intern or assignment and adds its state as
+// the transaction left it, unless it's gone or in the trash. Other tables keep no history.
+func (sq *Sqlite) writeHistory(tx *sql.Tx, table string, id int64) error {
+	history, ok := historyTables[table]
+	if !ok {
+		return nil
+	}
+	now := time.Now().Unix()
+	_, err := tx.ExecContext(sq.ctx, "UPDATE "+history.table+" SET valid_to=? WHERE "+history.column+"=? AND org_id=? AND valid_to IS NULL", now, id, sq.orgId)
+	if err != nil {
+		return err
+	}
+	columns := strings.Join(history.columns, ",")
+	_, err = tx.ExecContext(sq.ctx, "INSERT INTO "+history.table+" ("+history.column+",org_id,"+columns+",valid_from) SELECT id,org_id,"+columns+",CAST(? AS BIGINT) FROM "+table+
+		" WHERE id=? AND org_id=? AND deleted_at IS NULL", now, id, sq.orgId)
+	return err
+}
+
+// validAt is the condition for history rows of alias that were current at the time, it takes
+// the time twice
+func validAt(alias string) string {
+	return alias + ".valid_from<=? AND (" + alias + ".valid_to IS NULL OR " + alias + ".valid_to>?)"
+}
+
+// GetInternsAsOf lists the interns of the organization as they were at the time, the scope
+// applies to who their mentor was then. Mentor names are the current ones.
+func (sq *Sqlite) GetInternsAsOf(scope *types.Scope, asOf time.Time) ([]types.ReturnIntern, error) {
+	condition, args := "", []any{}
+	if scope != nil && scope.MentorId != 0 {
+		condition, args = " AND a.mentor_id=?", []any{scope.MentorId}
+	} else if scope != nil && scope.InternId != 0 {
+		condition, args = " AND a.intern_id=?", []any{scope.InternId}
+	}
+	return sq.queryInternsAsOf(asOf, condition, args...)
+}
+
+func (sq *Sqlite) GetInternAsOf(id *int64, asOf time.Time) (*types.ReturnIntern, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	interns, err := sq.queryInternsAsOf(asOf, " AND a.intern_id=?", *id)
+	if err != nil {
+		return nil, err
+	}
+	if len(interns) == 0 {
+		return nil, fmt.Errorf("%w: no intern with id %d at %s", ErrRecordNotFound, *id, asOf.UTC().Format(time.RFC3339))
+	}
+	return &interns[0], nil
+}
+
+// queryInternsAsOf lists the interns of the organization at the time that meet the extra
+// condition, a is the intern's history row and b its mentor
+func (sq *Sqlite) queryInternsAsOf(asOf time.Time, condition string, args ...any) ([]types.ReturnIntern, error) {
+	query := "SELECT a.intern_id,a.name,a.email,COALESCE(a.status,''),a.mentor_id,COALESCE(b.name,''),COALESCE(b.email,''),a.version FROM InternHistory as a LEFT JOIN Mentors as b on a.mentor_id=b.id WHERE a.org_id=? AND " +
+		validAt("a") + condition + " ORDER BY a.intern_id"
+	rows, err := sq.DB.QueryContext(sq.ctx, query, append([]any{sq.orgId, asOf.Unix(), asOf.Unix()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	interns := []types.ReturnIntern{}
+	for rows.Next() {
+		var intern types.ReturnIntern
+		var mentorId sql.NullInt64
+		err := rows.Scan(&intern.ID, &intern.Name, &intern.Email, &intern.Status, &mentorId, &intern.MentorName, &intern.MentorEmail, &intern.Version)
+		if err != nil {
+			return nil, err
+		}
+		if mentorId.Valid {
+			intern.MentorId = strconv.FormatInt(mentorId.Int64, 10)
+		}
+		interns = append(interns, intern)
+	}
+	return interns, rows.Err()
+}
+
+// GetAssignmentsAsOf lists the assignments of the organization as they were at the time, with
+// the names their intern had then. The scope applies to who the intern's mentor was then.
+func (sq *Sqlite) GetAssignmentsAsOf(scope *types.Scope, asOf time.Time) ([]types.ReturnAssignment, error) {
+	if scope != nil && scope.MentorId != 0 {
+		return sq.queryAssignmentsAsOf(asOf, " AND b.mentor_id=?", scope.MentorId)
+	} else if scope != nil && scope.InternId != 0 {
+		return sq.queryAssignmentsAsOf(asOf, " AND a.intern_id=?", scope.InternId)
+	}
+	return sq.queryAssignmentsAsOf(asOf, "")
+}
+
+func (sq *Sqlite) GetAssignmentAsOf(id *int64, asOf time.Time) (*types.ReturnAssignment, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	assignments, err := sq.queryAssignmentsAsOf(asOf, " AND a.assignment_id=?", *id)
+	if err != nil {
+		return nil, err
+	}
+	if len(assignments) == 0 {
+		return nil, fmt.Errorf("%w: no assignment with id %d at %s", ErrRecordNotFound, *id, asOf.UTC().Format(time.RFC3339))
+	}
+	return &assignments[0], nil
+}
+
+// queryAssignmentsAsOf lists the assignments of the organization at the time that meet the extra
+// condition, a is the assignment's history row, b its intern's and c its project
+func (sq *Sqlite) queryAssignmentsAsOf(asOf time.Time, condition string, args ...any) ([]types.ReturnAssignment, error) {
+	query := "SELECT a.assignment_id,a.intern_id,a.project_id,COALESCE(a.progress,0),COALESCE(a.remarks,''),COALESCE(b.name,''),COALESCE(c.name,''),a.version FROM AssignmentHistory as a " +
+		"LEFT JOIN InternHistory as b on a.intern_id=b.intern_id AND " + validAt("b") + " LEFT JOIN Projects as c on a.project_id=c.id WHERE a.org_id=? AND " +
+		validAt("a") + condition + " ORDER BY a.assignment_id"
+	at := asOf.Unix()
+	rows, err := sq.DB.QueryContext(sq.ctx, query, append([]any{at, at, sq.orgId, at, at}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	assignments := []types.ReturnAssignment{}
+	for rows.Next() {
+		var assign types.ReturnAssignment
+		err := rows.Scan(&assign.Id, &assign.InternId, &assign.ProjectId, &assign.Progress, &assign.Remarks, &assign.InternName, &assign.ProjectName, &assign.Version)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assign)
+	}
+	return assignments, rows.Err()
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var (
+	ErrInvalidInvitation  = errors.New("invalid, used or expired invitation")
+	ErrInvitationNotFound = errors.New("no pending invitation found with the given id")
+)
+
+// CreateInvitation stores a single use invitation and returns the raw token for the email
+func (sq *Sqlite) CreateInvitation(email *string, role *string, entityId *int64, invitedBy int64, ttl time.Duration) (int64, string, error) {
+	if email == nil || *email == "" || role == nil {
+		return 0, "", fmt.Errorf("email and role are required")
+	}
+	if err := sq.requireOrg(); err != nil {
+		return 0, "", err
+	}
+
+	switch *role {
+	case types.RoleAdmin:
+		entityId = nil
+	case types.RoleMentor, types.RoleIntern:
+		if entityId == nil || *entityId == 0 {
+			return 0, "", fmt.Errorf("entity_id is required for %s invitations", *role)
+		}
+		table := "Mentors"
+		if *role == types.RoleIntern {
+			table = "Interns"
+		}
+		var rowEmail string
+		err := sq.DB.QueryRowContext(sq.ctx, "SELECT email FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", entityId, sq.orgId).Scan(&rowEmail)
+		if err == sql.ErrNoRows {
+			return 0, "", fmt.Errorf("no %s found with id %d", *role, *entityId)
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		if rowEmail != *email {
+			return 0, "", fmt.Errorf("email does not match the %s's email", *role)
+		}
+	default:
+		return 0, "", fmt.Errorf("role must be admin, mentor or intern")
+	}
+
+	token, err := jwt.NewTokenID()
+	if err != nil {
+		return 0, "", err
+	}
+	now := time.Now()
+	var id int64
+	err = sq.DB.QueryRowContext(sq.ctx, `INSERT INTO Invitations (email,role,entity_id,token_hash,invited_by,org_id,created_at,expires_at)
+		VALUES (?,?,?,?,?,?,?,?) RETURNING id`, email, role, entityId, hashToken(token), invitedBy, sq.orgId, now.Unix(), now.Add(ttl).Unix()).Scan(&id)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, token, nil
+}
+
+func (sq *Sqlite) GetInvitations() ([]types.ReturnInvitation, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT id,email,role,entity_id,invited_by,created_at,expires_at,accepted_at,revoked_at
+		FROM Invitations WHERE org_id=? ORDER BY id DESC`, sq.orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now().Unix()
+	invitations := []types.ReturnInvitation{}
+	for rows.Next() {
+		var inv types.ReturnInvitation
+		var entityId, acceptedAt, revokedAt sql.NullInt64
+		var createdAt, expiresAt int64
+		err1 := rows.Scan(&inv.Id, &inv.Email, &inv.Role, &entityId, &inv.InvitedBy, &createdAt, &expiresAt, &acceptedAt, &revokedAt)
+		if err1 != nil {
+			return nil, err1
+		}
+		inv.EntityId = entityId.Int64
+		inv.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
+		inv.ExpiresAt = time.Unix(expiresAt, 0).UTC().Format(time.RFC3339)
+		switch {
+		case acceptedAt.Valid:
+			inv.Status = "accepted"
+		case revokedAt.Valid:
+			inv.Status = "revoked"
+		case expiresAt < now:
+			inv.Status = "expired"
+		default:
+			inv.Status = "pending"
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+func (sq *Sqlite) RevokeInvitation(id *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	res, err := sq.DB.ExecContext(sq.ctx, "UPDATE Invitations SET revoked_at=? WHERE id=? AND org_id=? AND accepted_at IS NULL AND revoked_at IS NULL", time.Now().Unix(), id, sq.orgId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation consumes the invitation and creates the admin or account it was issued for
+func (sq *Sqlite) AcceptInvitation(token *string, username *string, password *string) (*types.Principal, error) {
+	if token == nil || *token == "" {
+		return nil, ErrInvalidInvitation
+	}
+	if password == nil || *password == "" {
+		return nil, fmt.Errorf("password is required")
+	}
+
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var entityId sql.NullInt64
+	principal := types.Principal{}
+	err = tx.QueryRowContext(sq.ctx, `SELECT id,email,role,entity_id,org_id FROM Invitations
+		WHERE token_hash=? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at>=?`,
+		hashToken(*token), time.Now().Unix()).Scan(&id, &principal.Email, &principal.Role, &entityId, &principal.OrgID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+	principal.EntityID = entityId.Int64
+
+	var name string
+	if username != nil {
+		name = *username
+	}
+	hashedPassword, err := sq.hashNewPassword("password", *password, principal.Email, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if principal.Role == types.RoleAdmin {
+		if username == nil || *username == "" {
+			return nil, fmt.Errorf("username is required")
+		}
+		err = tx.QueryRowContext(sq.ctx, "INSERT INTO Admin (username,email,password) VALUES (?,?,?) RETURNING id", username, principal.Email, hashedPassword).Scan(&principal.ID)
+		if err == nil {
+			// invited admins join the organization they were invited to
+			_, err = tx.ExecContext(sq.ctx, "INSERT INTO AdminOrganizations (admin_id,org_id,created_at) VALUES (?,?,?)", principal.ID, principal.OrgID, time.Now().Unix())
+		}
+	} else {
+		err = tx.QueryRowContext(sq.ctx, `INSERT INTO Accounts (role,entity_id,password,created_at) VALUES (?,?,?,?)
+			ON CONFLICT (role,entity_id) DO UPDATE SET password=excluded.password, activation_hash=NULL, activation_expires_at=NULL
+			WHERE Accounts.password IS NULL RETURNING id`,
+			principal.Role, principal.EntityID, hashedPassword, time.Now().Unix()).Scan(&principal.ID)
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountExists
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(sq.ctx, "UPDATE Invitations SET accepted_at=? WHERE id=?", time.Now().Unix(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
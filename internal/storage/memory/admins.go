@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+type admin struct {
+	id              int64
+	username, email string
+	password        string
+	active          bool
+}
+
+func (t *tenant) isMember(adminId int64) bool {
+	_, ok := t.members[adminId][t.orgId]
+	return ok
+}
+
+// requireMember hides admins outside the organization
+func (t *tenant) requireMember(adminId int64) (*admin, error) {
+	a, ok := t.admins[adminId]
+	if !ok || !t.isMember(adminId) {
+		return nil, storage.ErrAdminNotFound
+	}
+	return a, nil
+}
+
+// requireSoleMember also refuses admins who belong to another organization, like the sqlite
+// storage
+func (t *tenant) requireSoleMember(adminId int64) (*admin, error) {
+	a, err := t.requireMember(adminId)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.members[adminId]) > 1 {
+		return nil, storage.ErrAdminShared
+	}
+	return a, nil
+}
+
+func (s *Store) adminTaken(username string, email string, except int64) bool {
+	for _, a := range s.admins {
+		if a.id != except && (a.username == username || a.email == email) {
+			return true
+		}
+	}
+	return false
+}
+
+// otherActiveMember reports whether anyone besides the admin could still log in to the organization
+func (t *tenant) otherActiveMember(adminId int64) bool {
+	for _, a := range t.admins {
+		if a.active && a.id != adminId && t.isMember(a.id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tenant) returnAdmin(a *admin) types.ReturnAdmin {
+	return types.ReturnAdmin{Id: a.id, Username: a.username, Email: a.email, Active: a.active}
+}
+
+func (t *tenant) GetAdmins() ([]types.ReturnAdmin, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	admins := []types.ReturnAdmin{}
+	for _, id := range sortedIds(t.admins) {
+		if t.isMember(id) {
+			admins = append(admins, t.returnAdmin(t.admins[id]))
+		}
+	}
+	return admins, nil
+}
+
+func (t *tenant) GetAdmin(id *int64) (*types.ReturnAdmin, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	a, err := t.requireMember(*id)
+	if err != nil {
+		return nil, err
+	}
+	admin := t.returnAdmin(a)
+	return &admin, nil
+}
+
+func (t *tenant) CreateAdmin(username *string, email *string, password *string) (int64, error) {
+	if username == nil || *username == "" || email == nil || *email == "" || password == nil || *password == "" {
+		return 0, fmt.Errorf("username, email and password are required")
+	}
+	if err := t.requireOrg(); err != nil {
+		return 0, err
+	}
+	trimmed := strings.TrimSpace(*email)
+	if err := t.policy.Validate("password", *password, trimmed, *username); err != nil {
+		return 0, err
+	}
+	hashedPassword, err := t.hasher.Hash(*password)
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.adminTaken(*username, trimmed, 0) {
+		return 0, storage.ErrAdminExists
+	}
+	id := t.nextId("Admin")
+	t.admins[id] = &admin{id: id, username: *username, email: trimmed, password: hashedPassword, active: true}
+	t.members[id] = map[int64]time.Time{t.orgId: time.Now()}
+	return id, nil
+}
+
+func (t *tenant) UpdateAdmin(id *int64, username *string, email *string) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if username == nil || *username == "" || email == nil || *email == "" {
+		return fmt.Errorf("username and email are required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, err := t.requireSoleMember(*id)
+	if err != nil {
+		return err
+	}
+	trimmed := strings.TrimSpace(*email)
+	if t.adminTaken(*username, trimmed, a.id) {
+		return storage.ErrAdminExists
+	}
+	a.username, a.email = *username, trimmed
+	return nil
+}
+
+// SetAdminActive refuses to deactivate the last active admin of the organization, like the
+// sqlite storage
+func (t *tenant) SetAdminActive(id *int64, active bool) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, err := t.requireSoleMember(*id)
+	if err != nil {
+		return err
+	}
+	if !active && !t.otherActiveMember(a.id) {
+		return storage.ErrLastActiveAdmin
+	}
+	a.active = active
+	return nil
+}
+
+func (t *tenant) SetAdminPassword(id *int64, password *string) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if password == nil || *password == "" {
+		return fmt.Errorf("password is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, err := t.requireSoleMember(*id)
+	if err != nil {
+		return err
+	}
+	if err = t.policy.Validate("password", *password, a.email, a.username); err != nil {
+		return err
+	}
+	hashedPassword, err := t.hasher.Hash(*password)
+	if err != nil {
+		return err
+	}
+	a.password = hashedPassword
+	return nil
+}
+
+func (t *tenant) DeleteAdmin(id *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, err := t.requireSoleMember(*id)
+	if err != nil {
+		return err
+	}
+	if a.active && !t.otherActiveMember(a.id) {
+		return storage.ErrLastActiveAdmin
+	}
+	delete(t.admins, a.id)
+	delete(t.members, a.id)
+	return nil
+}
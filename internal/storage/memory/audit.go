@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+func (s *Store) Audit(ctx context.Context) storage.AuditRepository {
+	return s.forOrg(ctx)
+}
+
+type auditEntry struct {
+	orgId int64
+	storage.AuditEntry
+}
+
+// snapshot returns the fields of a row of the organization that storage.Sqlite audits, with the
+// values its columns would hold. It's nil when there is no such row outside the trash.
+func (t *tenant) snapshot(table string, id int64) map[string]any {
+	if _, ok := t.name(table, id); !ok {
+		return nil
+	}
+	switch table {
+	case "Mentors":
+		m := t.mentors[id]
+		return map[string]any{"name": m.name, "email": m.email, "department": m.department}
+	case "Interns":
+		i := t.interns[id]
+		var mentorId any // NULL in the column
+		if i.mentorId != 0 {
+			mentorId = i.mentorId
+		}
+		return map[string]any{"name": i.name, "email": i.email, "status": i.status, "mentor_id": mentorId}
+	case "Projects":
+		p := t.projects[id]
+		return map[string]any{"name": p.name, "description": p.description, "status": p.status, "start_date": p.startDate, "end_date": p.endDate}
+	case "Assignments":
+		a := t.assignments[id]
+		return map[string]any{"intern_id": a.internId, "project_id": a.projectId, "progress": a.progress, "remarks": a.remarks}
+	}
+	panic(fmt.Sprintf("memory: no table %s", table))
+}
+
+// audit records what happened to the row since before was taken with snapshot and adds the
+// new state of interns and assignments to their history. No audit entry is added when none of its
+// fields changed.
+func (t *tenant) audit(table string, id int64, action string, before map[string]any) {
+	t.writeHistory(table, id)
+	changes, err := storage.AuditChanges(before, t.snapshot(table, id))
+	if err != nil {
+		panic(fmt.Sprintf("memory: can't encode the changes of %s %d: %v", table, id, err))
+	}
+	if changes == nil {
+		return
+	}
+	entry := storage.NewAuditEntry(t.ctx, table, id, action, changes)
+	entry.Id = t.nextId("AuditLog")
+	t.auditLog = append(t.auditLog, auditEntry{orgId: t.orgId, AuditEntry: entry})
+}
+
+func (t *tenant) GetAudit(filter *storage.AuditFilter) ([]storage.AuditEntry, error) {
+	if _, ok := storage.TrashTables[filter.Entity]; filter.Entity != "" && !ok {
+		return nil, storage.ErrNotAudited
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	entries := []storage.AuditEntry{}
+	for i := len(t.auditLog) - 1; i >= 0 && len(entries) < filter.PageSize(); i-- {
+		e := t.auditLog[i]
+		if e.orgId != t.orgId ||
+			(filter.Entity != "" && e.Entity != filter.Entity) ||
+			(filter.EntityId != 0 && e.EntityId != filter.EntityId) ||
+			(filter.ActorId != 0 && e.ActorId != filter.ActorId) ||
+			(filter.ActorRole != "" && e.ActorRole != filter.ActorRole) ||
+			(!filter.Since.IsZero() && e.CreatedAt.Unix() < filter.Since.Unix()) ||
+			(!filter.Until.IsZero() && e.CreatedAt.Unix() >= filter.Until.Unix()) {
+			continue
+		}
+		entries = append(entries, e.AuditEntry)
+	}
+	return entries, nil
+}
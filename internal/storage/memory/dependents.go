@@ -0,0 +1,251 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+func (s *Store) Deletions(ctx context.Context) storage.DeletionRepository {
+	return s.forOrg(ctx)
+}
+
+// deletedAt returns the deletedAt field of a row of the organization, nil when there is no such
+// row in or out of the trash
+func (t *tenant) deletedAt(table string, id int64) *int64 {
+	switch table {
+	case "Mentors":
+		if m, ok := t.mentors[id]; ok && m.orgId == t.orgId {
+			return &m.deletedAt
+		}
+	case "Interns":
+		if i, ok := t.interns[id]; ok && i.orgId == t.orgId {
+			return &i.deletedAt
+		}
+	case "Projects":
+		if p, ok := t.projects[id]; ok && p.orgId == t.orgId {
+			return &p.deletedAt
+		}
+	case "Assignments":
+		if a, ok := t.assignments[id]; ok && a.orgId == t.orgId {
+			return &a.deletedAt
+		}
+	}
+	return nil
+}
+
+// childIds lists the rows of the organization that point at the row through rel, in or out of
+// the trash, the way storage.Sqlite finds them through the foreign key
+func (t *tenant) childIds(rel storage.Relationship, id int64) []int64 {
+	var ids []int64
+	switch rel.Child + "." + rel.Column {
+	case "Interns.mentor_id":
+		for _, internId := range sortedIds(t.interns) {
+			if i := t.interns[internId]; i.orgId == t.orgId && i.mentorId == id {
+				ids = append(ids, i.id)
+			}
+		}
+	case "Assignments.intern_id", "Assignments.project_id":
+		for _, assignmentId := range sortedIds(t.assignments) {
+			a := t.assignments[assignmentId]
+			if a.orgId != t.orgId || (rel.Column == "intern_id" && a.internId != id) || (rel.Column == "project_id" && a.projectId != id) {
+				continue
+			}
+			ids = append(ids, a.id)
+		}
+	default:
+		panic(fmt.Sprintf("memory: no rows for relationship %s.%s", rel.Child, rel.Column))
+	}
+	return ids
+}
+
+// dependents lists the rows that point at the row through rel and were trashed at deletedAt, 0
+// lists the ones that aren't in the trash
+func (t *tenant) dependents(rel storage.Relationship, id int64, deletedAt int64) []storage.Dependent {
+	list := []storage.Dependent{}
+	for _, childId := range t.childIds(rel, id) {
+		if *t.deletedAt(rel.Child, childId) == deletedAt {
+			list = append(list, storage.Dependent{Entity: entityNames[rel.Child], Id: childId, Name: t.label(rel.Child, childId)})
+		}
+	}
+	return list
+}
+
+// parentId returns what a row points at through rel, 0 for nothing
+func (t *tenant) parentId(rel storage.Relationship, childId int64) int64 {
+	switch rel.Child + "." + rel.Column {
+	case "Interns.mentor_id":
+		return t.interns[childId].mentorId
+	case "Assignments.intern_id":
+		return t.assignments[childId].internId
+	case "Assignments.project_id":
+		return t.assignments[childId].projectId
+	}
+	panic(fmt.Sprintf("memory: no parent for %s.%s", rel.Child, rel.Column))
+}
+
+// repoint changes what a dependent found through rel points at, to is 0 for none
+func (t *tenant) repoint(rel storage.Relationship, dependentId int64, to int64) {
+	before := t.snapshot(rel.Child, dependentId)
+	*t.version(rel.Child, dependentId)++
+	switch rel.Child + "." + rel.Column {
+	case "Interns.mentor_id":
+		t.interns[dependentId].mentorId = to
+	case "Assignments.intern_id":
+		t.assignments[dependentId].internId = to
+	case "Assignments.project_id":
+		t.assignments[dependentId].projectId = to
+	default:
+		panic(fmt.Sprintf("memory: can't repoint %s.%s", rel.Child, rel.Column))
+	}
+	t.audit(rel.Child, dependentId, storage.AuditUpdate, before)
+}
+
+func (t *tenant) assignmentName(a *assignment) string {
+	var internName, projectName string
+	if i, ok := t.interns[a.internId]; ok {
+		internName = i.name
+	}
+	if p, ok := t.projects[a.projectId]; ok {
+		projectName = p.name
+	}
+	return internName + " on " + projectName
+}
+
+// label names a row in a dependents list, delete preview or the trash
+func (t *tenant) label(table string, id int64) string {
+	switch table {
+	case "Mentors":
+		return t.mentors[id].name
+	case "Interns":
+		return t.interns[id].name
+	case "Projects":
+		return t.projects[id].name
+	case "Assignments":
+		return t.assignmentName(t.assignments[id])
+	}
+	return ""
+}
+
+// name returns the name of a row of the organization, ok is false when there is no such row
+// outside the trash
+func (t *tenant) name(table string, id int64) (name string, ok bool) {
+	if deletedAt := t.deletedAt(table, id); deletedAt == nil || *deletedAt != 0 {
+		return "", false
+	}
+	return t.label(table, id), true
+}
+
+func (t *tenant) impact(table string, id int64, reassignTo *int64) (*storage.DeleteImpact, error) {
+	entity := entityNames[table]
+	name, ok := t.name(table, id)
+	if !ok {
+		return nil, fmt.Errorf("%w: no %s with id %d", storage.ErrRecordNotFound, entity, id)
+	}
+	if reassignTo != nil {
+		if *reassignTo == id {
+			return nil, storage.ErrReassignToSelf
+		}
+		if _, ok := t.name(table, *reassignTo); !ok {
+			return nil, fmt.Errorf("%w: no %s with id %d", storage.ErrReassignMissing, entity, *reassignTo)
+		}
+	}
+	impact := storage.NewDeleteImpact(entity, id, reassignTo)
+	impact.Name = name
+	t.collectImpact(impact, table, id)
+	return impact, nil
+}
+
+func (t *tenant) collectImpact(impact *storage.DeleteImpact, table string, id int64) {
+	for _, rel := range storage.Relationships {
+		if rel.Parent != table {
+			continue
+		}
+		for _, d := range t.dependents(rel, id, 0) {
+			impact.Add(rel, d)
+			if impact.ReassignTo == nil && rel.OnDelete == storage.Cascade {
+				t.collectImpact(impact, rel.Child, d.Id)
+			}
+		}
+	}
+}
+
+var entityNames = map[string]string{"Mentors": "mentor", "Interns": "intern", "Projects": "project", "Assignments": "assignment"}
+
+func (t *tenant) DeleteImpact(entity string, id int64, reassignTo *int64) (*storage.DeleteImpact, error) {
+	table, ok := storage.DeletableTables[entity]
+	if !ok {
+		return nil, storage.ErrInvalidEntity
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.impact(table, id, reassignTo)
+}
+
+func (t *tenant) DeleteReassigning(entity string, id int64, reassignTo int64, version *int64) error {
+	table, ok := storage.DeletableTables[entity]
+	if !ok {
+		return storage.ErrInvalidEntity
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.impact(table, id, &reassignTo); err != nil {
+		return err
+	}
+	if err := t.requireVersion(table, id, version); err != nil {
+		return err
+	}
+	for _, rel := range storage.Relationships {
+		if rel.Parent != table {
+			continue
+		}
+		for _, d := range t.dependents(rel, id, 0) {
+			t.repoint(rel, d.Id, reassignTo)
+		}
+	}
+	t.trash(table, id, time.Now().Unix())
+	return nil
+}
+
+// deleteRow refuses to delete a row restricting relationships still point at, like
+// storage.Sqlite, and otherwise moves it to the trash along with what cascades from it
+func (t *tenant) deleteRow(table string, id int64, version *int64) error {
+	impact, err := t.impact(table, id, nil)
+	if err != nil && version == nil {
+		// deleting a row that isn't there has always been a no-op
+		return nil
+	}
+	if err := t.requireVersion(table, id, version); err != nil {
+		return err
+	}
+	if len(impact.Blocked) > 0 {
+		return &storage.DependentsError{Entity: impact.Entity, Id: id, Dependents: impact.Blocked}
+	}
+	t.trash(table, id, time.Now().Unix())
+	return nil
+}
+
+// trash moves the row to the trash and applies storage.Relationships to its dependents, the
+// ones it cascades to are trashed at the same time
+func (t *tenant) trash(table string, id int64, now int64) {
+	before := t.snapshot(table, id)
+	*t.deletedAt(table, id) = now
+	*t.version(table, id)++
+	t.audit(table, id, storage.AuditDelete, before)
+
+	for _, rel := range storage.Relationships {
+		if rel.Parent != table {
+			continue
+		}
+		for _, d := range t.dependents(rel, id, 0) {
+			if rel.OnDelete == storage.SetNull {
+				t.repoint(rel, d.Id, 0)
+			} else {
+				t.trash(rel.Child, d.Id, now)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// internVersion and assignmentVersion are the states a row was in, like the rows of InternHistory
+// and AssignmentHistory. They are valid from validFrom until validTo in unix seconds, validTo is 0
+// while the state is the current one.
+
+type internVersion struct {
+	intern
+	validFrom, validTo int64
+}
+
+type assignmentVersion struct {
+	assignment
+	validFrom, validTo int64
+}
+
+func validAt(validFrom, validTo int64, asOf time.Time) bool {
+	return validFrom <= asOf.Unix() && (validTo == 0 || validTo > asOf.Unix())
+}
+
+// writeHistory closes the current state of an intern or assignment and adds the one it is in
+// now, unless it's gone or in the trash, like storage.Sqlite
+func (t *tenant) writeHistory(table string, id int64) {
+	now := time.Now().Unix()
+	_, live := t.name(table, id)
+	switch table {
+	case "Interns":
+		for _, v := range t.internHistory {
+			if v.id == id && v.orgId == t.orgId && v.validTo == 0 {
+				v.validTo = now
+			}
+		}
+		if live {
+			t.internHistory = append(t.internHistory, &internVersion{intern: *t.interns[id], validFrom: now})
+		}
+	case "Assignments":
+		for _, v := range t.assignmentHistory {
+			if v.id == id && v.orgId == t.orgId && v.validTo == 0 {
+				v.validTo = now
+			}
+		}
+		if live {
+			t.assignmentHistory = append(t.assignmentHistory, &assignmentVersion{assignment: *t.assignments[id], validFrom: now})
+		}
+	}
+}
+
+// internAt returns the state an intern of the organization was in at the time, nil when it
+// didn't exist then
+func (t *tenant) internAt(id int64, asOf time.Time) *internVersion {
+	for _, v := range t.internHistory {
+		if v.id == id && v.orgId == t.orgId && validAt(v.validFrom, v.validTo, asOf) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (t *tenant) GetInternsAsOf(scope *types.Scope, asOf time.Time) ([]types.ReturnIntern, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	interns := []types.ReturnIntern{}
+	for _, v := range t.internHistory {
+		if v.orgId != t.orgId || !validAt(v.validFrom, v.validTo, asOf) {
+			continue
+		}
+		if scope != nil && scope.MentorId != 0 {
+			if v.mentorId != scope.MentorId {
+				continue
+			}
+		} else if scope != nil && scope.InternId != 0 && v.id != scope.InternId {
+			continue
+		}
+		intern := types.ReturnIntern{ID: v.id, Name: v.name, Email: v.email, Status: v.status, Version: v.version}
+		if v.mentorId != 0 {
+			intern.MentorId = strconv.FormatInt(v.mentorId, 10)
+		}
+		// mentors keep no history, the name is the current one
+		if m, ok := t.mentors[v.mentorId]; ok {
+			intern.MentorName, intern.MentorEmail = m.name, m.email
+		}
+		interns = append(interns, intern)
+	}
+	sort.Slice(interns, func(i, j int) bool { return interns[i].ID < interns[j].ID })
+	return interns, nil
+}
+
+func (t *tenant) GetInternAsOf(id *int64, asOf time.Time) (*types.ReturnIntern, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	interns, err := t.GetInternsAsOf(&types.Scope{InternId: *id}, asOf)
+	if err != nil {
+		return nil, err
+	}
+	if len(interns) == 0 {
+		return nil, fmt.Errorf("%w: no intern with id %d at %s", storage.ErrRecordNotFound, *id, asOf.UTC().Format(time.RFC3339))
+	}
+	return &interns[0], nil
+}
+
+func (t *tenant) GetAssignmentsAsOf(scope *types.Scope, asOf time.Time) ([]types.ReturnAssignment, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	assignments := []types.ReturnAssignment{}
+	for _, v := range t.assignmentHistory {
+		if v.orgId != t.orgId || !validAt(v.validFrom, v.validTo, asOf) {
+			continue
+		}
+		i := t.internAt(v.internId, asOf)
+		if scope != nil && scope.MentorId != 0 {
+			if i == nil || i.mentorId != scope.MentorId {
+				continue
+			}
+		} else if scope != nil && scope.InternId != 0 && v.internId != scope.InternId {
+			continue
+		}
+		assignment := types.ReturnAssignment{Id: v.id, InternId: v.internId, ProjectId: v.projectId, Progress: v.progress, Remarks: v.remarks, Version: v.version}
+		if i != nil {
+			assignment.InternName = i.name
+		}
+		// projects keep no history, the name is the current one
+		if p, ok := t.projects[v.projectId]; ok {
+			assignment.ProjectName = p.name
+		}
+		assignments = append(assignments, assignment)
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].Id < assignments[j].Id })
+	return assignments, nil
+}
+
+func (t *tenant) GetAssignmentAsOf(id *int64, asOf time.Time) (*types.ReturnAssignment, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	assignments, err := t.GetAssignmentsAsOf(nil, asOf)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range assignments {
+		if a.Id == *id {
+			return &a, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no assignment with id %d at %s", storage.ErrRecordNotFound, *id, asOf.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,663 @@
+// Package memory keeps mentors, interns, projects, assignments and admins in maps instead of a
+// database. It behaves like storage.Sqlite for everything storage.Repositories covers, so
+// handlers can run against it without a database file. Nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/password"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// deletedAt is when a row went to the trash in unix seconds, like the deleted_at column, 0 while
+// it's not in the trash. version starts at 1 and goes up with every change, like the column.
+
+type mentor struct {
+	id, orgId               int64
+	name, email, department string
+	deletedAt, version      int64
+}
+
+type intern struct {
+	id, orgId   int64
+	name, email string
+	status      string
+	mentorId    int64
+	deletedAt   int64
+	version     int64
+}
+
+type project struct {
+	id, orgId                                     int64
+	name, description, status, startDate, endDate string
+	deletedAt, version                            int64
+}
+
+type assignment struct {
+	id, orgId           int64
+	internId, projectId int64
+	progress            int64
+	remarks             string
+	deletedAt, version  int64
+}
+
+type account struct {
+	activationHash string
+	expiresAt      time.Time
+}
+
+type accountKey struct {
+	role     string
+	entityId int64
+}
+
+// Store holds the rows of every organization, the repositories it hands out see one each
+type Store struct {
+	mu     sync.RWMutex
+	hasher *password.Hasher
+	policy *password.Policy
+	lastId map[string]int64
+
+	mentors     map[int64]*mentor
+	interns     map[int64]*intern
+	projects    map[int64]*project
+	assignments map[int64]*assignment
+	admins      map[int64]*admin
+	members     map[int64]map[int64]time.Time // admin id to the organizations they joined and when
+	accounts    map[accountKey]*account
+	auditLog    []auditEntry // oldest first
+
+	internHistory     []*internVersion
+	assignmentHistory []*assignmentVersion
+}
+
+var _ storage.Repositories = (*Store)(nil)
+
+// New returns an empty store, passwords of admins follow cfg like they do with sqlite
+func New(cfg *config.Password) (*Store, error) {
+	hasher, err := password.NewHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		hasher:      hasher,
+		policy:      password.NewPolicy(cfg),
+		lastId:      map[string]int64{},
+		mentors:     map[int64]*mentor{},
+		interns:     map[int64]*intern{},
+		projects:    map[int64]*project{},
+		assignments: map[int64]*assignment{},
+		admins:      map[int64]*admin{},
+		members:     map[int64]map[int64]time.Time{},
+		accounts:    map[accountKey]*account{},
+	}, nil
+}
+
+// tenant is the view of one organization, like a storage.Sqlite handle from Tenant. Its changes
+// are audited as made by the request of ctx.
+type tenant struct {
+	*Store
+	ctx   context.Context
+	orgId int64
+}
+
+func (s *Store) forOrg(ctx context.Context) *tenant {
+	claims, ok := jwt.ClaimsFromContext(ctx)
+	if !ok {
+		return &tenant{Store: s, ctx: ctx}
+	}
+	return &tenant{Store: s, ctx: ctx, orgId: claims.OrgID}
+}
+
+func (s *Store) Mentors(ctx context.Context) storage.MentorRepository {
+	return s.forOrg(ctx)
+}
+
+func (s *Store) Interns(ctx context.Context) storage.InternRepository {
+	return s.forOrg(ctx)
+}
+
+func (s *Store) Projects(ctx context.Context) storage.ProjectRepository {
+	return s.forOrg(ctx)
+}
+
+func (s *Store) Assignments(ctx context.Context) storage.AssignmentRepository {
+	return s.forOrg(ctx)
+}
+
+func (s *Store) Admins(ctx context.Context) storage.AdminRepository {
+	return s.forOrg(ctx)
+}
+
+func (s *Store) Accounts(ctx context.Context) storage.AccountRepository {
+	return s.forOrg(ctx)
+}
+
+// nextId hands out ids per table the way AUTOINCREMENT does, never reusing one
+func (s *Store) nextId(table string) int64 {
+	s.lastId[table]++
+	return s.lastId[table]
+}
+
+func (t *tenant) requireOrg() error {
+	if t.orgId == 0 {
+		return storage.ErrNoOrganization
+	}
+	return nil
+}
+
+// requireMentor, requireIntern and requireProject check that a row another row points at belongs
+// to the organization and isn't in the trash
+
+func (t *tenant) requireMentor(id int64) error {
+	if m, ok := t.mentors[id]; !ok || m.orgId != t.orgId || m.deletedAt != 0 {
+		return storage.InvalidReference("mentor", id)
+	}
+	return nil
+}
+
+func (t *tenant) requireIntern(id int64) error {
+	if i, ok := t.interns[id]; !ok || i.orgId != t.orgId || i.deletedAt != 0 {
+		return storage.InvalidReference("intern", id)
+	}
+	return nil
+}
+
+func (t *tenant) requireProject(id int64) error {
+	if p, ok := t.projects[id]; !ok || p.orgId != t.orgId || p.deletedAt != 0 {
+		return storage.InvalidReference("project", id)
+	}
+	return nil
+}
+
+// emails of live mentors and interns are unique within an organization, as in the sqlite schema,
+// trashed rows don't hold on to theirs
+func (t *tenant) mentorEmailTaken(email string, except int64) bool {
+	for _, m := range t.mentors {
+		if m.orgId == t.orgId && m.deletedAt == 0 && m.email == email && m.id != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tenant) internEmailTaken(email string, except int64) bool {
+	for _, i := range t.interns {
+		if i.orgId == t.orgId && i.deletedAt == 0 && i.email == email && i.id != except {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedIds[T any](rows map[int64]T) []int64 {
+	ids := make([]int64, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (t *tenant) AddMentor(name *string, email *string, department *string) (int64, error) {
+	if name == nil || email == nil || department == nil {
+		return 0, fmt.Errorf("field missing")
+	}
+	if err := t.requireOrg(); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.mentorEmailTaken(*email, 0) {
+		return 0, fmt.Errorf("a mentor with this email already exists")
+	}
+	id := t.nextId("Mentors")
+	t.mentors[id] = &mentor{id: id, orgId: t.orgId, name: *name, email: *email, department: *department, version: 1}
+	t.audit("Mentors", id, storage.AuditCreate, nil)
+	return id, nil
+}
+
+func (t *tenant) GetMentors(scope *types.Scope) ([]types.ReturnMentor, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var mentors []types.ReturnMentor
+	for _, id := range sortedIds(t.mentors) {
+		m := t.mentors[id]
+		if m.orgId != t.orgId || m.deletedAt != 0 || (scope != nil && scope.MentorId != 0 && m.id != scope.MentorId) {
+			continue
+		}
+		mentors = append(mentors, types.ReturnMentor{Id: m.id, Name: m.name, Email: m.email, Department: m.department, Version: m.version})
+	}
+	return mentors, nil
+}
+
+func (t *tenant) GetMentor(id *int64) (*types.ReturnMentor, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	mentors, err := t.GetMentors(&types.Scope{MentorId: *id})
+	if err != nil {
+		return nil, err
+	}
+	if len(mentors) == 0 || mentors[0].Id != *id {
+		return nil, fmt.Errorf("%w: no mentor with id %d", storage.ErrRecordNotFound, *id)
+	}
+	return &mentors[0], nil
+}
+
+func (t *tenant) UpdateMentor(id *int64, name *string, email *string, department *string, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if name == nil || email == nil || department == nil {
+		return fmt.Errorf("field missing")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.requireVersion("Mentors", *id, version); err != nil {
+		return err
+	}
+	m, ok := t.mentors[*id]
+	if !ok || m.orgId != t.orgId || m.deletedAt != 0 {
+		return nil
+	}
+	if t.mentorEmailTaken(*email, m.id) {
+		return fmt.Errorf("a mentor with this email already exists")
+	}
+	before := t.snapshot("Mentors", m.id)
+	m.name, m.email, m.department = *name, *email, *department
+	m.version++
+	t.audit("Mentors", m.id, storage.AuditUpdate, before)
+	return nil
+}
+
+func (t *tenant) DeleteMentor(id *int64, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.deleteRow("Mentors", *id, version)
+}
+
+func (t *tenant) AddIntern(name *string, email *string, mentorId *int64) (int64, error) {
+	if name == nil || email == nil || mentorId == nil {
+		return 0, fmt.Errorf("field empty")
+	}
+	if err := t.requireOrg(); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.requireMentor(*mentorId); err != nil {
+		return 0, err
+	}
+	if t.internEmailTaken(*email, 0) {
+		return 0, fmt.Errorf("an intern with this email already exists")
+	}
+	id := t.nextId("Interns")
+	t.interns[id] = &intern{id: id, orgId: t.orgId, name: *name, email: *email, status: "active", mentorId: *mentorId, version: 1}
+	t.audit("Interns", id, storage.AuditCreate, nil)
+	return id, nil
+}
+
+// GetInterns lists interns without a mentor too, with the mentor left blank like the sqlite query
+func (t *tenant) GetInterns(scope *types.Scope) ([]types.ReturnIntern, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var interns []types.ReturnIntern
+	for _, id := range sortedIds(t.interns) {
+		i := t.interns[id]
+		if i.orgId != t.orgId || i.deletedAt != 0 {
+			continue
+		}
+		if scope != nil && scope.MentorId != 0 {
+			if i.mentorId != scope.MentorId {
+				continue
+			}
+		} else if scope != nil && scope.InternId != 0 && i.id != scope.InternId {
+			continue
+		}
+		intern := types.ReturnIntern{
+			ID:      i.id,
+			Name:    i.name,
+			Email:   i.email,
+			Status:  i.status,
+			Version: i.version,
+		}
+		if i.mentorId != 0 {
+			intern.MentorId = strconv.FormatInt(i.mentorId, 10)
+		}
+		if m, ok := t.mentors[i.mentorId]; ok {
+			intern.MentorName, intern.MentorEmail = m.name, m.email
+		}
+		interns = append(interns, intern)
+	}
+	return interns, nil
+}
+
+func (t *tenant) GetIntern(id *int64) (*types.ReturnIntern, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	interns, err := t.GetInterns(&types.Scope{InternId: *id})
+	if err != nil {
+		return nil, err
+	}
+	if len(interns) == 0 || interns[0].ID != *id {
+		return nil, fmt.Errorf("%w: no intern with id %d", storage.ErrRecordNotFound, *id)
+	}
+	return &interns[0], nil
+}
+
+func (t *tenant) UpdateIntern(id *int64, name *string, email *string, mentorId *int64, status *string, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if name == nil || email == nil || status == nil {
+		return fmt.Errorf("field missing")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if mentorId != nil {
+		if err := t.requireMentor(*mentorId); err != nil {
+			return err
+		}
+	}
+	if err := t.requireVersion("Interns", *id, version); err != nil {
+		return err
+	}
+	i, ok := t.interns[*id]
+	if !ok || i.orgId != t.orgId || i.deletedAt != 0 {
+		return nil
+	}
+	if t.internEmailTaken(*email, i.id) {
+		return fmt.Errorf("an intern with this email already exists")
+	}
+	before := t.snapshot("Interns", i.id)
+	i.name, i.email, i.status = *name, *email, *status
+	i.mentorId = 0
+	if mentorId != nil {
+		i.mentorId = *mentorId
+	}
+	i.version++
+	t.audit("Interns", i.id, storage.AuditUpdate, before)
+	return nil
+}
+
+func (t *tenant) DeleteIntern(id *int64, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.deleteRow("Interns", *id, version)
+}
+
+func (t *tenant) AddProject(name *string, description *string, startDate *string, endDate *string) (int64, error) {
+	if name == nil || description == nil || startDate == nil || endDate == nil {
+		return 0, fmt.Errorf("field missing")
+	}
+	if err := t.requireOrg(); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := t.nextId("Projects")
+	t.projects[id] = &project{id: id, orgId: t.orgId, name: *name, description: *description, status: "ongoing", startDate: *startDate, endDate: *endDate, version: 1}
+	t.audit("Projects", id, storage.AuditCreate, nil)
+	return id, nil
+}
+
+func (t *tenant) GetProjects() ([]types.ReturnProject, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var projects []types.ReturnProject
+	for _, id := range sortedIds(t.projects) {
+		p := t.projects[id]
+		if p.orgId != t.orgId || p.deletedAt != 0 {
+			continue
+		}
+		projects = append(projects, types.ReturnProject{Id: p.id, Name: p.name, Description: p.description, Status: p.status, StartDate: p.startDate, EndDate: p.endDate, Version: p.version})
+	}
+	return projects, nil
+}
+
+func (t *tenant) GetProject(id *int64) (*types.ReturnProject, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	projects, err := t.GetProjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if p.Id == *id {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no project with id %d", storage.ErrRecordNotFound, *id)
+}
+
+func (t *tenant) UpdateProject(id *int64, name *string, description *string, status *string, startDate *string, endDate *string, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	if name == nil || description == nil || status == nil || startDate == nil || endDate == nil {
+		return fmt.Errorf("field missing")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.requireVersion("Projects", *id, version); err != nil {
+		return err
+	}
+	if p, ok := t.projects[*id]; ok && p.orgId == t.orgId && p.deletedAt == 0 {
+		before := t.snapshot("Projects", p.id)
+		p.name, p.description, p.status, p.startDate, p.endDate = *name, *description, *status, *startDate, *endDate
+		p.version++
+		t.audit("Projects", p.id, storage.AuditUpdate, before)
+	}
+	return nil
+}
+
+func (t *tenant) DeleteProject(id *int64, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.deleteRow("Projects", *id, version)
+}
+
+func (t *tenant) AddAssignment(internId *int64, projectId *int64, remarks *string) (int64, error) {
+	if internId == nil || projectId == nil || remarks == nil {
+		return 0, fmt.Errorf("missing field")
+	}
+	if err := t.requireOrg(); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.requireIntern(*internId); err != nil {
+		return 0, err
+	}
+	if err := t.requireProject(*projectId); err != nil {
+		return 0, err
+	}
+	id := t.nextId("Assignments")
+	t.assignments[id] = &assignment{id: id, orgId: t.orgId, internId: *internId, projectId: *projectId, remarks: *remarks, version: 1}
+	t.audit("Assignments", id, storage.AuditCreate, nil)
+	return id, nil
+}
+
+// GetAssignmets leaves out assignments whose intern or project is gone, like the sqlite joins
+func (t *tenant) GetAssignmets(scope *types.Scope) ([]types.ReturnAssignment, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var assignments []types.ReturnAssignment
+	for _, id := range sortedIds(t.assignments) {
+		a := t.assignments[id]
+		i, okIntern := t.interns[a.internId]
+		p, okProject := t.projects[a.projectId]
+		if a.orgId != t.orgId || a.deletedAt != 0 || !okIntern || !okProject {
+			continue
+		}
+		if scope != nil && scope.MentorId != 0 {
+			if i.mentorId != scope.MentorId {
+				continue
+			}
+		} else if scope != nil && scope.InternId != 0 && a.internId != scope.InternId {
+			continue
+		}
+		assignments = append(assignments, types.ReturnAssignment{
+			Id:          a.id,
+			InternId:    a.internId,
+			InternName:  i.name,
+			ProjectId:   a.projectId,
+			ProjectName: p.name,
+			Progress:    a.progress,
+			Remarks:     a.remarks,
+			Version:     a.version,
+		})
+	}
+	return assignments, nil
+}
+
+func (t *tenant) GetAssignment(id *int64) (*types.ReturnAssignment, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	assignments, err := t.GetAssignmets(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range assignments {
+		if a.Id == *id {
+			return &a, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no assignment with id %d", storage.ErrRecordNotFound, *id)
+}
+
+func (t *tenant) UpdateAssignment(id *int64, internId *int64, projectId *int64, progress *int64, remarks *string, version *int64) error {
+	if id == nil || internId == nil || projectId == nil {
+		return fmt.Errorf("missing field")
+	}
+	if progress == nil || remarks == nil {
+		return fmt.Errorf("missing field")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.requireIntern(*internId); err != nil {
+		return err
+	}
+	if err := t.requireProject(*projectId); err != nil {
+		return err
+	}
+	if err := t.requireVersion("Assignments", *id, version); err != nil {
+		return err
+	}
+	if a, ok := t.assignments[*id]; ok && a.orgId == t.orgId && a.deletedAt == 0 {
+		before := t.snapshot("Assignments", a.id)
+		a.internId, a.projectId, a.progress, a.remarks = *internId, *projectId, *progress, *remarks
+		a.version++
+		t.audit("Assignments", a.id, storage.AuditUpdate, before)
+	}
+	return nil
+}
+
+func (t *tenant) DeleteAssignment(id *int64, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.deleteRow("Assignments", *id, version)
+}
+
+// CreateAccount issues an activation token for a mentor or intern of the organization. The
+// store doesn't log anyone in, so accounts never get activated and asking again issues a new token.
+func (t *tenant) CreateAccount(role *string, entityId *int64, ttl time.Duration) (string, error) {
+	if role == nil || entityId == nil {
+		return "", fmt.Errorf("role and entity_id are required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch *role {
+	case types.RoleMentor:
+		if m, ok := t.mentors[*entityId]; !ok || m.orgId != t.orgId || m.deletedAt != 0 {
+			return "", fmt.Errorf("no %s found with id %d", *role, *entityId)
+		}
+	case types.RoleIntern:
+		if i, ok := t.interns[*entityId]; !ok || i.orgId != t.orgId || i.deletedAt != 0 {
+			return "", fmt.Errorf("no %s found with id %d", *role, *entityId)
+		}
+	default:
+		return "", fmt.Errorf("role must be mentor or intern")
+	}
+
+	token, err := jwt.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(token))
+	t.accounts[accountKey{role: *role, entityId: *entityId}] = &account{activationHash: hex.EncodeToString(sum[:]), expiresAt: time.Now().Add(ttl)}
+	return token, nil
+}
+
+// InternMentor and AssignmentOwner let rbac.Require check Own grants against the store
+
+func (s *Store) InternMentor(ctx context.Context, internId int64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.interns[internId]
+	if !ok || i.deletedAt != 0 {
+		return 0, sql.ErrNoRows
+	}
+	return i.mentorId, nil
+}
+
+func (s *Store) AssignmentOwner(ctx context.Context, assignmentId int64) (int64, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.assignments[assignmentId]
+	if !ok || a.deletedAt != 0 {
+		return 0, 0, sql.ErrNoRows
+	}
+	var mentorId int64
+	if i, ok := s.interns[a.internId]; ok {
+		mentorId = i.mentorId
+	}
+	return a.internId, mentorId, nil
+}
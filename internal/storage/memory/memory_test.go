@@ -0,0 +1,20 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/storage/memory"
+	"github.com/Aytaditya/slotwise/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Repositories {
+		store, err := memory.New(&config.Password{MinLength: 12, MaxLength: 72, Hash: "bcrypt", BcryptCost: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+var _ storage.TrashPurger = (*Store)(nil)
+
+func (s *Store) Trash(ctx context.Context) storage.TrashRepository {
+	return s.forOrg(ctx)
+}
+
+// purgeOrder has every table after the tables that point at it, as in storage.Sqlite
+var purgeOrder = []string{"Assignments", "Interns", "Projects", "Mentors"}
+
+// ids lists the ids of a table's rows in every organization
+func (s *Store) ids(table string) []int64 {
+	switch table {
+	case "Mentors":
+		return sortedIds(s.mentors)
+	case "Interns":
+		return sortedIds(s.interns)
+	case "Projects":
+		return sortedIds(s.projects)
+	case "Assignments":
+		return sortedIds(s.assignments)
+	}
+	panic(fmt.Sprintf("memory: no table %s", table))
+}
+
+// orgOf returns the organization a row belongs to
+func (s *Store) orgOf(table string, id int64) int64 {
+	switch table {
+	case "Mentors":
+		return s.mentors[id].orgId
+	case "Interns":
+		return s.interns[id].orgId
+	case "Projects":
+		return s.projects[id].orgId
+	case "Assignments":
+		return s.assignments[id].orgId
+	}
+	panic(fmt.Sprintf("memory: no table %s", table))
+}
+
+func (t *tenant) GetTrash(entity string) ([]storage.TrashedRecord, error) {
+	tables := purgeOrder
+	if entity != "" {
+		table, ok := storage.TrashTables[entity]
+		if !ok {
+			return nil, storage.ErrNotTrashable
+		}
+		tables = []string{table}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	list := []storage.TrashedRecord{}
+	for _, table := range tables {
+		for _, id := range t.ids(table) {
+			deletedAt := t.deletedAt(table, id)
+			if deletedAt == nil || *deletedAt == 0 {
+				continue
+			}
+			list = append(list, storage.TrashedRecord{Entity: entityNames[table], Id: id, Name: t.label(table, id), DeletedAt: time.Unix(*deletedAt, 0).UTC()})
+		}
+	}
+	storage.SortTrash(list)
+	return list, nil
+}
+
+func (t *tenant) Restore(entity string, id int64) ([]storage.Dependent, error) {
+	table, ok := storage.TrashTables[entity]
+	if !ok {
+		return nil, storage.ErrNotTrashable
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deletedAt := t.deletedAt(table, id)
+	if deletedAt == nil || *deletedAt == 0 {
+		return nil, fmt.Errorf("%w: no %s with id %d in the trash", storage.ErrRecordNotFound, entity, id)
+	}
+	if err := t.requireParents(table, id); err != nil {
+		return nil, err
+	}
+	if t.emailTaken(table, id) {
+		return nil, fmt.Errorf("%w: %s %d can't be restored", storage.ErrEmailTaken, entity, id)
+	}
+	return t.restore(table, id), nil
+}
+
+// emailTaken reports whether a live mentor or intern took the email of the trashed row
+func (t *tenant) emailTaken(table string, id int64) bool {
+	switch table {
+	case "Mentors":
+		return t.mentorEmailTaken(t.mentors[id].email, id)
+	case "Interns":
+		return t.internEmailTaken(t.interns[id].email, id)
+	}
+	return false
+}
+
+// restore takes the row out of the trash with the rows trashed along with it whose parents
+// aren't in the trash, and returns those rows
+func (t *tenant) restore(table string, id int64) []storage.Dependent {
+	deletedAt := t.deletedAt(table, id)
+	trashedAt := *deletedAt
+	*deletedAt = 0
+	*t.version(table, id)++
+	t.audit(table, id, storage.AuditRestore, nil)
+
+	restored := []storage.Dependent{}
+	for _, rel := range storage.Relationships {
+		if rel.Parent != table || rel.OnDelete != storage.Cascade {
+			continue
+		}
+		for _, child := range t.dependents(rel, id, trashedAt) {
+			if t.requireParents(rel.Child, child.Id) != nil {
+				continue
+			}
+			restored = append(append(restored, child), t.restore(rel.Child, child.Id)...)
+		}
+	}
+	return restored
+}
+
+// requireParents fails with a storage.RestoreError when a row the row points at is in the trash
+func (t *tenant) requireParents(table string, id int64) error {
+	var trashed []storage.Dependent
+	for _, rel := range storage.Relationships {
+		if rel.Child != table {
+			continue
+		}
+		parentId := t.parentId(rel, id)
+		if deletedAt := t.deletedAt(rel.Parent, parentId); deletedAt != nil && *deletedAt != 0 {
+			trashed = append(trashed, storage.Dependent{Entity: entityNames[rel.Parent], Id: parentId, Name: t.label(rel.Parent, parentId)})
+		}
+	}
+	if len(trashed) > 0 {
+		return &storage.RestoreError{Entity: entityNames[table], Id: id, Parents: trashed}
+	}
+	return nil
+}
+
+// PurgeTrash deletes what every organization trashed before the time, keeping rows that other
+// rows still point at like storage.Sqlite
+func (s *Store) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for _, table := range purgeOrder {
+		for _, id := range s.ids(table) {
+			t := &tenant{Store: s, ctx: ctx, orgId: s.orgOf(table, id)}
+			if deletedAt := *t.deletedAt(table, id); deletedAt == 0 || deletedAt >= before.Unix() || t.pointedAt(table, id) {
+				continue
+			}
+			t.remove(table, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// pointedAt reports whether any row, in the trash or not, points at the row
+func (t *tenant) pointedAt(table string, id int64) bool {
+	for _, rel := range storage.Relationships {
+		if rel.Parent == table && len(t.childIds(rel, id)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tenant) remove(table string, id int64) {
+	switch table {
+	case "Mentors":
+		delete(t.mentors, id)
+	case "Interns":
+		delete(t.interns, id)
+	case "Projects":
+		delete(t.projects, id)
+	case "Assignments":
+		delete(t.assignments, id)
+	}
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/Aytaditya/slotwise/internal/storage"
+)
+
+// version returns the version field of a row of the organization, nil when there is no such row
+// in or out of the trash
+func (t *tenant) version(table string, id int64) *int64 {
+	switch table {
+	case "Mentors":
+		if m, ok := t.mentors[id]; ok && m.orgId == t.orgId {
+			return &m.version
+		}
+	case "Interns":
+		if i, ok := t.interns[id]; ok && i.orgId == t.orgId {
+			return &i.version
+		}
+	case "Projects":
+		if p, ok := t.projects[id]; ok && p.orgId == t.orgId {
+			return &p.version
+		}
+	case "Assignments":
+		if a, ok := t.assignments[id]; ok && a.orgId == t.orgId {
+			return &a.version
+		}
+	}
+	return nil
+}
+
+// requireVersion fails like storage.Sqlite unless the row is out of the trash and at version,
+// nil accepts any
+func (t *tenant) requireVersion(table string, id int64, version *int64) error {
+	if version == nil {
+		return nil
+	}
+	if _, ok := t.name(table, id); !ok {
+		return fmt.Errorf("%w: no %s with id %d", storage.ErrRecordNotFound, entityNames[table], id)
+	}
+	if current := *t.version(table, id); current != *version {
+		return fmt.Errorf("%w: %s %d is at version %d, not %d", storage.ErrVersionMismatch, entityNames[table], id, current, *version)
+	}
+	return nil
+}
@@ -0,0 +1,554 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+)
+
+// migrations holds the schema changes in order, NNNN_name.up.sql applies one and
+// NNNN_name.down.sql reverts it. A change the backends need different SQL for comes as
+// NNNN_name.sqlite.up.sql and NNNN_name.postgres.up.sql instead. Applied migrations must never be
+// edited, add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var (
+	ErrMigrationModified = errors.New("an applied migration was edited")
+	ErrMigrationUnknown  = errors.New("the database has a migration this build doesn't know")
+	ErrMigrationsPending = errors.New("the database has pending migrations, run migrate up")
+	ErrMigrationLocked   = errors.New("another process is migrating the database")
+)
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(?:(sqlite|postgres)\.)?(up|down)\.sql$`)
+
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+// checksum covers both scripts, so editing the down script of an applied migration is caught too
+func (m *migration) checksum() string {
+	sum := sha256.Sum256([]byte(m.up + "\x00" + m.down))
+	return hex.EncodeToString(sum[:])
+}
+
+// legacyChecksum is what databases migrated before the down script was covered recorded
+func (m *migration) legacyChecksum() string {
+	sum := sha256.Sum256([]byte(m.up))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *migration) matches(checksum string) bool {
+	return checksum == m.checksum() || checksum == m.legacyChecksum()
+}
+
+// MigrationStatus is one migration as `migrate status` lists it, AppliedAt is nil while it's pending
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Modified  bool
+}
+
+// Migrator applies and reverts the embedded migrations, schema_migrations records which ones
+// ran and the checksum of their scripts at the time.
+type Migrator struct {
+	db         *sql.DB
+	postgres   bool
+	migrations []migration
+}
+
+// NewMigrator connects to the configured database without touching its schema
+func NewMigrator(cfg *config.Config) (*Migrator, error) {
+	db, err := openDB(&cfg.Database, cfg.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMigrator(db, cfg.Database.Driver == "postgres")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func newMigrator(db *sql.DB, postgres bool) (*Migrator, error) {
+	dialect := "sqlite"
+	if postgres {
+		dialect = "postgres"
+	}
+	list, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, postgres: postgres, migrations: list}, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// loadMigrations returns the scripts of the dialect, a script written for it wins over the shared one
+func loadMigrations(dialect string) ([]migration, error) {
+	files, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", file.Name())
+		}
+		if match[3] != "" && match[3] != dialect {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrations.ReadFile(path.Join("migrations", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{version: version, name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, mig.name, match[2])
+		}
+		script := &mig.down
+		if match[4] == "up" {
+			script = &mig.up
+		}
+		if *script == "" || match[3] != "" {
+			*script = string(content)
+		}
+	}
+
+	list := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.up == "" || mig.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", mig.version, mig.name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	return list, nil
+}
+
+// applied reads schema_migrations, creating it first on a new database
+func (m *Migrator) applied() (map[int]appliedMigration, error) {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := m.db.Query("SELECT version,name,checksum,applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	rows := map[int]appliedMigration{}
+	for result.Next() {
+		var a appliedMigration
+		if err := result.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		rows[a.version] = a
+	}
+	return rows, result.Err()
+}
+
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt int64
+}
+
+// verify refuses databases whose applied migrations differ from the embedded ones
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := map[int]*migration{}
+	for i := range m.migrations {
+		known[m.migrations[i].version] = &m.migrations[i]
+	}
+	for version, a := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: %04d_%s", ErrMigrationUnknown, version, a.name)
+		}
+		if !mig.matches(a.checksum) {
+			return fmt.Errorf("%w: %04d_%s", ErrMigrationModified, version, mig.name)
+		}
+	}
+	return nil
+}
+
+// upgradeChecksums replaces the up-only checksums of older databases, verify accepted them
+func (m *Migrator) upgradeChecksums(applied map[int]appliedMigration) error {
+	for i := range m.migrations {
+		mig := &m.migrations[i]
+		a, ok := applied[mig.version]
+		if !ok || a.checksum != mig.legacyChecksum() {
+			continue
+		}
+		_, err := m.db.Exec("UPDATE schema_migrations SET checksum=? WHERE version=? AND checksum=?", mig.checksum(), mig.version, a.checksum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationLockKey identifies the postgres advisory lock migrations hold
+const migrationLockKey = 0x736c6f74
+
+// migrationLockWait is how long a migration waits for another process to finish on sqlite, and
+// how old a lock row has to be before it's taken to be left behind by a crash
+const migrationLockWait = 5 * time.Minute
+
+// lock keeps other processes from migrating until unlock is called, so two replicas starting
+// at once don't both apply the same migration. Postgres takes an advisory lock, which ends with the
+// connection; sqlite inserts a row into schema_migrations_lock.
+func (m *Migrator) lock() (func(), error) {
+	ctx := context.Background()
+	if m.postgres {
+		conn, err := m.db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", migrationLockKey); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(ctx, "SELECT pg_advisory_unlock(?)", migrationLockKey)
+			conn.Close()
+		}, nil
+	}
+
+	_, err := m.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations_lock (id INTEGER PRIMARY KEY, locked_at INTEGER NOT NULL)")
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(migrationLockWait)
+	for {
+		now := time.Now()
+		// a lock older than the wait was left behind by a process that died while migrating
+		_, err := m.db.Exec("DELETE FROM schema_migrations_lock WHERE id=1 AND locked_at<?", now.Add(-migrationLockWait).Unix())
+		if err != nil {
+			return nil, err
+		}
+		res, err := m.db.Exec("INSERT INTO schema_migrations_lock (id,locked_at) VALUES (1,?) ON CONFLICT DO NOTHING", now.Unix())
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return func() { m.db.Exec("DELETE FROM schema_migrations_lock WHERE id=1") }, nil
+		}
+		if now.After(deadline) {
+			return nil, ErrMigrationLocked
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// Up applies every pending migration in order and returns the ones it ran
+func (m *Migrator) Up() ([]MigrationStatus, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	if err := m.upgradeChecksums(applied); err != nil {
+		return nil, err
+	}
+
+	ran := []MigrationStatus{}
+	for i := range m.migrations {
+		mig := &m.migrations[i]
+		if _, ok := applied[mig.version]; ok {
+			continue
+		}
+		now := time.Now()
+		if err := m.run(mig, mig.up, func(tx *sql.Tx) error {
+			if mig.version == 1 {
+				if err := upgradeLegacy(tx); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("INSERT INTO schema_migrations (version,name,checksum,applied_at) VALUES (?,?,?,?)",
+				mig.version, mig.name, mig.checksum(), now.Unix())
+			return err
+		}); err != nil {
+			return ran, err
+		}
+		ran = append(ran, MigrationStatus{Version: mig.version, Name: mig.name, AppliedAt: &now})
+	}
+
+	// signup and every handle created before organizations rely on the default organization
+	return ran, seedDefaultOrganization(m.db, m.postgres)
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the ones it reverted
+func (m *Migrator) Down(steps int) ([]MigrationStatus, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+
+	reverted := []MigrationStatus{}
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		mig := &m.migrations[i]
+		if _, ok := applied[mig.version]; !ok {
+			continue
+		}
+		if err := m.run(mig, mig.down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version=?", mig.version)
+			return err
+		}); err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, MigrationStatus{Version: mig.version, Name: mig.name})
+	}
+	return reverted, nil
+}
+
+// Status lists every known migration and whether it was applied, without changing anything
+// but creating schema_migrations. The error is the one Up would refuse to run with.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	list := []MigrationStatus{}
+	for i := range m.migrations {
+		mig := &m.migrations[i]
+		status := MigrationStatus{Version: mig.version, Name: mig.name}
+		if a, ok := applied[mig.version]; ok {
+			at := time.Unix(a.appliedAt, 0).UTC()
+			status.AppliedAt = &at
+			status.Modified = !mig.matches(a.checksum)
+		}
+		list = append(list, status)
+	}
+	return list, m.verify(applied)
+}
+
+// pending fails when the database isn't at the latest migration, for servers that don't migrate
+// on startup
+func (m *Migrator) pending() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+	if len(applied) < len(m.migrations) {
+		return ErrMigrationsPending
+	}
+	return nil
+}
+
+// run executes a script and its bookkeeping in one transaction, both backends roll back schema
+// changes with it
+func (m *Migrator) run(mig *migration, script string, record func(tx *sql.Tx) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !m.postgres {
+		// sqlite changes constraints by rebuilding the table, which only works with foreign keys off.
+		// They can't be switched inside a transaction, foreign_key_check catches what a script broke.
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys=OFF"); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys=ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", mig.version, mig.name, err)
+		}
+	}
+	if !m.postgres {
+		if err := foreignKeyCheck(tx); err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", mig.version, mig.name, err)
+		}
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// foreignKeyCheck fails when a row of the sqlite database points at a row that doesn't exist
+func foreignKeyCheck(tx *sql.Tx) error {
+	rows, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		return fmt.Errorf("row %d of %s points at a missing row of %s", rowid.Int64, table, parent)
+	}
+	return rows.Err()
+}
+
+// splitStatements cuts a script at the semicolons outside of quotes and drops -- comments, the
+// drivers only take one statement at a time
+func splitStatements(script string) []string {
+	var statements []string
+	var b strings.Builder
+	inString, inComment := false, false
+	flush := func() {
+		if statement := strings.TrimSpace(b.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case inComment:
+			if c == '\n' {
+				inComment = false
+				b.WriteByte(c)
+			}
+		case c == '\'':
+			inString = !inString
+			b.WriteByte(c)
+		case !inString && c == '-' && i+1 < len(script) && script[i+1] == '-':
+			inComment = true
+		case !inString && c == ';':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// upgradeLegacy adds the columns that databases created before migrations were tracked got at
+// startup. The first migration already has them in its CREATE TABLE statements, so on a new
+// database there is nothing to add.
+func upgradeLegacy(tx *sql.Tx) error {
+	columns := []struct{ table, column, definition string }{
+		{"Admin", "active", "INTEGER NOT NULL DEFAULT 1"},
+		{"Sessions", "org_id", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, table := range []string{"Mentors", "Interns", "Projects", "Assignments", "Invitations", "ApiKeys"} {
+		columns = append(columns, struct{ table, column, definition string }{table, "org_id", fmt.Sprintf("INTEGER NOT NULL DEFAULT %d", DefaultOrgID)})
+	}
+	for _, c := range columns {
+		if err := addColumn(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type schemaEditor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// addColumn adds a column to a table created by an older version, if it isn't there yet
+func addColumn(db schemaEditor, table string, column string, definition string) error {
+	// reading the columns of an empty result works the same on every backend
+	rows, err := db.Query("SELECT * FROM " + table + " LIMIT 0")
+	if err != nil {
+		return err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	for _, name := range columns {
+		if strings.EqualFold(name, column) {
+			return nil
+		}
+	}
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// seedDefaultOrganization creates the organization that data from before organizations, and
+// every admin that existed then, belongs to
+func seedDefaultOrganization(db *sql.DB, postgres bool) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM Organizations").Scan(&count); err != nil || count > 0 {
+		return err
+	}
+	now := time.Now().Unix()
+	_, err := db.Exec("INSERT INTO Organizations (id,name,created_at) VALUES (?,?,?)", DefaultOrgID, "Default", now)
+	if err != nil {
+		return err
+	}
+	if postgres {
+		// the explicit id doesn't advance the sequence, the next organization would collide
+		_, err = db.Exec("SELECT setval(pg_get_serial_sequence('organizations','id'), (SELECT MAX(id) FROM Organizations))")
+		if err != nil {
+			return err
+		}
+	}
+	// sqlite can only parse ON CONFLICT after a SELECT that has a WHERE clause
+	_, err = db.Exec(`INSERT INTO AdminOrganizations (admin_id,org_id,created_at) SELECT id,CAST(? AS BIGINT),CAST(? AS BIGINT) FROM Admin
+		WHERE true ON CONFLICT DO NOTHING`, DefaultOrgID, now)
+	return err
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+)
+
+func openMigrator(t *testing.T) *Migrator {
+	t.Helper()
+	db, err := openDB(&config.Database{}, filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMigrator(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMigrationChecksums(t *testing.T) {
+	m := openMigrator(t)
+	last := &m.migrations[len(m.migrations)-1]
+
+	// databases from before the down script was covered keep working, and get the new checksum
+	if _, err := m.db.Exec("UPDATE schema_migrations SET checksum=? WHERE version=?", last.legacyChecksum(), last.version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Status(); err != nil {
+		t.Fatalf("an up-only checksum was refused: %v", err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	var checksum string
+	if err := m.db.QueryRow("SELECT checksum FROM schema_migrations WHERE version=?", last.version).Scan(&checksum); err != nil {
+		t.Fatal(err)
+	}
+	if checksum != last.checksum() {
+		t.Fatalf("the up-only checksum wasn't replaced: %s", checksum)
+	}
+
+	last.down += "\n-- edited"
+	if _, err := m.Status(); !errors.Is(err, ErrMigrationModified) {
+		t.Fatalf("expected ErrMigrationModified after editing a down script, got %v", err)
+	}
+}
+
+func TestMigrationLock(t *testing.T) {
+	m := openMigrator(t)
+	unlock, err := m.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lockedAt int64
+	if err := m.db.QueryRow("SELECT locked_at FROM schema_migrations_lock WHERE id=1").Scan(&lockedAt); err != nil {
+		t.Fatalf("no lock row while migrating: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Up()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("a second migration ran while the first held the lock: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := m.db.QueryRow("SELECT locked_at FROM schema_migrations_lock").Scan(&lockedAt); err != sql.ErrNoRows {
+		t.Fatalf("the lock was kept after migrating: %v", err)
+	}
+
+	// a lock left behind by a process that died is taken over
+	if _, err := m.db.Exec("INSERT INTO schema_migrations_lock (id,locked_at) VALUES (1,?)", time.Now().Add(-2*migrationLockWait).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("a stale lock blocked the migration: %v", err)
+	}
+}
+
+func TestMigrationOrphanedRows(t *testing.T) {
+	m := openMigrator(t)
+	if _, err := m.Down(len(m.migrations) - 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows left behind by deletes from before foreign keys were enforced
+	conn, err := m.db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"PRAGMA foreign_keys=OFF",
+		"INSERT INTO Projects (id,name) VALUES (1,'Website')",
+		"INSERT INTO Interns (id,name,email,mentor_id) VALUES (1,'Alan','alan@example.com',9)",
+		"INSERT INTO Assignments (id,intern_id,project_id,remarks) VALUES (1,1,1,'kept'),(2,8,1,'lost intern'),(3,1,7,'lost project')",
+		"PRAGMA foreign_keys=ON",
+	} {
+		if _, err := conn.ExecContext(context.Background(), statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	var mentorId sql.NullInt64
+	if err := m.db.QueryRow("SELECT mentor_id FROM Interns WHERE id=1").Scan(&mentorId); err != nil || mentorId.Valid {
+		t.Fatalf("the intern should be kept without a mentor, got %v %v", mentorId, err)
+	}
+	var kept, orphaned int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM Assignments").Scan(&kept); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM OrphanedAssignments WHERE remarks LIKE 'lost%'").Scan(&orphaned); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 1 || orphaned != 2 {
+		t.Fatalf("expected 1 assignment kept and 2 moved aside, got %d and %d", kept, orphaned)
+	}
+}
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS AdminOrganizations;
+DROP TABLE IF EXISTS Organizations;
+DROP TABLE IF EXISTS Sessions;
+DROP TABLE IF EXISTS OIDCIdentities;
+DROP TABLE IF EXISTS OIDCStates;
+DROP TABLE IF EXISTS ApiKeys;
+DROP TABLE IF EXISTS RecoveryCodes;
+DROP TABLE IF EXISTS TwoFactor;
+DROP TABLE IF EXISTS LoginThrottles;
+DROP TABLE IF EXISTS LoginAttempts;
+DROP TABLE IF EXISTS PasswordResets;
+DROP TABLE IF EXISTS TokenCutoffs;
+DROP TABLE IF EXISTS Invitations;
+DROP TABLE IF EXISTS Accounts;
+DROP TABLE IF EXISTS RevokedTokens;
+DROP TABLE IF EXISTS RefreshTokens;
+DROP TABLE IF EXISTS Assignments;
+DROP TABLE IF EXISTS Projects;
+DROP TABLE IF EXISTS Interns;
+DROP TABLE IF EXISTS Mentors;
+DROP TABLE IF EXISTS Admin;
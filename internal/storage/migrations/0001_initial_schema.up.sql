@@ -0,0 +1,209 @@
+-- the schema as it was when migrations started being tracked. IF NOT EXISTS lets databases
+-- created before then adopt it, the columns they lack are added by the migrator.
+
+CREATE TABLE IF NOT EXISTS Admin (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	active INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS Mentors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	department TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS Interns (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	status TEXT DEFAULT 'active',
+	mentor_id INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY(mentor_id) REFERENCES mentors(id)
+);
+
+CREATE TABLE IF NOT EXISTS Projects (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	description TEXT,
+	status TEXT DEFAULT 'ongoing',
+	start_date TEXT,
+	end_date TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS Assignments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	intern_id INTEGER,
+	project_id INTEGER,
+	progress INTEGER DEFAULT 0,
+	remarks TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY (intern_id) REFERENCES interns(id),
+	FOREIGN KEY (project_id) REFERENCES projects(id)
+);
+
+-- refresh tokens are stored hashed, rotated tokens share a family_id
+CREATE TABLE IF NOT EXISTS RefreshTokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	role TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	replaced_at INTEGER,
+	revoked_at INTEGER
+);
+
+-- access tokens revoked before their expiry, keyed by jti
+CREATE TABLE IF NOT EXISTS RevokedTokens (
+	jti TEXT PRIMARY KEY,
+	expires_at INTEGER NOT NULL
+);
+
+-- login accounts for mentors and interns, the email comes from the linked row
+CREATE TABLE IF NOT EXISTS Accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	role TEXT NOT NULL CHECK (role IN ('mentor','intern')),
+	entity_id INTEGER NOT NULL,
+	password TEXT,
+	activation_hash TEXT UNIQUE,
+	activation_expires_at INTEGER,
+	created_at INTEGER NOT NULL,
+	UNIQUE (role, entity_id)
+);
+
+-- single use invitations, only the hash of the token is kept
+CREATE TABLE IF NOT EXISTS Invitations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL CHECK (role IN ('admin','mentor','intern')),
+	entity_id INTEGER,
+	token_hash TEXT NOT NULL UNIQUE,
+	invited_by INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	accepted_at INTEGER,
+	revoked_at INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1
+);
+
+-- access tokens issued before not_before are rejected, set when all sessions are ended
+CREATE TABLE IF NOT EXISTS TokenCutoffs (
+	role TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	not_before INTEGER NOT NULL,
+	PRIMARY KEY (role, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS PasswordResets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	role TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	used_at INTEGER
+);
+
+-- failed logins, kept for admins to review
+CREATE TABLE IF NOT EXISTS LoginAttempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+-- running failure counters per email ("email:...") and per ip ("ip:...")
+CREATE TABLE IF NOT EXISTS LoginThrottles (
+	key TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failure_at INTEGER NOT NULL,
+	next_attempt_at INTEGER NOT NULL,
+	locked_until INTEGER NOT NULL DEFAULT 0
+);
+
+-- an admin's TOTP secret, enabled_at stays NULL until the first code is confirmed
+CREATE TABLE IF NOT EXISTS TwoFactor (
+	admin_id INTEGER PRIMARY KEY,
+	secret TEXT NOT NULL,
+	enabled_at INTEGER,
+	last_step INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS RecoveryCodes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	admin_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL UNIQUE,
+	used_at INTEGER
+);
+
+-- scopes is a comma separated list such as "interns:read,assignments:write"
+CREATE TABLE IF NOT EXISTS ApiKeys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	created_by INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER,
+	last_used_at INTEGER,
+	revoked_at INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1
+);
+
+-- pending sso sign-ins, keyed by the hash of the state parameter
+CREATE TABLE IF NOT EXISTS OIDCStates (
+	state_hash TEXT PRIMARY KEY,
+	nonce TEXT NOT NULL,
+	code_verifier TEXT NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+
+-- links an identity provider subject to an admin or account
+CREATE TABLE IF NOT EXISTS OIDCIdentities (
+	issuer TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	role TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (issuer, subject)
+);
+
+-- one row per login, the id is the family_id of its refresh tokens
+CREATE TABLE IF NOT EXISTS Sessions (
+	id TEXT PRIMARY KEY,
+	role TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	user_agent TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	last_seen_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	revoked_at INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 0
+);
+
+-- business units, every mentor, intern, project and assignment belongs to exactly one
+CREATE TABLE IF NOT EXISTS Organizations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	created_at INTEGER NOT NULL
+);
+
+-- admins can work in several organizations and switch between them
+CREATE TABLE IF NOT EXISTS AdminOrganizations (
+	admin_id INTEGER NOT NULL,
+	org_id INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (admin_id, org_id)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE Interns DROP CONSTRAINT IF EXISTS interns_mentor_id_fkey;
+ALTER TABLE Interns ADD CONSTRAINT interns_mentor_id_fkey FOREIGN KEY (mentor_id) REFERENCES Mentors(id);
+
+ALTER TABLE Assignments DROP CONSTRAINT IF EXISTS assignments_intern_id_fkey;
+ALTER TABLE Assignments ADD CONSTRAINT assignments_intern_id_fkey FOREIGN KEY (intern_id) REFERENCES Interns(id);
+
+ALTER TABLE Assignments DROP CONSTRAINT IF EXISTS assignments_project_id_fkey;
+ALTER TABLE Assignments ADD CONSTRAINT assignments_project_id_fkey FOREIGN KEY (project_id) REFERENCES Projects(id);
@@ -0,0 +1,31 @@
+-- the foreign keys get the ON DELETE actions of storage.Relationships. Databases migrated
+-- before foreign keys were enforced have none yet, hence IF EXISTS.
+
+-- rows that point at rows deleted while foreign keys weren't enforced. Interns lose their mentor,
+-- assignments can't be kept without their intern or project so they're moved to
+-- OrphanedAssignments, which the down script leaves in place.
+UPDATE Interns SET mentor_id=NULL WHERE mentor_id NOT IN (SELECT id FROM Mentors);
+CREATE TABLE IF NOT EXISTS OrphanedAssignments (
+	id INTEGER PRIMARY KEY,
+	intern_id INTEGER,
+	project_id INTEGER,
+	progress INTEGER,
+	remarks TEXT,
+	org_id INTEGER NOT NULL
+);
+INSERT INTO OrphanedAssignments (id,intern_id,project_id,progress,remarks,org_id)
+	SELECT id,intern_id,project_id,progress,remarks,org_id FROM Assignments
+	WHERE intern_id NOT IN (SELECT id FROM Interns) OR project_id NOT IN (SELECT id FROM Projects);
+DELETE FROM Assignments WHERE intern_id NOT IN (SELECT id FROM Interns) OR project_id NOT IN (SELECT id FROM Projects);
+
+ALTER TABLE Interns DROP CONSTRAINT IF EXISTS interns_mentor_id_fkey;
+ALTER TABLE Interns ADD CONSTRAINT interns_mentor_id_fkey
+	FOREIGN KEY (mentor_id) REFERENCES Mentors(id) ON DELETE RESTRICT;
+
+ALTER TABLE Assignments DROP CONSTRAINT IF EXISTS assignments_intern_id_fkey;
+ALTER TABLE Assignments ADD CONSTRAINT assignments_intern_id_fkey
+	FOREIGN KEY (intern_id) REFERENCES Interns(id) ON DELETE CASCADE;
+
+ALTER TABLE Assignments DROP CONSTRAINT IF EXISTS assignments_project_id_fkey;
+ALTER TABLE Assignments ADD CONSTRAINT assignments_project_id_fkey
+	FOREIGN KEY (project_id) REFERENCES Projects(id) ON DELETE RESTRICT;
@@ -0,0 +1,30 @@
+CREATE TABLE Interns_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	status TEXT DEFAULT 'active',
+	mentor_id INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY(mentor_id) REFERENCES mentors(id)
+);
+INSERT INTO Interns_old (id,name,email,status,mentor_id,org_id) SELECT id,name,email,status,mentor_id,org_id FROM Interns;
+DELETE FROM sqlite_sequence WHERE name='Interns_old';
+UPDATE sqlite_sequence SET name='Interns_old' WHERE name='Interns';
+DROP TABLE Interns;
+ALTER TABLE Interns_old RENAME TO Interns;
+
+CREATE TABLE Assignments_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	intern_id INTEGER,
+	project_id INTEGER,
+	progress INTEGER DEFAULT 0,
+	remarks TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY (intern_id) REFERENCES interns(id),
+	FOREIGN KEY (project_id) REFERENCES projects(id)
+);
+INSERT INTO Assignments_old (id,intern_id,project_id,progress,remarks,org_id) SELECT id,intern_id,project_id,progress,remarks,org_id FROM Assignments;
+DELETE FROM sqlite_sequence WHERE name='Assignments_old';
+UPDATE sqlite_sequence SET name='Assignments_old' WHERE name='Assignments';
+DROP TABLE Assignments;
+ALTER TABLE Assignments_old RENAME TO Assignments;
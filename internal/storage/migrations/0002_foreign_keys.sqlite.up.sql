@@ -0,0 +1,51 @@
+-- the foreign keys get the ON DELETE actions of storage.Relationships. sqlite can't alter
+-- constraints, so Interns and Assignments are rebuilt.
+
+-- rows that point at rows deleted while foreign keys weren't enforced. Interns lose their mentor,
+-- assignments can't be kept without their intern or project so they're moved to
+-- OrphanedAssignments, which the down script leaves in place.
+UPDATE Interns SET mentor_id=NULL WHERE mentor_id NOT IN (SELECT id FROM Mentors);
+CREATE TABLE IF NOT EXISTS OrphanedAssignments (
+	id INTEGER PRIMARY KEY,
+	intern_id INTEGER,
+	project_id INTEGER,
+	progress INTEGER,
+	remarks TEXT,
+	org_id INTEGER NOT NULL
+);
+INSERT INTO OrphanedAssignments (id,intern_id,project_id,progress,remarks,org_id)
+	SELECT id,intern_id,project_id,progress,remarks,org_id FROM Assignments
+	WHERE intern_id NOT IN (SELECT id FROM Interns) OR project_id NOT IN (SELECT id FROM Projects);
+DELETE FROM Assignments WHERE intern_id NOT IN (SELECT id FROM Interns) OR project_id NOT IN (SELECT id FROM Projects);
+
+CREATE TABLE Interns_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	status TEXT DEFAULT 'active',
+	mentor_id INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY (mentor_id) REFERENCES Mentors(id) ON DELETE RESTRICT
+);
+INSERT INTO Interns_new (id,name,email,status,mentor_id,org_id) SELECT id,name,email,status,mentor_id,org_id FROM Interns;
+-- keeps the AUTOINCREMENT counter, ids of deleted rows aren't handed out again
+DELETE FROM sqlite_sequence WHERE name='Interns_new';
+UPDATE sqlite_sequence SET name='Interns_new' WHERE name='Interns';
+DROP TABLE Interns;
+ALTER TABLE Interns_new RENAME TO Interns;
+
+CREATE TABLE Assignments_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	intern_id INTEGER,
+	project_id INTEGER,
+	progress INTEGER DEFAULT 0,
+	remarks TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY (intern_id) REFERENCES Interns(id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES Projects(id) ON DELETE RESTRICT
+);
+INSERT INTO Assignments_new (id,intern_id,project_id,progress,remarks,org_id) SELECT id,intern_id,project_id,progress,remarks,org_id FROM Assignments;
+DELETE FROM sqlite_sequence WHERE name='Assignments_new';
+UPDATE sqlite_sequence SET name='Assignments_new' WHERE name='Assignments';
+DROP TABLE Assignments;
+ALTER TABLE Assignments_new RENAME TO Assignments;
@@ -0,0 +1,11 @@
+-- what is in the trash is deleted for good, it would come back otherwise. Rows go before the
+-- rows they point at.
+DELETE FROM Assignments WHERE deleted_at IS NOT NULL;
+DELETE FROM Interns WHERE deleted_at IS NOT NULL;
+DELETE FROM Projects WHERE deleted_at IS NOT NULL;
+DELETE FROM Mentors WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE Assignments DROP COLUMN deleted_at;
+ALTER TABLE Projects DROP COLUMN deleted_at;
+ALTER TABLE Interns DROP COLUMN deleted_at;
+ALTER TABLE Mentors DROP COLUMN deleted_at;
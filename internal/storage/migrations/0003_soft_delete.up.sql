@@ -0,0 +1,6 @@
+-- deleting a mentor, intern, project or assignment moves it to the trash, it stays restorable
+-- until trash.retention has passed. Rows with a deleted_at are left out everywhere else.
+ALTER TABLE Mentors ADD COLUMN deleted_at INTEGER;
+ALTER TABLE Interns ADD COLUMN deleted_at INTEGER;
+ALTER TABLE Projects ADD COLUMN deleted_at INTEGER;
+ALTER TABLE Assignments ADD COLUMN deleted_at INTEGER;
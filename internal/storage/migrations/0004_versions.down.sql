@@ -0,0 +1,4 @@
+ALTER TABLE Assignments DROP COLUMN version;
+ALTER TABLE Projects DROP COLUMN version;
+ALTER TABLE Interns DROP COLUMN version;
+ALTER TABLE Mentors DROP COLUMN version;
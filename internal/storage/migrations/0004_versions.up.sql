@@ -0,0 +1,6 @@
+-- every change to a mentor, intern, project or assignment increments its version, clients send
+-- the version they read in If-Match so they don't overwrite a change they haven't seen
+ALTER TABLE Mentors ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE Interns ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE Projects ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE Assignments ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,19 @@
+-- every create, update, delete and restore of a mentor, intern, project or assignment, with the
+-- fields it changed as {"field":{"old":...,"new":...}}. Rows are only ever added, an entry
+-- outlives the record it is about. actor_id is 0 for changes no one signed in made.
+CREATE TABLE IF NOT EXISTS AuditLog (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	org_id INTEGER NOT NULL,
+	entity TEXT NOT NULL,
+	entity_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	actor_id INTEGER NOT NULL DEFAULT 0,
+	actor_role TEXT NOT NULL DEFAULT '',
+	actor_email TEXT NOT NULL DEFAULT '',
+	request_id TEXT NOT NULL DEFAULT '',
+	changes TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity ON AuditLog (org_id, entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_created_at ON AuditLog (org_id, created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS assignment_history_org;
+DROP INDEX IF EXISTS assignment_history_assignment;
+DROP INDEX IF EXISTS intern_history_org;
+DROP INDEX IF EXISTS intern_history_intern;
+DROP TABLE IF EXISTS AssignmentHistory;
+DROP TABLE IF EXISTS InternHistory;
@@ -0,0 +1,39 @@
+-- every state an intern or assignment was in, valid from valid_from until valid_to (unix
+-- seconds, NULL while it's the current one). A change closes the current row and adds the new
+-- state, a delete only closes it. Existing records start their history now, nothing is known
+-- about them before.
+CREATE TABLE IF NOT EXISTS InternHistory (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	intern_id INTEGER NOT NULL,
+	org_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	status TEXT,
+	mentor_id INTEGER,
+	version INTEGER NOT NULL,
+	valid_from INTEGER NOT NULL,
+	valid_to INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS AssignmentHistory (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	assignment_id INTEGER NOT NULL,
+	org_id INTEGER NOT NULL,
+	intern_id INTEGER,
+	project_id INTEGER,
+	progress INTEGER,
+	remarks TEXT,
+	version INTEGER NOT NULL,
+	valid_from INTEGER NOT NULL,
+	valid_to INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS intern_history_intern ON InternHistory (intern_id, valid_from);
+CREATE INDEX IF NOT EXISTS intern_history_org ON InternHistory (org_id, valid_from);
+CREATE INDEX IF NOT EXISTS assignment_history_assignment ON AssignmentHistory (assignment_id, valid_from);
+CREATE INDEX IF NOT EXISTS assignment_history_org ON AssignmentHistory (org_id, valid_from);
+
+INSERT INTO InternHistory (intern_id,org_id,name,email,status,mentor_id,version,valid_from)
+	SELECT id,org_id,name,email,status,mentor_id,version,CAST(strftime('%s','now') AS INTEGER) FROM Interns WHERE deleted_at IS NULL;
+INSERT INTO AssignmentHistory (assignment_id,org_id,intern_id,project_id,progress,remarks,version,valid_from)
+	SELECT id,org_id,intern_id,project_id,progress,remarks,version,CAST(strftime('%s','now') AS INTEGER) FROM Assignments WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS org_invitations_admin;
+DROP TABLE IF EXISTS OrgInvitations;
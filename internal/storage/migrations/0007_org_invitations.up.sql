@@ -0,0 +1,14 @@
+-- admins only join another organization by accepting an invitation to it while signed in, an
+-- invitation names an existing admin and is used once
+CREATE TABLE IF NOT EXISTS OrgInvitations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	org_id INTEGER NOT NULL,
+	admin_id INTEGER NOT NULL,
+	invited_by INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	accepted_at INTEGER,
+	declined_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS org_invitations_admin ON OrgInvitations (admin_id);
@@ -0,0 +1,6 @@
+-- fails while two organizations share an email
+DROP INDEX IF EXISTS mentors_org_email;
+DROP INDEX IF EXISTS interns_org_email;
+
+ALTER TABLE Mentors ADD CONSTRAINT mentors_email_key UNIQUE (email);
+ALTER TABLE Interns ADD CONSTRAINT interns_email_key UNIQUE (email);
@@ -0,0 +1,7 @@
+-- mentor and intern emails are unique per organization instead of across all of them, so one
+-- organization can't keep another from adding a person
+ALTER TABLE Mentors DROP CONSTRAINT IF EXISTS mentors_email_key;
+ALTER TABLE Interns DROP CONSTRAINT IF EXISTS interns_email_key;
+
+CREATE UNIQUE INDEX mentors_org_email ON Mentors (org_id,email);
+CREATE UNIQUE INDEX interns_org_email ON Interns (org_id,email);
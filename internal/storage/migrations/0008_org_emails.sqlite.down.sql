@@ -0,0 +1,37 @@
+-- fails while two organizations share an email
+DROP INDEX IF EXISTS mentors_org_email;
+DROP INDEX IF EXISTS interns_org_email;
+
+CREATE TABLE Mentors_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	department TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	deleted_at INTEGER,
+	version INTEGER NOT NULL DEFAULT 1
+);
+INSERT INTO Mentors_old (id,name,email,department,org_id,deleted_at,version)
+	SELECT id,name,email,department,org_id,deleted_at,version FROM Mentors;
+DELETE FROM sqlite_sequence WHERE name='Mentors_old';
+UPDATE sqlite_sequence SET name='Mentors_old' WHERE name='Mentors';
+DROP TABLE Mentors;
+ALTER TABLE Mentors_old RENAME TO Mentors;
+
+CREATE TABLE Interns_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	status TEXT DEFAULT 'active',
+	mentor_id INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	deleted_at INTEGER,
+	version INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY (mentor_id) REFERENCES Mentors(id) ON DELETE RESTRICT
+);
+INSERT INTO Interns_old (id,name,email,status,mentor_id,org_id,deleted_at,version)
+	SELECT id,name,email,status,mentor_id,org_id,deleted_at,version FROM Interns;
+DELETE FROM sqlite_sequence WHERE name='Interns_old';
+UPDATE sqlite_sequence SET name='Interns_old' WHERE name='Interns';
+DROP TABLE Interns;
+ALTER TABLE Interns_old RENAME TO Interns;
@@ -0,0 +1,40 @@
+-- mentor and intern emails are unique per organization instead of across all of them, so one
+-- organization can't keep another from adding a person. sqlite can't drop the inline UNIQUE, so
+-- both tables are rebuilt.
+
+CREATE TABLE Mentors_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	department TEXT,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	deleted_at INTEGER,
+	version INTEGER NOT NULL DEFAULT 1
+);
+INSERT INTO Mentors_new (id,name,email,department,org_id,deleted_at,version)
+	SELECT id,name,email,department,org_id,deleted_at,version FROM Mentors;
+DELETE FROM sqlite_sequence WHERE name='Mentors_new';
+UPDATE sqlite_sequence SET name='Mentors_new' WHERE name='Mentors';
+DROP TABLE Mentors;
+ALTER TABLE Mentors_new RENAME TO Mentors;
+
+CREATE TABLE Interns_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	status TEXT DEFAULT 'active',
+	mentor_id INTEGER,
+	org_id INTEGER NOT NULL DEFAULT 1,
+	deleted_at INTEGER,
+	version INTEGER NOT NULL DEFAULT 1,
+	FOREIGN KEY (mentor_id) REFERENCES Mentors(id) ON DELETE RESTRICT
+);
+INSERT INTO Interns_new (id,name,email,status,mentor_id,org_id,deleted_at,version)
+	SELECT id,name,email,status,mentor_id,org_id,deleted_at,version FROM Interns;
+DELETE FROM sqlite_sequence WHERE name='Interns_new';
+UPDATE sqlite_sequence SET name='Interns_new' WHERE name='Interns';
+DROP TABLE Interns;
+ALTER TABLE Interns_new RENAME TO Interns;
+
+CREATE UNIQUE INDEX mentors_org_email ON Mentors (org_id,email);
+CREATE UNIQUE INDEX interns_org_email ON Interns (org_id,email);
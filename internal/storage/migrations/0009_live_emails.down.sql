@@ -0,0 +1,6 @@
+-- fails while a trashed row shares its email with a live one
+DROP INDEX IF EXISTS mentors_org_email;
+DROP INDEX IF EXISTS interns_org_email;
+
+CREATE UNIQUE INDEX mentors_org_email ON Mentors (org_id,email);
+CREATE UNIQUE INDEX interns_org_email ON Interns (org_id,email);
@@ -0,0 +1,7 @@
+-- only live mentors and interns hold on to their email, a trashed one's can be used again;
+-- restoring it is refused while the email is taken
+DROP INDEX IF EXISTS mentors_org_email;
+DROP INDEX IF EXISTS interns_org_email;
+
+CREATE UNIQUE INDEX mentors_org_email ON Mentors (org_id,email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX interns_org_email ON Interns (org_id,email) WHERE deleted_at IS NULL;
@@ -0,0 +1 @@
+UPDATE TokenCutoffs SET not_before=not_before/1000;
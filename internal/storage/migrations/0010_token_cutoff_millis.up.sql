@@ -0,0 +1,3 @@
+-- token cutoffs are compared with iat in milliseconds, so a token issued right after a password
+-- reset isn't taken for one issued before it
+UPDATE TokenCutoffs SET not_before=not_before*1000;
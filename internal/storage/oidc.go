@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var (
+	ErrInvalidOIDCState = errors.New("invalid or expired sign-in attempt, please start again")
+	ErrNoSSOAccount     = errors.New("no account that may use single sign-on matches this identity")
+)
+
+// SaveOIDCState remembers a started sso sign-in until the provider redirects back
+func (sq *Sqlite) SaveOIDCState(state string, nonce string, verifier string, ttl time.Duration) error {
+	now := time.Now().Unix()
+	if _, err := sq.DB.ExecContext(sq.ctx, "DELETE FROM OIDCStates WHERE expires_at<?", now); err != nil {
+		return err
+	}
+	_, err := sq.DB.ExecContext(sq.ctx, "INSERT INTO OIDCStates (state_hash,nonce,code_verifier,expires_at) VALUES (?,?,?,?)",
+		hashToken(state), nonce, verifier, now+int64(ttl.Seconds()))
+	return err
+}
+
+// ConsumeOIDCState returns the nonce and PKCE verifier of a sign-in; each state works once
+func (sq *Sqlite) ConsumeOIDCState(state string) (string, string, error) {
+	if state == "" {
+		return "", "", ErrInvalidOIDCState
+	}
+	var nonce, verifier string
+	err := sq.DB.QueryRowContext(sq.ctx, "DELETE FROM OIDCStates WHERE state_hash=? AND expires_at>=? RETURNING nonce,code_verifier",
+		hashToken(state), time.Now().Unix()).Scan(&nonce, &verifier)
+	if err == sql.ErrNoRows {
+		return "", "", ErrInvalidOIDCState
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return nonce, verifier, nil
+}
+
+// OIDCPrincipal maps a provider identity to a local admin or account. A known subject wins;
+// otherwise the verified email is matched against admins, then mentor and intern rows, and the
+// subject is linked so later email changes at the provider don't matter. Mentors and interns
+// without an account get one that can only be used through sso until a password is set.
+func (sq *Sqlite) OIDCPrincipal(issuer string, subject string, email string, emailVerified bool, roles []string) (*types.Principal, error) {
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var role string
+	var userId int64
+	err = tx.QueryRowContext(sq.ctx, "SELECT role,user_id FROM OIDCIdentities WHERE issuer=? AND subject=?", issuer, subject).Scan(&role, &userId)
+	if err == nil {
+		if !slices.Contains(roles, role) {
+			return nil, ErrNoSSOAccount
+		}
+		principal, err1 := findPrincipal(sq.ctx, tx, role, userId)
+		if err1 == sql.ErrNoRows {
+			return nil, ErrNoSSOAccount
+		}
+		return principal, err1
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if email == "" || !emailVerified {
+		return nil, ErrNoSSOAccount
+	}
+
+	principal := types.Principal{Email: email}
+	found := false
+	if slices.Contains(roles, types.RoleAdmin) {
+		err = tx.QueryRowContext(sq.ctx, "SELECT id,email FROM Admin WHERE LOWER(email)=LOWER(?) AND active=1", email).Scan(&principal.ID, &principal.Email)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			principal.Role = types.RoleAdmin
+			principal.OrgID, err = defaultOrg(sq.ctx, tx, principal.ID)
+			if err != nil {
+				return nil, err
+			}
+			found = true
+		}
+	}
+	for _, candidate := range []struct{ role, table string }{{types.RoleMentor, "Mentors"}, {types.RoleIntern, "Interns"}} {
+		if found || !slices.Contains(roles, candidate.role) {
+			continue
+		}
+		// emails are only unique per organization, one used by several of them isn't linked as it's
+		// unclear which organization the login is for
+		err = tx.QueryRowContext(sq.ctx, `SELECT r.id,r.email,r.org_id FROM `+candidate.table+` as r WHERE LOWER(r.email)=LOWER(?) AND r.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM `+candidate.table+` as o WHERE LOWER(o.email)=LOWER(r.email) AND o.deleted_at IS NULL AND o.id<>r.id)`,
+			email).Scan(&principal.EntityID, &principal.Email, &principal.OrgID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = tx.QueryRowContext(sq.ctx, `INSERT INTO Accounts (role,entity_id,created_at) VALUES (?,?,?)
+			ON CONFLICT (role,entity_id) DO UPDATE SET role=excluded.role RETURNING id`,
+			candidate.role, principal.EntityID, time.Now().Unix()).Scan(&principal.ID)
+		if err != nil {
+			return nil, err
+		}
+		principal.Role = candidate.role
+		found = true
+	}
+	if !found {
+		return nil, ErrNoSSOAccount
+	}
+
+	_, err = tx.ExecContext(sq.ctx, "INSERT INTO OIDCIdentities (issuer,subject,role,user_id,created_at) VALUES (?,?,?,?,?)",
+		issuer, subject, principal.Role, principal.ID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/password"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// DefaultOrgID owns everything created before organizations existed, first admins join it
+const DefaultOrgID = 1
+
+var (
+	ErrNoOrganization = errors.New("no organization selected, create one or switch to one you belong to")
+	ErrOrgExists      = errors.New("an organization with this name already exists")
+	ErrNotOrgMember   = errors.New("you are not a member of this organization")
+	ErrMemberNotFound = errors.New("no admin with the given id belongs to this organization")
+
+	ErrAlreadyOrgMember      = errors.New("the admin already belongs to this organization")
+	ErrOrgInvitationNotFound = errors.New("no pending invitation of yours found with the given id")
+)
+
+// ForOrg returns a handle that only sees and writes rows of the organization
+func (sq *Sqlite) ForOrg(orgId int64) *Sqlite {
+	return &Sqlite{DB: sq.DB, ctx: sq.ctx, queryTimeout: sq.queryTimeout, orgId: orgId, hasher: sq.hasher, policy: sq.policy, postgres: sq.postgres}
+}
+
+// WithContext returns a handle of the same organization whose statements run under ctx, and
+// give up once database.query_timeout has passed
+func (sq *Sqlite) WithContext(ctx context.Context) *Sqlite {
+	handle := sq.ForOrg(sq.orgId)
+	handle.ctx = ctx
+	if sq.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		handle.ctx, cancel = context.WithTimeout(ctx, sq.queryTimeout)
+		// the request context is done once the handler returns, which frees the timer early
+		context.AfterFunc(ctx, cancel)
+	}
+	return handle
+}
+
+// Tenant returns a handle for the organization of the authenticated request, running its
+// statements under the request context
+func (sq *Sqlite) Tenant(ctx context.Context) *Sqlite {
+	claims, ok := jwt.ClaimsFromContext(ctx)
+	if !ok {
+		return sq.ForOrg(0).WithContext(ctx)
+	}
+	return sq.ForOrg(claims.OrgID).WithContext(ctx)
+}
+
+// requireOrg stops writes through a handle without an organization
+func (sq *Sqlite) requireOrg() error {
+	if sq.orgId == 0 {
+		return ErrNoOrganization
+	}
+	return nil
+}
+
+// requireInOrg checks that a row another row points at belongs to the same organization and
+// isn't in the trash
+func (sq *Sqlite) requireInOrg(table string, id int64) error {
+	var found int
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT 1 FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", id, sq.orgId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return InvalidReference(strings.ToLower(strings.TrimSuffix(table, "s")), id)
+	}
+	return err
+}
+
+// InvalidReference is the validation error for an <entity>_id field naming a row the organization
+// doesn't have, so it answers 400 like any other bad field
+func InvalidReference(entity string, id int64) error {
+	return &password.ValidationError{Fields: map[string][]string{
+		entity + "_id": {fmt.Sprintf("no %s found with id %d", entity, id)},
+	}}
+}
+
+// defaultOrg is the organization an admin starts in after logging in
+func defaultOrg(ctx context.Context, db querier, adminId int64) (int64, error) {
+	var orgId int64
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MIN(org_id),0) FROM AdminOrganizations WHERE admin_id=?", adminId).Scan(&orgId)
+	return orgId, err
+}
+
+func isOrgMember(ctx context.Context, db querier, adminId int64, orgId int64) (bool, error) {
+	var found int
+	err := db.QueryRowContext(ctx, "SELECT 1 FROM AdminOrganizations WHERE admin_id=? AND org_id=?", adminId, orgId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetOrganizations lists the organizations the admin belongs to
+func (sq *Sqlite) GetOrganizations(adminId int64) ([]types.ReturnOrganization, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT o.id,o.name,o.created_at FROM Organizations as o
+		INNER JOIN AdminOrganizations as m on m.org_id=o.id WHERE m.admin_id=? ORDER BY o.id`, adminId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []types.ReturnOrganization{}
+	for rows.Next() {
+		var org types.ReturnOrganization
+		var createdAt int64
+		err1 := rows.Scan(&org.Id, &org.Name, &createdAt)
+		if err1 != nil {
+			return nil, err1
+		}
+		org.Current = org.Id == sq.orgId
+		org.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// CreateOrganization adds an organization with the admin creating it as its first member
+func (sq *Sqlite) CreateOrganization(name *string, adminId int64) (int64, error) {
+	if name == nil || strings.TrimSpace(*name) == "" {
+		return 0, fmt.Errorf("name is required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	var id int64
+	err = tx.QueryRowContext(sq.ctx, "INSERT INTO Organizations (name,created_at) VALUES (?,?) RETURNING id", strings.TrimSpace(*name), now).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, ErrOrgExists
+	}
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(sq.ctx, "INSERT INTO AdminOrganizations (admin_id,org_id,created_at) VALUES (?,?,?)", adminId, id, now)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// SwitchOrganization moves the admin's session to another organization they belong to, later
+// refreshes of the session stay there
+func (sq *Sqlite) SwitchOrganization(principal *types.Principal, orgId *int64) error {
+	if principal == nil || principal.Role != types.RoleAdmin {
+		return ErrNotOrgMember
+	}
+	if orgId == nil || *orgId == 0 {
+		return fmt.Errorf("org_id is required")
+	}
+	member, err := isOrgMember(sq.ctx, sq.DB, principal.ID, *orgId)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrNotOrgMember
+	}
+	if principal.SessionID != "" {
+		_, err = sq.DB.ExecContext(sq.ctx, "UPDATE Sessions SET org_id=? WHERE id=?", orgId, principal.SessionID)
+		if err != nil {
+			return err
+		}
+	}
+	principal.OrgID = *orgId
+	return nil
+}
+
+// InviteOrgMember invites an existing admin into the handle's organization, they only become a
+// member once they accept it with AcceptOrgInvitation
+func (sq *Sqlite) InviteOrgMember(adminId *int64, invitedBy int64, ttl time.Duration) (int64, error) {
+	if err := sq.requireOrg(); err != nil {
+		return 0, err
+	}
+	if adminId == nil || *adminId == 0 {
+		return 0, fmt.Errorf("admin_id is required")
+	}
+	var found int
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT 1 FROM Admin WHERE id=?", adminId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return 0, ErrAdminNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	member, err := isOrgMember(sq.ctx, sq.DB, *adminId, sq.orgId)
+	if err != nil {
+		return 0, err
+	}
+	if member {
+		return 0, ErrAlreadyOrgMember
+	}
+
+	now := time.Now()
+	var id int64
+	err = sq.DB.QueryRowContext(sq.ctx, `INSERT INTO OrgInvitations (org_id,admin_id,invited_by,created_at,expires_at) VALUES (?,?,?,?,?) RETURNING id`,
+		sq.orgId, adminId, invitedBy, now.Unix(), now.Add(ttl).Unix()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetOrgInvitations lists the pending invitations of the admin into other organizations
+func (sq *Sqlite) GetOrgInvitations(adminId int64) ([]types.ReturnOrgInvitation, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT i.id,i.org_id,o.name,COALESCE(a.email,''),i.created_at,i.expires_at FROM OrgInvitations as i
+		INNER JOIN Organizations as o on o.id=i.org_id LEFT JOIN Admin as a on a.id=i.invited_by
+		WHERE i.admin_id=? AND i.accepted_at IS NULL AND i.declined_at IS NULL AND i.expires_at>=? ORDER BY i.id`, adminId, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []types.ReturnOrgInvitation{}
+	for rows.Next() {
+		var inv types.ReturnOrgInvitation
+		var createdAt, expiresAt int64
+		err1 := rows.Scan(&inv.Id, &inv.OrgId, &inv.OrgName, &inv.InvitedBy, &createdAt, &expiresAt)
+		if err1 != nil {
+			return nil, err1
+		}
+		inv.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
+		inv.ExpiresAt = time.Unix(expiresAt, 0).UTC().Format(time.RFC3339)
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// AcceptOrgInvitation makes the admin a member of the organization a pending invitation of
+// theirs is for, and returns its id
+func (sq *Sqlite) AcceptOrgInvitation(adminId int64, id *int64) (int64, error) {
+	if id == nil {
+		return 0, fmt.Errorf("id is required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	var orgId int64
+	err = tx.QueryRowContext(sq.ctx, `UPDATE OrgInvitations SET accepted_at=? WHERE id=? AND admin_id=?
+		AND accepted_at IS NULL AND declined_at IS NULL AND expires_at>=? RETURNING org_id`, now, id, adminId, now).Scan(&orgId)
+	if err == sql.ErrNoRows {
+		return 0, ErrOrgInvitationNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(sq.ctx, "INSERT INTO AdminOrganizations (admin_id,org_id,created_at) VALUES (?,?,?) ON CONFLICT DO NOTHING", adminId, orgId, now)
+	if err != nil {
+		return 0, err
+	}
+	return orgId, tx.Commit()
+}
+
+// DeclineOrgInvitation turns down a pending invitation of the admin
+func (sq *Sqlite) DeclineOrgInvitation(adminId int64, id *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
+	}
+	now := time.Now().Unix()
+	res, err := sq.DB.ExecContext(sq.ctx, `UPDATE OrgInvitations SET declined_at=? WHERE id=? AND admin_id=?
+		AND accepted_at IS NULL AND declined_at IS NULL AND expires_at>=?`, now, id, adminId, now)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOrgInvitationNotFound
+	}
+	return nil
+}
+
+// RemoveOrgMember takes an admin out of the handle's organization, their tokens for it stop
+// working right away. The last active admin of the organization can't be removed.
+func (sq *Sqlite) RemoveOrgMember(adminId *int64) error {
+	if err := sq.requireOrg(); err != nil {
+		return err
+	}
+	if adminId == nil {
+		return fmt.Errorf("admin_id is required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = sq.lockOrgAdmins(tx); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(sq.ctx, `DELETE FROM AdminOrganizations WHERE admin_id=? AND org_id=?
+		AND (EXISTS (SELECT 1 FROM Admin WHERE id=? AND active=0) OR `+otherActiveMember+`)`, adminId, sq.orgId, adminId, sq.orgId, adminId)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return tx.Commit()
+	}
+	member, err := isOrgMember(sq.ctx, tx, *adminId, sq.orgId)
+	if err != nil {
+		return err
+	}
+	if member {
+		return ErrLastActiveAdmin
+	}
+	return ErrMemberNotFound
+}
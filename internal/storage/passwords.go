@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordReset issues a reset token for the user with the email. It returns a nil
+// principal and no error when nobody has that email so callers can answer identically.
+func (sq *Sqlite) CreatePasswordReset(email *string, ttl time.Duration) (*types.Principal, string, error) {
+	if email == nil || *email == "" {
+		return nil, "", fmt.Errorf("email is required")
+	}
+
+	candidates, err := sq.principalsByEmail(*email)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(candidates) == 0 {
+		return nil, "", nil
+	}
+	// the link resets the admin or the oldest account of the email
+	principal := &candidates[0].principal
+
+	token, err := jwt.NewTokenID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	// only the most recent link works
+	now := time.Now()
+	_, err = tx.ExecContext(sq.ctx, "UPDATE PasswordResets SET used_at=? WHERE role=? AND user_id=? AND used_at IS NULL", now.Unix(), principal.Role, principal.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tx.ExecContext(sq.ctx, "INSERT INTO PasswordResets (role,user_id,token_hash,created_at,expires_at) VALUES (?,?,?,?,?)",
+		principal.Role, principal.ID, hashToken(token), now.Unix(), now.Add(ttl).Unix())
+	if err != nil {
+		return nil, "", err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return principal, token, nil
+}
+
+// ResetPassword consumes the reset token, sets the new password and ends every existing session
+func (sq *Sqlite) ResetPassword(token *string, password *string) error {
+	if token == nil || *token == "" {
+		return ErrInvalidResetToken
+	}
+	if password == nil || *password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id, userId int64
+	var role string
+	err = tx.QueryRowContext(sq.ctx, "SELECT id,role,user_id FROM PasswordResets WHERE token_hash=? AND used_at IS NULL AND expires_at>=?",
+		hashToken(*token), time.Now().Unix()).Scan(&id, &role, &userId)
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := sq.hashUserPassword(tx, role, userId, "password", *password)
+	if err != nil {
+		return err
+	}
+
+	if role == types.RoleAdmin {
+		_, err = tx.ExecContext(sq.ctx, "UPDATE Admin SET password=? WHERE id=?", hashedPassword, userId)
+	} else {
+		_, err = tx.ExecContext(sq.ctx, "UPDATE Accounts SET password=? WHERE id=?", hashedPassword, userId)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(sq.ctx, "UPDATE PasswordResets SET used_at=? WHERE id=?", time.Now().Unix(), id)
+	if err != nil {
+		return err
+	}
+	if err = revokeAllTokens(sq.ctx, tx, role, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashNewPassword checks a password against the policy and hashes it. field is the request
+// field it came in, for the validation error.
+func (sq *Sqlite) hashNewPassword(field string, password string, email string, username string) (string, error) {
+	if err := sq.policy.Validate(field, password, email, username); err != nil {
+		return "", err
+	}
+	return sq.hasher.Hash(password)
+}
+
+// hashUserPassword is hashNewPassword for an existing admin or account
+func (sq *Sqlite) hashUserPassword(db querier, role string, userId int64, field string, password string) (string, error) {
+	var email, username string
+	var err error
+	if role == types.RoleAdmin {
+		err = db.QueryRowContext(sq.ctx, "SELECT email,username FROM Admin WHERE id=?", userId).Scan(&email, &username)
+	} else {
+		err = db.QueryRowContext(sq.ctx, `SELECT COALESCE(m.email,i.email,'') FROM Accounts as a
+			LEFT JOIN Mentors as m on a.role='mentor' AND m.id=a.entity_id
+			LEFT JOIN Interns as i on a.role='intern' AND i.id=a.entity_id
+			WHERE a.id=?`, userId).Scan(&email)
+	}
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return sq.hashNewPassword(field, password, email, username)
+}
+
+// rehash stores the password again with the configured algorithm and cost. It skips the policy,
+// the password is already in use and a stricter policy only applies to the next change.
+func (sq *Sqlite) rehash(principal *types.Principal, password string) error {
+	hashedPassword, err := sq.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	table := "Accounts"
+	if principal.Role == types.RoleAdmin {
+		table = "Admin"
+	}
+	_, err = sq.DB.ExecContext(sq.ctx, "UPDATE "+table+" SET password=? WHERE id=?", hashedPassword, principal.ID)
+	return err
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// The queries of this package are written once, with ? placeholders and SQLite column types.
+// postgresConnector hands Postgres connections that rewrite them on the way in: placeholders
+// become $1, $2, ... and the column types of CREATE TABLE and ALTER TABLE become Postgres ones.
+// Migrations whose SQL can't be shared this way come in a postgres variant of their own.
+type postgresConnector struct {
+	*pq.Connector
+}
+
+func newPostgresConnector(dsn string) (driver.Connector, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConnector{connector}, nil
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresConn{conn}, nil
+}
+
+// postgresConn passes everything to lib/pq's connection after rewriting the query
+type postgresConn struct {
+	driver.Conn
+}
+
+func (c *postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(toPostgres(query))
+}
+
+func (c *postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, toPostgres(query))
+}
+
+func (c *postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, toPostgres(query), args)
+	return rows, canceled(ctx, err)
+}
+
+func (c *postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, toPostgres(query), args)
+	return result, canceled(ctx, err)
+}
+
+// canceled adds ctx.Err() to the error of a statement that failed because ctx ended. lib/pq
+// reports those as a canceled statement while go-sqlite3 returns ctx.Err(), this way callers
+// can check for context.DeadlineExceeded and context.Canceled on both.
+func canceled(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return errors.Join(ctx.Err(), err)
+	}
+	return err
+}
+
+func (c *postgresConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *postgresConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *postgresConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *postgresConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+var (
+	autoincrement = regexp.MustCompile(`(?i)\bINTEGER PRIMARY KEY AUTOINCREMENT\b`)
+	integerType   = regexp.MustCompile(`\bINTEGER\b`)
+)
+
+// toPostgres numbers the placeholders of a query and translates the schema statements
+func toPostgres(query string) string {
+	trimmed := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(trimmed, "CREATE TABLE") || strings.HasPrefix(trimmed, "ALTER TABLE") {
+		query = autoincrement.ReplaceAllString(query, "BIGSERIAL PRIMARY KEY")
+		query = integerType.ReplaceAllString(query, "BIGINT") // unix timestamps outgrow INTEGER in 2038
+	}
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+			b.WriteRune(r)
+		case r == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package storage
+
+import "testing"
+
+func TestToPostgres(t *testing.T) {
+	tests := []struct {
+		name, query, want string
+	}{
+		{"no placeholders", "SELECT COUNT(*) FROM Admin", "SELECT COUNT(*) FROM Admin"},
+		{"placeholders", "SELECT id FROM Mentors WHERE id=? AND org_id=?", "SELECT id FROM Mentors WHERE id=$1 AND org_id=$2"},
+		{"question mark in a string", "SELECT '?' FROM Mentors WHERE name=? AND remarks<>'why?'", "SELECT '?' FROM Mentors WHERE name=$1 AND remarks<>'why?'"},
+		{"returning", "INSERT INTO Projects (name,org_id) VALUES (?,?) RETURNING id", "INSERT INTO Projects (name,org_id) VALUES ($1,$2) RETURNING id"},
+		{"on conflict",
+			"INSERT INTO TokenCutoffs (role,user_id,not_before) VALUES (?,?,?) ON CONFLICT (role,user_id) DO UPDATE SET not_before=excluded.not_before",
+			"INSERT INTO TokenCutoffs (role,user_id,not_before) VALUES ($1,$2,$3) ON CONFLICT (role,user_id) DO UPDATE SET not_before=excluded.not_before"},
+		{"conditional upsert",
+			"INSERT INTO LoginThrottles (key,failures) VALUES (?,1) ON CONFLICT (key) DO UPDATE SET failures=LoginThrottles.failures+1 WHERE LoginThrottles.locked_until<=? RETURNING failures",
+			"INSERT INTO LoginThrottles (key,failures) VALUES ($1,1) ON CONFLICT (key) DO UPDATE SET failures=LoginThrottles.failures+1 WHERE LoginThrottles.locked_until<=$2 RETURNING failures"},
+		{"limit", "SELECT id FROM AuditLog WHERE org_id=? ORDER BY id DESC LIMIT ?", "SELECT id FROM AuditLog WHERE org_id=$1 ORDER BY id DESC LIMIT $2"},
+		{"casts outside schema statements are kept", "SELECT CAST(? AS BIGINT), CAST(strftime('%s','now') AS INTEGER)", "SELECT CAST($1 AS BIGINT), CAST(strftime('%s','now') AS INTEGER)"},
+		{"create table",
+			"CREATE TABLE IF NOT EXISTS AuditLog (\n\tid INTEGER PRIMARY KEY AUTOINCREMENT,\n\torg_id INTEGER NOT NULL,\n\tchanges TEXT NOT NULL\n)",
+			"CREATE TABLE IF NOT EXISTS AuditLog (\n\tid BIGSERIAL PRIMARY KEY,\n\torg_id BIGINT NOT NULL,\n\tchanges TEXT NOT NULL\n)"},
+		{"alter table", "ALTER TABLE Mentors ADD COLUMN deleted_at INTEGER", "ALTER TABLE Mentors ADD COLUMN deleted_at BIGINT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toPostgres(tt.query); got != tt.want {
+				t.Errorf("toPostgres(%q)\n got %q\nwant %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
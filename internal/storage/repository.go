@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// The repositories below are what the mentor, intern, project, assignment and admin handlers
+// need from storage. Each one sees a single organization, Repositories hands out the one for the
+// organization of the request. Sqlite and memory.Store implement them, storagetest checks that
+// both behave the same.
+//
+// Updates and deletes of mentors, interns, projects and assignments take the version the caller
+// read. They fail with ErrVersionMismatch when the record has changed since, nil skips the check.
+//
+// Interns and assignments keep every state they were in, the AsOf reads return them as they were
+// at a time in the past.
+
+type MentorRepository interface {
+	AddMentor(name *string, email *string, department *string) (int64, error)
+	GetMentors(scope *types.Scope) ([]types.ReturnMentor, error)
+	GetMentor(id *int64) (*types.ReturnMentor, error)
+	UpdateMentor(id *int64, name *string, email *string, department *string, version *int64) error
+	DeleteMentor(id *int64, version *int64) error
+}
+
+type InternRepository interface {
+	AddIntern(name *string, email *string, mentorId *int64) (int64, error)
+	GetInterns(scope *types.Scope) ([]types.ReturnIntern, error)
+	GetIntern(id *int64) (*types.ReturnIntern, error)
+	GetInternsAsOf(scope *types.Scope, asOf time.Time) ([]types.ReturnIntern, error)
+	GetInternAsOf(id *int64, asOf time.Time) (*types.ReturnIntern, error)
+	UpdateIntern(id *int64, name *string, email *string, mentorId *int64, status *string, version *int64) error
+	DeleteIntern(id *int64, version *int64) error
+}
+
+type ProjectRepository interface {
+	AddProject(name *string, description *string, startDate *string, endDate *string) (int64, error)
+	GetProjects() ([]types.ReturnProject, error)
+	GetProject(id *int64) (*types.ReturnProject, error)
+	UpdateProject(id *int64, name *string, description *string, status *string, startDate *string, endDate *string, version *int64) error
+	DeleteProject(id *int64, version *int64) error
+}
+
+type AssignmentRepository interface {
+	AddAssignment(internId *int64, projectId *int64, remarks *string) (int64, error)
+	GetAssignmets(scope *types.Scope) ([]types.ReturnAssignment, error)
+	GetAssignment(id *int64) (*types.ReturnAssignment, error)
+	GetAssignmentsAsOf(scope *types.Scope, asOf time.Time) ([]types.ReturnAssignment, error)
+	GetAssignmentAsOf(id *int64, asOf time.Time) (*types.ReturnAssignment, error)
+	UpdateAssignment(id *int64, internId *int64, projectId *int64, progress *int64, remarks *string, version *int64) error
+	DeleteAssignment(id *int64, version *int64) error
+}
+
+type AdminRepository interface {
+	GetAdmins() ([]types.ReturnAdmin, error)
+	GetAdmin(id *int64) (*types.ReturnAdmin, error)
+	CreateAdmin(username *string, email *string, password *string) (int64, error)
+	UpdateAdmin(id *int64, username *string, email *string) error
+	SetAdminActive(id *int64, active bool) error
+	SetAdminPassword(id *int64, password *string) error
+	DeleteAdmin(id *int64) error
+}
+
+// AccountRepository creates the login accounts of mentors and interns
+type AccountRepository interface {
+	CreateAccount(role *string, entityId *int64, ttl time.Duration) (string, error)
+}
+
+// DeletionRepository previews what deleting a mentor, intern or project does to the rows that
+// point at it, and deletes one after moving those rows to another record
+type DeletionRepository interface {
+	DeleteImpact(entity string, id int64, reassignTo *int64) (*DeleteImpact, error)
+	DeleteReassigning(entity string, id int64, reassignTo int64, version *int64) error
+}
+
+// TrashRepository lists the deleted mentors, interns, projects and assignments of the
+// organization and restores them
+type TrashRepository interface {
+	GetTrash(entity string) ([]TrashedRecord, error)
+	Restore(entity string, id int64) ([]Dependent, error)
+}
+
+// AuditRepository reads the audit log of the organization. The other repositories add to it,
+// every change they make to a mentor, intern, project or assignment gets an entry.
+type AuditRepository interface {
+	GetAudit(filter *AuditFilter) ([]AuditEntry, error)
+}
+
+// TrashPurger permanently deletes what every organization trashed before a time
+type TrashPurger interface {
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Repositories returns the repositories of the organization the request's token works in
+type Repositories interface {
+	Mentors(ctx context.Context) MentorRepository
+	Interns(ctx context.Context) InternRepository
+	Projects(ctx context.Context) ProjectRepository
+	Assignments(ctx context.Context) AssignmentRepository
+	Admins(ctx context.Context) AdminRepository
+	Accounts(ctx context.Context) AccountRepository
+	Deletions(ctx context.Context) DeletionRepository
+	Trash(ctx context.Context) TrashRepository
+	Audit(ctx context.Context) AuditRepository
+}
+
+var (
+	_ Repositories = (*Sqlite)(nil)
+	_ TrashPurger  = (*Sqlite)(nil)
+)
+
+func (sq *Sqlite) Mentors(ctx context.Context) MentorRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Interns(ctx context.Context) InternRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Projects(ctx context.Context) ProjectRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Assignments(ctx context.Context) AssignmentRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Admins(ctx context.Context) AdminRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Accounts(ctx context.Context) AccountRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Deletions(ctx context.Context) DeletionRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Trash(ctx context.Context) TrashRepository {
+	return sq.Tenant(ctx)
+}
+
+func (sq *Sqlite) Audit(ctx context.Context) AuditRepository {
+	return sq.Tenant(ctx)
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var (
+	ErrSessionNotFound = errors.New("no active session found with the given id")
+	ErrUserNotFound    = errors.New("no admin or account found for the given id")
+)
+
+// TouchSession moves last_seen_at forward, at most once per lastUsedResolution
+func (sq *Sqlite) TouchSession(ctx context.Context, sessionId string) error {
+	sq = sq.WithContext(ctx)
+	now := time.Now()
+	_, err := sq.DB.ExecContext(sq.ctx, "UPDATE Sessions SET last_seen_at=? WHERE id=? AND last_seen_at<?",
+		now.Unix(), sessionId, now.Add(-lastUsedResolution).Unix())
+	return err
+}
+
+// GetSessions lists the active sessions of a user, most recently used first
+func (sq *Sqlite) GetSessions(role string, userId int64) ([]types.ReturnSession, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT id,user_agent,ip,created_at,last_seen_at FROM Sessions
+		WHERE role=? AND user_id=? AND revoked_at IS NULL AND expires_at>=? ORDER BY last_seen_at DESC`,
+		role, userId, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []types.ReturnSession{}
+	for rows.Next() {
+		var session types.ReturnSession
+		var createdAt, lastSeenAt int64
+		err1 := rows.Scan(&session.Id, &session.UserAgent, &session.IP, &createdAt, &lastSeenAt)
+		if err1 != nil {
+			return nil, err1
+		}
+		session.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
+		session.LastSeenAt = time.Unix(lastSeenAt, 0).UTC().Format(time.RFC3339)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends one of the user's own sessions
+func (sq *Sqlite) RevokeSession(role string, userId int64, sessionId *string) error {
+	if sessionId == nil || *sessionId == "" {
+		return ErrSessionNotFound
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(sq.ctx, "SELECT 1 FROM Sessions WHERE id=? AND role=? AND user_id=? AND revoked_at IS NULL", sessionId, role, userId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err = revokeSession(sq.ctx, tx, *sessionId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeAllSessions ends every session of an admin (by Admin.id) or of the account linked to
+// a mentor or intern row of the handle's organization, and stops all access tokens issued to
+// them so far
+func (sq *Sqlite) RevokeAllSessions(role *string, userId *int64, entityId *int64) error {
+	if role == nil {
+		return fmt.Errorf("role is required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	switch *role {
+	case types.RoleAdmin:
+		if userId == nil || *userId == 0 {
+			return fmt.Errorf("user_id is required for admins")
+		}
+		err = tx.QueryRowContext(sq.ctx, "SELECT admin_id FROM AdminOrganizations WHERE admin_id=? AND org_id=?", userId, sq.orgId).Scan(&id)
+	case types.RoleMentor, types.RoleIntern:
+		if entityId == nil || *entityId == 0 {
+			return fmt.Errorf("entity_id is required for %s accounts", *role)
+		}
+		err = tx.QueryRowContext(sq.ctx, `SELECT a.id FROM Accounts as a
+			LEFT JOIN Mentors as m on a.role='mentor' AND m.id=a.entity_id
+			LEFT JOIN Interns as i on a.role='intern' AND i.id=a.entity_id
+			WHERE a.role=? AND a.entity_id=? AND COALESCE(m.org_id,i.org_id)=?`, role, entityId, sq.orgId).Scan(&id)
+	default:
+		return fmt.Errorf("role must be admin, mentor or intern")
+	}
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = revokeAllTokens(sq.ctx, tx, *role, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
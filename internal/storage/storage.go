@@ -1,214 +1,277 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Aytaditya/slotwise/internal/config"
 	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/password"
 	"github.com/Aytaditya/slotwise/internal/types"
 	_ "github.com/mattn/go-sqlite3"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// Sqlite reads and writes mentors, interns, projects and assignments of one organization only,
+// get a handle for it with Tenant or ForOrg. The handle returned by ConnectDB has none and
+// sees no such rows. Despite the name it runs on postgres as well, see database.driver.
+//
+// Statements run under the context of the handle, set by Tenant or WithContext, so they stop
+// when the request is canceled or database.query_timeout runs out.
 type Sqlite struct {
-	DB *sql.DB
+	DB           *sql.DB
+	ctx          context.Context
+	queryTimeout time.Duration
+	orgId        int64
+	hasher       *password.Hasher
+	policy       *password.Policy
+	postgres     bool
 }
 
+// ErrInvalidCredentials is returned for both unknown emails and wrong passwords
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 func ConnectDB(config *config.Config) (*Sqlite, error) {
 	// db is instance
 	fmt.Println(config.Address)
-	db, err := sql.Open("sqlite3", config.StoragePath)
+	db, err := openDB(&config.Database, config.StoragePath)
 	if err != nil {
 		return nil, err
 	}
 
-	// creating admin table TABLE 1
-	_, er := db.Exec(`CREATE TABLE IF NOT EXISTS Admin (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL UNIQUE,
-		email TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL
-	)`)
-
-	if er != nil {
-		return nil, er
+	migrator, err := newMigrator(db, config.Database.Driver == "postgres")
+	if err != nil {
+		return nil, err
 	}
-
-	// creating buddy table TABLE 2
-	_, ok := db.Exec(`CREATE TABLE IF NOT EXISTS Mentors (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-  		name TEXT NOT NULL,
-  		email TEXT UNIQUE NOT NULL,
-  		department TEXT
-	)`)
-
-	if ok != nil {
-		return nil, ok
+	if config.Database.ManualMigrate {
+		err = migrator.pending()
+	} else {
+		_, err = migrator.Up()
 	}
-
-	// creating table for interns
-	_, er1 := db.Exec(`CREATE TABLE IF NOT EXISTS Interns (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-  name TEXT NOT NULL,
-  email TEXT UNIQUE NOT NULL,
-  status TEXT DEFAULT 'active',
-  mentor_id INTEGER,
-  FOREIGN KEY(mentor_id) REFERENCES mentors(id)
-	)`)
-
-	if er1 != nil {
-		return nil, er1
+	if err != nil {
+		return nil, err
 	}
 
-	// creating table for projects
-	_, er2 := db.Exec(`CREATE TABLE IF NOT EXISTS Projects (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-  		name TEXT NOT NULL,
-  		description TEXT,
-  		status TEXT DEFAULT 'ongoing',
-  		start_date TEXT,
-  		end_date TEXT
-	)`)
-
-	if er2 != nil {
-		return nil, er2
+	hasher, err := password.NewHasher(&config.Password)
+	if err != nil {
+		return nil, err
 	}
+	return &Sqlite{
+		DB:           db,
+		ctx:          context.Background(),
+		queryTimeout: config.Database.QueryTimeout,
+		hasher:       hasher,
+		policy:       password.NewPolicy(&config.Password),
+		postgres:     config.Database.Driver == "postgres",
+	}, nil
+}
 
-	_, er3 := db.Exec(`CREATE TABLE IF NOT EXISTS Assignments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-  intern_id INTEGER,
-  project_id INTEGER,
-  progress INTEGER DEFAULT 0,
-  remarks TEXT,
-  FOREIGN KEY (intern_id) REFERENCES interns(id),
-  FOREIGN KEY (project_id) REFERENCES projects(id)
-	)`)
-
-	if er3 != nil {
-		return nil, er3
+// openDB connects to the configured backend, both run the same queries
+func openDB(cfg *config.Database, storagePath string) (*sql.DB, error) {
+	switch cfg.Driver {
+	case "", "sqlite", "sqlite3":
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = storagePath
+		}
+		if dsn == "" {
+			return nil, fmt.Errorf("storage_path or database.dsn is required for sqlite")
+		}
+		// sqlite only enforces foreign keys on connections that ask for it
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		return sql.Open("sqlite3", dsn+separator+"_foreign_keys=on")
+	case "postgres":
+		connector, err := newPostgresConnector(cfg.DSN)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
 	}
-
-	return &Sqlite{DB: db}, nil
+	return nil, fmt.Errorf("unknown database driver %q, use sqlite or postgres", cfg.Driver)
 }
 
-func (sq *Sqlite) Signup(username *string, email *string, password *string) (int64, string, error) {
+// ErrSignupClosed is returned by Signup once the first admin exists, unless signup is open
+var ErrSignupClosed = errors.New("signup is closed, ask an admin for an invitation")
+
+// Signup creates an admin in the default organization. Unless open is set only the very first
+// admin can sign up, two signups racing on a fresh install can't both get in.
+func (sq *Sqlite) Signup(username *string, email *string, password *string, open bool) (int64, error) {
 	if username == nil || password == nil || email == nil {
-		return 0, "", fmt.Errorf("username, email and password must not be nil")
+		return 0, fmt.Errorf("username, email and password must not be nil")
+	}
+	if !open {
+		// refuse early, before the password is checked and hashed. The insert below decides.
+		var found int
+		err := sq.DB.QueryRowContext(sq.ctx, "SELECT 1 FROM Admin LIMIT 1").Scan(&found)
+		if err == nil {
+			return 0, ErrSignupClosed
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	hashedPassword, err := sq.hashNewPassword("password", *password, *email, *username)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to hash password: %v", err)
+		return 0, err
 	}
 
-	stmt, err := sq.DB.Prepare("INSERT INTO Admin (username,email,password) VALUES (?,?,?)")
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
 	if err != nil {
-		return 0, "", err
-	}
-	res, err1 := stmt.Exec(username, email, string(hashedPassword))
-	if err1 != nil {
-		return 0, "", err1
+		return 0, err
 	}
-	stmt.Close()
+	defer tx.Rollback()
 
-	id, err2 := res.LastInsertId()
-	if err2 != nil {
-		return 0, "", err2
+	query := "INSERT INTO Admin (username,email,password) VALUES (?,?,?) RETURNING id"
+	if !open {
+		if sq.postgres {
+			// concurrent signups queue up here and see each other's admin, sqlite writes one at a time
+			if _, err = tx.ExecContext(sq.ctx, "LOCK TABLE Admin IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+				return 0, err
+			}
+		}
+		query = "INSERT INTO Admin (username,email,password) SELECT ?,?,? WHERE NOT EXISTS (SELECT 1 FROM Admin) RETURNING id"
 	}
-
-	// now we will generate token
-	token, err3 := jwt.CreateToken(id, *email)
-	if err3 != nil {
-		return 0, "", err3
+	var id int64
+	err = tx.QueryRowContext(sq.ctx, query, username, email, hashedPassword).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrSignupClosed
 	}
-
-	return id, token, nil
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(sq.ctx, "INSERT INTO AdminOrganizations (admin_id,org_id,created_at) VALUES (?,?,?)", id, DefaultOrgID, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-func (sq *Sqlite) Login(email *string, password *string) (int64, string, error) {
+// Login checks admins first and then mentor/intern accounts
+func (sq *Sqlite) Login(email *string, password *string) (*types.Principal, string, error) {
 	if email == nil || password == nil {
-		return 0, "", fmt.Errorf("email or password cant be empty")
+		return nil, "", fmt.Errorf("email or password cant be empty")
 	}
 
-	row := sq.DB.QueryRow("SELECT id,password FROM Admin where email=?", email)
-	var id int64
+	candidates, err := sq.principalsByEmail(*email)
+	if err != nil {
+		return nil, "", err
+	}
+	var principal *types.Principal
 	var dbPassword string
-
-	err := row.Scan(&id, &dbPassword)
-	if err == sql.ErrNoRows {
-		return 0, "", fmt.Errorf("no user found with the given email")
+	for i := range candidates {
+		if sq.hasher.Verify(candidates[i].password, *password) {
+			principal, dbPassword = &candidates[i].principal, candidates[i].password
+			break
+		}
 	}
-	if err != nil {
-		return 0, "", err
+	if len(candidates) == 0 {
+		// compare anyway so unknown emails take as long as wrong passwords
+		sq.hasher.VerifyDummy(*password)
 	}
-
-	// Compare the provided password with the stored hashed password
-	err = bcrypt.CompareHashAndPassword([]byte(dbPassword), []byte(*password))
-	if err != nil {
-		return 0, "", fmt.Errorf("wrong password entered")
+	if principal == nil {
+		return nil, "", ErrInvalidCredentials
+	}
+	// the plain password is only at hand now, so this is when an outdated hash gets upgraded
+	if sq.hasher.NeedsRehash(dbPassword) {
+		if err = sq.rehash(principal, *password); err != nil {
+			log.Printf("upgrading password hash failed: %v", err)
+		}
 	}
 
 	// now we will generate token
-	token, err1 := jwt.CreateToken(id, *email)
+	token, err1 := jwt.CreateToken(principal)
 	if err1 != nil {
-		return 0, "", err1
+		return nil, "", err1
 	}
-	return id, token, nil
+	return principal, token, nil
 }
 
-func (sq *Sqlite) AddIntern(name *string, email *string, mentorId *int64) (int64, error) {
+type loginCandidate struct {
+	principal types.Principal
+	password  string
+}
 
-	if name == nil || email == nil || mentorId == nil {
-		return 0, fmt.Errorf("field empty")
+// principalsByEmail finds the admin and activated accounts that log in with the email, the admin
+// first and then the oldest account. Mentor and intern emails are only unique per organization, so
+// a person in several organizations can have an account in each.
+func (sq *Sqlite) principalsByEmail(email string) ([]loginCandidate, error) {
+	candidates := []loginCandidate{}
+	admin := loginCandidate{principal: types.Principal{Email: email, Role: types.RoleAdmin}}
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT id,password FROM Admin where email=? AND active=1", email).Scan(&admin.principal.ID, &admin.password)
+	if err == nil {
+		admin.principal.OrgID, err = defaultOrg(sq.ctx, sq.DB, admin.principal.ID)
+	}
+	if err == nil {
+		candidates = append(candidates, admin)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
 	}
 
-	stmt, err := sq.DB.Prepare("INSERT INTO Interns (name,email,mentor_id) VALUES (?,?,?)")
+	rows, err := sq.DB.QueryContext(sq.ctx, `SELECT a.id,a.role,a.entity_id,COALESCE(m.org_id,i.org_id),a.password FROM Accounts as a
+		LEFT JOIN Mentors as m on a.role='mentor' AND m.id=a.entity_id AND m.deleted_at IS NULL
+		LEFT JOIN Interns as i on a.role='intern' AND i.id=a.entity_id AND i.deleted_at IS NULL
+		WHERE a.password IS NOT NULL AND (m.email=? OR i.email=?) ORDER BY a.id`, email, email)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	res, err1 := stmt.Exec(name, email, mentorId)
-	if err1 != nil {
-		return 0, err1
+	defer rows.Close()
+	for rows.Next() {
+		account := loginCandidate{principal: types.Principal{Email: email}}
+		err1 := rows.Scan(&account.principal.ID, &account.principal.Role, &account.principal.EntityID, &account.principal.OrgID, &account.password)
+		if err1 != nil {
+			return nil, err1
+		}
+		candidates = append(candidates, account)
 	}
+	return candidates, rows.Err()
+}
 
-	stmt.Close()
+func (sq *Sqlite) AddIntern(name *string, email *string, mentorId *int64) (int64, error) {
 
-	id, err2 := res.LastInsertId()
-	if err2 != nil {
-		return 0, err2
+	if name == nil || email == nil || mentorId == nil {
+		return 0, fmt.Errorf("field empty")
+	}
+	if err := sq.requireOrg(); err != nil {
+		return 0, err
+	}
+	if err := sq.requireInOrg("Mentors", *mentorId); err != nil {
+		return 0, err
 	}
 
-	return id, nil
+	return sq.insert("Interns", "INSERT INTO Interns (name,email,mentor_id,org_id) VALUES (?,?,?,?) RETURNING id", name, email, mentorId, sq.orgId)
 }
 
 func (sq *Sqlite) AddMentor(name *string, email *string, department *string) (int64, error) {
 	if name == nil || email == nil || department == nil {
 		return 0, fmt.Errorf("field missing")
 	}
-
-	stmt, err := sq.DB.Prepare("INSERT INTO Mentors (name,email,department) VALUES (?,?,?)")
-	if err != nil {
+	if err := sq.requireOrg(); err != nil {
 		return 0, err
 	}
 
-	res, err1 := stmt.Exec(name, email, department)
-	if err1 != nil {
-		return 0, err1
-	}
-
-	stmt.Close()
-
-	id, err2 := res.LastInsertId()
-	if err2 != nil {
-		return 0, err2
-	}
-
-	return id, nil
+	return sq.insert("Mentors", "INSERT INTO Mentors (name,email,department,org_id) VALUES (?,?,?,?) RETURNING id", name, email, department, sq.orgId)
 }
 
-func (sq *Sqlite) GetMentors() ([]types.ReturnMentor, error) {
-	rows, err := sq.DB.Query("SELECT id, name,email,department FROM Mentors")
+func (sq *Sqlite) GetMentors(scope *types.Scope) ([]types.ReturnMentor, error) {
+	query := "SELECT id, name,email,department,version FROM Mentors WHERE org_id=? AND deleted_at IS NULL"
+	args := []any{sq.orgId}
+	if scope != nil && scope.MentorId != 0 {
+		query += " AND id=?"
+		args = append(args, scope.MentorId)
+	}
+	rows, err := sq.DB.QueryContext(sq.ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -217,18 +280,42 @@ func (sq *Sqlite) GetMentors() ([]types.ReturnMentor, error) {
 	var mentors []types.ReturnMentor
 	for rows.Next() {
 		var mentor types.ReturnMentor // a single mentor will be appended into mentors
-		err1 := rows.Scan(&mentor.Id, &mentor.Name, &mentor.Email, &mentor.Department)
+		err1 := rows.Scan(&mentor.Id, &mentor.Name, &mentor.Email, &mentor.Department, &mentor.Version)
 		if err1 != nil {
 			return nil, err1
 		}
 		mentors = append(mentors, mentor)
 	}
 
-	return mentors, nil
+	return mentors, rows.Err()
 }
 
-func (sq *Sqlite) GetInterns() ([]types.ReturnIntern, error) {
-	rows, err := sq.DB.Query("SELECT a.id,a.name,a.email,a.status,a.mentor_id,b.name,b.email from Interns as a INNER JOIN Mentors as b on a.mentor_id=b.id ")
+func (sq *Sqlite) GetMentor(id *int64) (*types.ReturnMentor, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	mentors, err := sq.GetMentors(&types.Scope{MentorId: *id})
+	if err != nil {
+		return nil, err
+	}
+	if len(mentors) == 0 || mentors[0].Id != *id {
+		return nil, fmt.Errorf("%w: no mentor with id %d", ErrRecordNotFound, *id)
+	}
+	return &mentors[0], nil
+}
+
+func (sq *Sqlite) GetInterns(scope *types.Scope) ([]types.ReturnIntern, error) {
+	// interns without a mentor are listed too, with the mentor left blank
+	query := "SELECT a.id,a.name,a.email,a.status,a.mentor_id,COALESCE(b.name,''),COALESCE(b.email,''),a.version from Interns as a LEFT JOIN Mentors as b on a.mentor_id=b.id WHERE a.org_id=? AND a.deleted_at IS NULL "
+	args := []any{sq.orgId}
+	if scope != nil && scope.MentorId != 0 {
+		query += "AND a.mentor_id=?"
+		args = append(args, scope.MentorId)
+	} else if scope != nil && scope.InternId != 0 {
+		query += "AND a.id=?"
+		args = append(args, scope.InternId)
+	}
+	rows, err := sq.DB.QueryContext(sq.ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -236,116 +323,112 @@ func (sq *Sqlite) GetInterns() ([]types.ReturnIntern, error) {
 	var interns []types.ReturnIntern
 	for rows.Next() {
 		var intern types.ReturnIntern
+		var mentorId sql.NullInt64
 		err1 := rows.Scan(&intern.ID,
 			&intern.Name,
 			&intern.Email,
 			&intern.Status,
-			&intern.MentorId,
+			&mentorId,
 			&intern.MentorName,
-			&intern.MentorEmail)
+			&intern.MentorEmail,
+			&intern.Version)
 		if err1 != nil {
 			return nil, err1
 		}
+		if mentorId.Valid {
+			intern.MentorId = strconv.FormatInt(mentorId.Int64, 10)
+		}
 
 		interns = append(interns, intern)
 	}
-	return interns, nil
+	return interns, rows.Err()
 }
 
-func (sq *Sqlite) UpdateIntern(id *int64, name *string, email *string, mentor_id *int64, status *string) error {
+func (sq *Sqlite) GetIntern(id *int64) (*types.ReturnIntern, error) {
 	if id == nil {
-		return fmt.Errorf("id is required")
+		return nil, fmt.Errorf("id is required")
 	}
-
-	stmt, err := sq.DB.Prepare("UPDATE Interns SET name=?, email=?, mentor_id=?, status=? WHERE id=?")
+	interns, err := sq.GetInterns(&types.Scope{InternId: *id})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	_, err1 := stmt.Exec(name, email, mentor_id, status, id)
-	if err1 != nil {
-		return err1
+	if len(interns) == 0 || interns[0].ID != *id {
+		return nil, fmt.Errorf("%w: no intern with id %d", ErrRecordNotFound, *id)
 	}
-
-	stmt.Close()
-	return nil
+	return &interns[0], nil
 }
 
-func (sq *Sqlite) DeleteIntern(id *int64) error {
+func (sq *Sqlite) UpdateIntern(id *int64, name *string, email *string, mentor_id *int64, status *string, version *int64) error {
 	if id == nil {
 		return fmt.Errorf("id is required")
 	}
-	stmt, err := sq.DB.Prepare("DELETE FROM Interns WHERE id=?")
-	if err != nil {
-		return err
-	}
-	_, err1 := stmt.Exec(id)
-	if err1 != nil {
-		return err1
+	if mentor_id != nil {
+		if err := sq.requireInOrg("Mentors", *mentor_id); err != nil {
+			return err
+		}
 	}
-	stmt.Close()
-	return nil
+
+	query, args := versioned("UPDATE Interns SET name=?, email=?, mentor_id=?, status=?, version=version+1 WHERE id=? AND org_id=? AND deleted_at IS NULL",
+		[]any{name, email, mentor_id, status, id, sq.orgId}, version)
+	return sq.update("Interns", *id, query, args, version)
 }
 
-func (sq *Sqlite) UpdateMentor(id *int64, name *string, email *string, department *string) error {
+func (sq *Sqlite) DeleteIntern(id *int64, version *int64) error {
 	if id == nil {
 		return fmt.Errorf("id is required")
 	}
+	return sq.deleteRow("Interns", *id, version)
+}
 
-	stmt, err := sq.DB.Prepare("UPDATE Mentors SET name=?, email=?, department=? WHERE id=?")
-	if err != nil {
-		return err
-	}
-
-	_, err1 := stmt.Exec(name, email, department, id)
-	if err1 != nil {
-		return err1
+func (sq *Sqlite) UpdateMentor(id *int64, name *string, email *string, department *string, version *int64) error {
+	if id == nil {
+		return fmt.Errorf("id is required")
 	}
 
-	stmt.Close()
-	return nil
+	query, args := versioned("UPDATE Mentors SET name=?, email=?, department=?, version=version+1 WHERE id=? AND org_id=? AND deleted_at IS NULL",
+		[]any{name, email, department, id, sq.orgId}, version)
+	return sq.update("Mentors", *id, query, args, version)
 }
 
-func (sq *Sqlite) DeleteMentor(id *int64) error {
+func (sq *Sqlite) DeleteMentor(id *int64, version *int64) error {
 	if id == nil {
 		return fmt.Errorf("id is required")
 	}
-	stmt, err := sq.DB.Prepare("DELETE FROM Mentors WHERE id=?")
-	if err != nil {
-		return err
-	}
-	_, err1 := stmt.Exec(id)
-	if err1 != nil {
-		return err1
-	}
-	stmt.Close()
-	return nil
+	return sq.deleteRow("Mentors", *id, version)
 }
 
 func (sq *Sqlite) AddProject(name *string, description *string, startDate *string, endDate *string) (int64, error) {
 	if name == nil || description == nil || startDate == nil || endDate == nil {
 		return 0, fmt.Errorf("field missing")
 	}
-	stmt, err := sq.DB.Prepare("INSERT INTO Projects (name,description,start_date,end_date) VALUES (?,?,?,?)")
-	if err != nil {
+	if err := sq.requireOrg(); err != nil {
 		return 0, err
 	}
-	res, err1 := stmt.Exec(name, description, startDate, endDate)
-	if err1 != nil {
-		return 0, err1
-	}
-	defer stmt.Close()
+	return sq.insert("Projects", "INSERT INTO Projects (name,description,start_date,end_date,org_id) VALUES (?,?,?,?,?) RETURNING id", name, description, startDate, endDate, sq.orgId)
+}
 
-	id, err2 := res.LastInsertId()
-	if err2 != nil {
-		return 0, err2
-	}
+func (sq *Sqlite) GetProjects() ([]types.ReturnProject, error) {
+	return sq.queryProjects("")
+}
 
-	return id, nil
+func (sq *Sqlite) GetProject(id *int64) (*types.ReturnProject, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	projects, err := sq.queryProjects(" AND id=?", *id)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("%w: no project with id %d", ErrRecordNotFound, *id)
+	}
+	return &projects[0], nil
 }
 
-func (sq *Sqlite) GetProjects() ([]types.ReturnProject, error) {
-	rows, err := sq.DB.Query("SELECT id,name,description,status,start_date,end_date FROM Projects")
+// queryProjects lists the projects of the organization that meet the extra condition
+func (sq *Sqlite) queryProjects(condition string, args ...any) ([]types.ReturnProject, error) {
+	rows, err := sq.DB.QueryContext(sq.ctx, "SELECT id,name,description,status,start_date,end_date,version FROM Projects WHERE org_id=? AND deleted_at IS NULL"+condition,
+		append([]any{sq.orgId}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -353,113 +436,134 @@ func (sq *Sqlite) GetProjects() ([]types.ReturnProject, error) {
 	var projects []types.ReturnProject
 	for rows.Next() {
 		var proj types.ReturnProject
-		err1 := rows.Scan(&proj.Id, &proj.Name, &proj.Description, &proj.Status, &proj.StartDate, &proj.EndDate)
+		err1 := rows.Scan(&proj.Id, &proj.Name, &proj.Description, &proj.Status, &proj.StartDate, &proj.EndDate, &proj.Version)
 		if err1 != nil {
 			return nil, err1
 		}
 		projects = append(projects, proj)
 	}
-	return projects, nil
+	return projects, rows.Err()
 }
 
-func (sq *Sqlite) UpdateProject(id *int64, name *string, description *string, status *string, startDate *string, endDate *string) error {
+func (sq *Sqlite) UpdateProject(id *int64, name *string, description *string, status *string, startDate *string, endDate *string, version *int64) error {
 	if id == nil {
 		return fmt.Errorf("id is required")
 	}
 	if name == nil {
 		return fmt.Errorf("field missing")
 	}
-	stmt, err := sq.DB.Prepare("UPDATE Projects SET name=?, description=?, status=?, start_date=?, end_date=? WHERE id=?")
-	if err != nil {
-		return err
-	}
-	_, err1 := stmt.Exec(name, description, status, startDate, endDate, id)
-	if err1 != nil {
-		return err1
-	}
-	defer stmt.Close()
-	return nil
+	query, args := versioned("UPDATE Projects SET name=?, description=?, status=?, start_date=?, end_date=?, version=version+1 WHERE id=? AND org_id=? AND deleted_at IS NULL",
+		[]any{name, description, status, startDate, endDate, id, sq.orgId}, version)
+	return sq.update("Projects", *id, query, args, version)
 }
 
-func (sq *Sqlite) DeleteProject(id *int64) error {
+func (sq *Sqlite) DeleteProject(id *int64, version *int64) error {
 	if id == nil {
 		return fmt.Errorf("id is required")
 	}
-	stmt, err := sq.DB.Prepare("DELETE FROM Projects WHERE id=?")
-	if err != nil {
-		return err
-	}
-	_, err1 := stmt.Exec(id)
-	if err1 != nil {
-		return err1
-	}
-	return nil
+	return sq.deleteRow("Projects", *id, version)
 }
 
 func (sq *Sqlite) AddAssignment(internId *int64, projectId *int64, remarks *string) (int64, error) {
 	if internId == nil || projectId == nil || remarks == nil {
 		return 0, fmt.Errorf("missing field")
 	}
-	stmt, err := sq.DB.Prepare("INSERT INTO Assignments (intern_id,project_id,remarks) VALUES (?,?,?)")
-	if err != nil {
+	if err := sq.requireOrg(); err != nil {
 		return 0, err
 	}
-	res, err1 := stmt.Exec(internId, projectId, remarks)
-	if err1 != nil {
-		return 0, err1
+	if err := sq.requireInOrg("Interns", *internId); err != nil {
+		return 0, err
 	}
-	defer stmt.Close()
-	id, err2 := res.LastInsertId()
-	if err2 != nil {
-		return 0, err2
+	if err := sq.requireInOrg("Projects", *projectId); err != nil {
+		return 0, err
 	}
-	return id, nil
+	return sq.insert("Assignments", "INSERT INTO Assignments (intern_id,project_id,remarks,org_id) VALUES (?,?,?,?) RETURNING id", internId, projectId, remarks, sq.orgId)
+}
+
+func (sq *Sqlite) GetAssignmets(scope *types.Scope) ([]types.ReturnAssignment, error) {
+	if scope != nil && scope.MentorId != 0 {
+		return sq.queryAssignments(" AND b.mentor_id=?", scope.MentorId)
+	} else if scope != nil && scope.InternId != 0 {
+		return sq.queryAssignments(" AND a.intern_id=?", scope.InternId)
+	}
+	return sq.queryAssignments("")
 }
 
-func (sq *Sqlite) GetAssignmets() ([]types.ReturnAssignment, error) {
-	row, err := sq.DB.Query("SELECT a.id,a.intern_id,a.project_id,a.progress,a.remarks,b.name,c.name from Assignments as a INNER JOIN Interns as b on a.intern_id=b.id INNER JOIN Projects as c on a.project_id=c.id")
+func (sq *Sqlite) GetAssignment(id *int64) (*types.ReturnAssignment, error) {
+	if id == nil {
+		return nil, fmt.Errorf("id is required")
+	}
+	assignments, err := sq.queryAssignments(" AND a.id=?", *id)
 	if err != nil {
 		return nil, err
 	}
+	if len(assignments) == 0 {
+		return nil, fmt.Errorf("%w: no assignment with id %d", ErrRecordNotFound, *id)
+	}
+	return &assignments[0], nil
+}
+
+// queryAssignments lists the assignments of the organization that meet the extra condition, a is
+// the assignment, b its intern and c its project
+func (sq *Sqlite) queryAssignments(condition string, args ...any) ([]types.ReturnAssignment, error) {
+	query := "SELECT a.id,a.intern_id,a.project_id,a.progress,a.remarks,b.name,c.name,a.version from Assignments as a INNER JOIN Interns as b on a.intern_id=b.id INNER JOIN Projects as c on a.project_id=c.id WHERE a.org_id=? AND a.deleted_at IS NULL" + condition
+	rows, err := sq.DB.QueryContext(sq.ctx, query, append([]any{sq.orgId}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	var assignments []types.ReturnAssignment
-	for row.Next() {
+	for rows.Next() {
 		var assign types.ReturnAssignment
-		err1 := row.Scan(&assign.Id, &assign.InternId, &assign.ProjectId, &assign.Progress, &assign.Remarks, &assign.InternName, &assign.ProjectName)
+		err1 := rows.Scan(&assign.Id, &assign.InternId, &assign.ProjectId, &assign.Progress, &assign.Remarks, &assign.InternName, &assign.ProjectName, &assign.Version)
 		if err1 != nil {
 			return nil, err1
 		}
 		assignments = append(assignments, assign)
 	}
-	return assignments, nil
+	return assignments, rows.Err()
 }
 
-func (sq *Sqlite) UpdateAssignment(internId *int64, projectId *int64, progress *int64, remarks *string) error {
-	if internId == nil || projectId == nil {
+func (sq *Sqlite) UpdateAssignment(id *int64, internId *int64, projectId *int64, progress *int64, remarks *string, version *int64) error {
+	if id == nil || internId == nil || projectId == nil {
 		return fmt.Errorf("missing field")
 	}
-	stmt, err := sq.DB.Prepare("UPDATE Assignments SET progress=?, remarks=? WHERE intern_id=? AND project_id=?")
-	if err != nil {
+	if err := sq.requireInOrg("Interns", *internId); err != nil {
 		return err
 	}
-	_, err1 := stmt.Exec(progress, remarks, internId, projectId)
-	if err1 != nil {
-		return err1
+	if err := sq.requireInOrg("Projects", *projectId); err != nil {
+		return err
 	}
-	defer stmt.Close()
-	return nil
+	query, args := versioned("UPDATE Assignments SET intern_id=?, project_id=?, progress=?, remarks=?, version=version+1 WHERE id=? AND org_id=? AND deleted_at IS NULL",
+		[]any{internId, projectId, progress, remarks, id, sq.orgId}, version)
+	return sq.update("Assignments", *id, query, args, version)
 }
 
-func (sq *Sqlite) DeleteAssignment(id *int64) error {
+func (sq *Sqlite) DeleteAssignment(id *int64, version *int64) error {
 	if id == nil {
 		return fmt.Errorf("id is required")
 	}
-	stmt, err := sq.DB.Prepare("DELETE FROM Assignments WHERE id=?")
+	return sq.deleteRow("Assignments", *id, version)
+}
+
+// InternMentor returns the mentor an intern is assigned to
+func (sq *Sqlite) InternMentor(ctx context.Context, internId int64) (int64, error) {
+	sq = sq.WithContext(ctx)
+	var mentorId sql.NullInt64
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT mentor_id FROM Interns WHERE id=? AND deleted_at IS NULL", internId).Scan(&mentorId)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	_, err1 := stmt.Exec(id)
-	if err1 != nil {
-		return err1
+	return mentorId.Int64, nil
+}
+
+// AssignmentOwner returns the intern of an assignment and that intern's mentor
+func (sq *Sqlite) AssignmentOwner(ctx context.Context, assignmentId int64) (int64, int64, error) {
+	sq = sq.WithContext(ctx)
+	var internId, mentorId sql.NullInt64
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT a.intern_id,b.mentor_id FROM Assignments as a LEFT JOIN Interns as b on a.intern_id=b.id WHERE a.id=? AND a.deleted_at IS NULL", assignmentId).Scan(&internId, &mentorId)
+	if err != nil {
+		return 0, 0, err
 	}
-	return nil
+	return internId.Int64, mentorId.Int64, nil
 }
@@ -0,0 +1,561 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/storage/storagetest"
+	"github.com/Aytaditya/slotwise/internal/totp"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// testConfig is the default configuration with a cheap bcrypt cost
+func testConfig(driver string, dsn string, storagePath string) *config.Config {
+	cfg := &config.Config{StoragePath: storagePath}
+	cfg.Database = config.Database{Driver: driver, DSN: dsn}
+	cfg.Password = config.Password{MinLength: 12, MaxLength: 72, Hash: "bcrypt", BcryptCost: 4}
+	return cfg
+}
+
+func openSqlite(t *testing.T) *storage.Sqlite {
+	t.Helper()
+	sq, err := storage.ConnectDB(testConfig("sqlite", "", filepath.Join(t.TempDir(), "storage.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sq.DB.Close() })
+	return sq
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Repositories { return openSqlite(t) })
+}
+
+// TestPostgresConformance needs TALENTFLOW_TEST_POSTGRES, see storagetest.PostgresDSN
+func TestPostgresConformance(t *testing.T) {
+	if os.Getenv("TALENTFLOW_TEST_POSTGRES") == "" {
+		t.Skip("TALENTFLOW_TEST_POSTGRES is not set")
+	}
+	storagetest.Run(t, func(t *testing.T) storage.Repositories {
+		sq, err := storage.ConnectDB(testConfig("postgres", storagetest.PostgresDSN(t), ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sq.DB.Close() })
+		return sq
+	})
+}
+
+// TestOrgMembership checks that admins only join another organization by accepting an
+// invitation, and that an organization can't change the account of an admin it shares
+func TestOrgMembership(t *testing.T) {
+	sq := openSqlite(t)
+	const strong = "a long enough passphrase"
+	admins := sq.Admins(storagetest.Org(storage.DefaultOrgID))
+	victim, err := admins.CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr(strong))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := admins.CreateAdmin(ptr("linus"), ptr("linus@example.com"), ptr(strong))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgId, err := sq.CreateOrganization(ptr("Other"), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	org := sq.ForOrg(orgId)
+
+	id, err := org.InviteOrgMember(&victim, other, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := org.InviteOrgMember(&other, other, time.Hour); !errors.Is(err, storage.ErrAlreadyOrgMember) {
+		t.Fatalf("expected ErrAlreadyOrgMember, got %v", err)
+	}
+	if err := org.SetAdminPassword(&victim, ptr("the inviter's choice")); !errors.Is(err, storage.ErrAdminNotFound) {
+		t.Fatalf("an invited admin's password was changed before they accepted: %v", err)
+	}
+	if _, err := sq.AcceptOrgInvitation(other, &id); !errors.Is(err, storage.ErrOrgInvitationNotFound) {
+		t.Fatalf("someone else accepted the invitation: %v", err)
+	}
+	if list, err := sq.GetOrgInvitations(victim); err != nil || len(list) != 1 || list[0].OrgId != orgId || list[0].InvitedBy != "linus@example.com" {
+		t.Fatalf("expected the pending invitation, got %+v, %v", list, err)
+	}
+	if joined, err := sq.AcceptOrgInvitation(victim, &id); err != nil || joined != orgId {
+		t.Fatalf("accepting returned %d, %v", joined, err)
+	}
+	if _, err := sq.AcceptOrgInvitation(victim, &id); !errors.Is(err, storage.ErrOrgInvitationNotFound) {
+		t.Fatalf("an invitation was accepted twice: %v", err)
+	}
+
+	// both organizations now share the admin, neither may change their account
+	for _, handle := range []*storage.Sqlite{org, sq.ForOrg(storage.DefaultOrgID)} {
+		if err := handle.SetAdminPassword(&victim, ptr("the inviter's choice")); !errors.Is(err, storage.ErrAdminShared) {
+			t.Fatalf("expected ErrAdminShared setting the password, got %v", err)
+		}
+		if err := handle.UpdateAdmin(&victim, ptr("ada"), ptr("inviter@example.com")); !errors.Is(err, storage.ErrAdminShared) {
+			t.Fatalf("expected ErrAdminShared changing the email, got %v", err)
+		}
+		if err := handle.SetAdminActive(&victim, false); !errors.Is(err, storage.ErrAdminShared) {
+			t.Fatalf("expected ErrAdminShared deactivating, got %v", err)
+		}
+		if err := handle.DeleteAdmin(&victim); !errors.Is(err, storage.ErrAdminShared) {
+			t.Fatalf("expected ErrAdminShared deleting, got %v", err)
+		}
+	}
+
+	check(t, org.RemoveOrgMember(&victim))
+	if err := org.RemoveOrgMember(&other); !errors.Is(err, storage.ErrLastActiveAdmin) {
+		t.Fatalf("expected ErrLastActiveAdmin removing the last member, got %v", err)
+	}
+	declined, err := org.InviteOrgMember(&victim, other, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, sq.DeclineOrgInvitation(victim, &declined))
+	if _, err := sq.AcceptOrgInvitation(victim, &declined); !errors.Is(err, storage.ErrOrgInvitationNotFound) {
+		t.Fatalf("a declined invitation was accepted: %v", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// loadKeys lets the tests issue and validate tokens
+func loadKeys(t *testing.T) {
+	t.Helper()
+	err := jwt.LoadKeys(&config.JWT{SigningKey: "test", Keys: []config.JWTKey{{ID: "test", Algorithm: "HS256", Secret: "a secret only tests use"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestOrgEmails checks that a person with accounts in two organizations logs in to whichever
+// their password opens, and that login throttles are only visible to their own organization
+func TestOrgEmails(t *testing.T) {
+	loadKeys(t)
+	sq := openSqlite(t)
+	admin, err := sq.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgId, err := sq.CreateOrganization(ptr("Other"), admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwords := map[int64]string{storage.DefaultOrgID: "the first organization", orgId: "the second organization"}
+	for _, org := range []int64{storage.DefaultOrgID, orgId} {
+		handle := sq.ForOrg(org)
+		mentor, err := handle.AddMentor(ptr("Grace"), ptr("grace@example.com"), ptr("Engineering"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := handle.CreateAccount(ptr(types.RoleMentor), &mentor, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, handle.ActivateAccount(&token, ptr(passwords[org])))
+	}
+	for org, password := range passwords {
+		principal, _, err := sq.Login(ptr("grace@example.com"), ptr(password))
+		if err != nil || principal.OrgID != org {
+			t.Fatalf("expected a login to organization %d, got %+v, %v", org, principal, err)
+		}
+	}
+
+	cfg := &config.LoginThrottle{MaxFailures: 1, IPMaxFailures: 10, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Hour, LockoutDuration: time.Hour}
+	for _, email := range []string{"grace@example.com", "linus@example.com"} {
+		if _, err := sq.ReserveLoginAttempt(ptr(email), ptr("10.0.0."+email[:1]), cfg); err != nil {
+			t.Fatal(err)
+		}
+		check(t, sq.RecordLoginFailure(ptr(email), ptr("10.0.0."+email[:1]), storage.ReasonInvalidCredentials))
+	}
+	// linus belongs to no organization, so nobody sees or unlocks his attempts
+	attempts, err := sq.ForOrg(orgId).GetLoginAttempts(&types.LoginAttemptFilter{})
+	if err != nil || len(attempts) != 1 || attempts[0].Email != "grace@example.com" {
+		t.Fatalf("expected only the attempt of the organization's mentor, got %+v, %v", attempts, err)
+	}
+	if n, err := sq.ForOrg(orgId).UnlockLogin(ptr("linus@example.com"), ptr("10.0.0.l")); err != nil || n != 0 {
+		t.Fatalf("a lockout of another organization was lifted: %d, %v", n, err)
+	}
+	if n, err := sq.ForOrg(orgId).UnlockLogin(ptr("grace@example.com"), ptr("10.0.0.g")); err != nil || n != 2 {
+		t.Fatalf("expected the email and ip lockouts to be lifted, got %d, %v", n, err)
+	}
+}
+
+// TestTwoFactor checks that a TOTP code is only accepted once and recovery codes are single use
+func TestTwoFactor(t *testing.T) {
+	sq := openSqlite(t)
+	admin, err := sq.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := sq.BeginTwoFactor(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := sq.ConfirmTwoFactor(admin, &code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovery) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(recovery))
+	}
+
+	// the code that confirmed the setup can't log in, the next one can but only once
+	if err := sq.VerifyTwoFactor(admin, &code); !errors.Is(err, storage.ErrInvalidTwoFactorCode) {
+		t.Fatalf("the confirming code was replayed: %v", err)
+	}
+	next, err := totp.Code(secret, step+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, sq.VerifyTwoFactor(admin, &next))
+	if err := sq.VerifyTwoFactor(admin, &next); !errors.Is(err, storage.ErrInvalidTwoFactorCode) {
+		t.Fatalf("a code was accepted twice: %v", err)
+	}
+
+	check(t, sq.VerifyTwoFactor(admin, &recovery[0]))
+	if err := sq.VerifyTwoFactor(admin, &recovery[0]); !errors.Is(err, storage.ErrInvalidTwoFactorCode) {
+		t.Fatalf("a recovery code was accepted twice: %v", err)
+	}
+	// codes can be typed without the dash and in upper case
+	typed := strings.ToUpper(strings.ReplaceAll(recovery[1], "-", ""))
+	check(t, sq.VerifyTwoFactor(admin, &typed))
+	if status, err := sq.GetTwoFactorStatus(admin); err != nil || !status.Enabled || status.RecoveryCodesLeft != 8 {
+		t.Fatalf("expected 8 recovery codes left, got %+v, %v", status, err)
+	}
+
+	fresh, err := sq.RegenerateRecoveryCodes(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sq.VerifyTwoFactor(admin, &recovery[2]); !errors.Is(err, storage.ErrInvalidTwoFactorCode) {
+		t.Fatalf("a recovery code still worked after regenerating: %v", err)
+	}
+	check(t, sq.VerifyTwoFactor(admin, &fresh[0]))
+}
+
+// TestTokenCutoff checks that a password reset refuses the tokens issued before it and accepts
+// the ones issued right after it
+func TestTokenCutoff(t *testing.T) {
+	loadKeys(t)
+	sq := openSqlite(t)
+	_, err := sq.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, before, err := sq.Login(ptr("ada@example.com"), ptr("a long enough passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	principal, reset, err := sq.CreatePasswordReset(ptr("ada@example.com"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, sq.ResetPassword(&reset, ptr("another long passphrase")))
+	_, after, err := sq.Login(ptr("ada@example.com"), ptr("another long passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := jwt.CreateChallengeToken(principal, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := func(token string, validate func(string) (*types.CustomClaims, error)) bool {
+		t.Helper()
+		claims, err := validate(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revoked, err := sq.IsTokenRevoked(context.Background(), claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return revoked
+	}
+	if !revoked(before, jwt.ValidateToken) {
+		t.Fatal("a token issued before the reset is still accepted")
+	}
+	if revoked(after, jwt.ValidateToken) {
+		t.Fatal("a token issued right after the reset is refused")
+	}
+	if revoked(challenge, jwt.ValidateChallengeToken) {
+		t.Fatal("a challenge token issued right after the reset is refused")
+	}
+}
+
+// TestSignup checks that only one admin gets in while signup is closed, also when two sign up at
+// once, and that they land in the default organization
+func TestSignup(t *testing.T) {
+	sq := openSqlite(t)
+	ids := make(chan int64, 2)
+	errs := make(chan error, 2)
+	for _, name := range []string{"ada", "linus"} {
+		go func() {
+			id, err := sq.Signup(ptr(name), ptr(name+"@example.com"), ptr("a long enough passphrase"), false)
+			if err != nil {
+				errs <- err
+				return
+			}
+			ids <- id
+		}()
+	}
+	id := <-ids
+	if err := <-errs; !errors.Is(err, storage.ErrSignupClosed) {
+		t.Fatalf("the second signup wasn't refused as closed: %v", err)
+	}
+	if orgs, err := sq.GetOrganizations(id); err != nil || len(orgs) != 1 || orgs[0].Id != storage.DefaultOrgID {
+		t.Fatalf("the first admin isn't in the default organization: %+v, %v", orgs, err)
+	}
+
+	if _, err := sq.Signup(ptr("grace"), ptr("grace@example.com"), ptr("a long enough passphrase"), true); err != nil {
+		t.Fatalf("open signup was refused: %v", err)
+	}
+}
+
+// login signs ada in and starts a session, returning the principal with the session id and the
+// refresh token
+func login(t *testing.T, sq *storage.Sqlite, password string) (*types.Principal, string) {
+	t.Helper()
+	principal, _, err := sq.Login(ptr("ada@example.com"), &password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	family, refresh, err := sq.StartSession(principal, "test", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal.SessionID = family
+	return principal, refresh
+}
+
+// TestRefreshRotation checks that a refresh token can be exchanged once, and that presenting it
+// again ends the whole session
+func TestRefreshRotation(t *testing.T) {
+	loadKeys(t)
+	sq := openSqlite(t)
+	if _, err := sq.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase")); err != nil {
+		t.Fatal(err)
+	}
+	session, first := login(t, sq, "a long enough passphrase")
+
+	principal, second, err := sq.RotateRefreshToken(&first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.SessionID != session.SessionID || second == first {
+		t.Fatalf("expected a new token of the same session, got %+v", principal)
+	}
+	_, third, err := sq.RotateRefreshToken(&second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first token was rotated already, someone else has a copy of it
+	if _, _, err := sq.RotateRefreshToken(&first); !errors.Is(err, storage.ErrRefreshTokenReused) {
+		t.Fatalf("expected the reuse to be detected, got %v", err)
+	}
+	if _, _, err := sq.RotateRefreshToken(&third); !errors.Is(err, storage.ErrInvalidRefreshToken) {
+		t.Fatalf("the latest token of the family survived the reuse: %v", err)
+	}
+	if revoked, err := sq.IsTokenRevoked(context.Background(), &types.CustomClaims{Role: types.RoleAdmin, ID: session.ID, SessionID: session.SessionID}); err != nil || !revoked {
+		t.Fatalf("access tokens of the session are still accepted: %v", err)
+	}
+
+	// logging out ends the session without touching the others
+	other, refresh := login(t, sq, "a long enough passphrase")
+	kept, keptRefresh := login(t, sq, "a long enough passphrase")
+	check(t, sq.RevokeRefreshFamily(other, &refresh))
+	if _, _, err := sq.RotateRefreshToken(&refresh); !errors.Is(err, storage.ErrInvalidRefreshToken) {
+		t.Fatalf("a logged out refresh token still works: %v", err)
+	}
+	if principal, _, err := sq.RotateRefreshToken(&keptRefresh); err != nil || principal.SessionID != kept.SessionID {
+		t.Fatalf("logging out ended another session: %+v, %v", principal, err)
+	}
+}
+
+// TestPasswordReset checks that only the latest reset link works, once and before it expires,
+// and that using it ends every session
+func TestPasswordReset(t *testing.T) {
+	loadKeys(t)
+	sq := openSqlite(t)
+	if _, err := sq.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase")); err != nil {
+		t.Fatal(err)
+	}
+	if principal, token, err := sq.CreatePasswordReset(ptr("nobody@example.com"), time.Hour); err != nil || principal != nil || token != "" {
+		t.Fatalf("an unknown email got a reset link: %+v, %v", principal, err)
+	}
+	_, refresh := login(t, sq, "a long enough passphrase")
+
+	_, replaced, err := sq.CreatePasswordReset(ptr("ada@example.com"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, latest, err := sq.CreatePasswordReset(ptr("ada@example.com"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sq.ResetPassword(&replaced, ptr("another long passphrase")); !errors.Is(err, storage.ErrInvalidResetToken) {
+		t.Fatalf("a replaced reset link still works: %v", err)
+	}
+	check(t, sq.ResetPassword(&latest, ptr("another long passphrase")))
+	if err := sq.ResetPassword(&latest, ptr("a third long passphrase")); !errors.Is(err, storage.ErrInvalidResetToken) {
+		t.Fatalf("a reset link was used twice: %v", err)
+	}
+	if _, _, err := sq.RotateRefreshToken(&refresh); !errors.Is(err, storage.ErrInvalidRefreshToken) {
+		t.Fatalf("a session survived the reset: %v", err)
+	}
+	if _, _, err := sq.Login(ptr("ada@example.com"), ptr("a long enough passphrase")); !errors.Is(err, storage.ErrInvalidCredentials) {
+		t.Fatalf("the old password still logs in: %v", err)
+	}
+
+	_, expired, err := sq.CreatePasswordReset(ptr("ada@example.com"), -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sq.ResetPassword(&expired, ptr("a third long passphrase")); !errors.Is(err, storage.ErrInvalidResetToken) {
+		t.Fatalf("an expired reset link was accepted: %v", err)
+	}
+	_, refresh = login(t, sq, "another long passphrase")
+	if _, _, err := sq.RotateRefreshToken(&refresh); err != nil {
+		t.Fatalf("a refused reset ended the session: %v", err)
+	}
+}
+
+// TestLoginThrottle checks the backoff and lockout of an email, the lockout of an ip, and that a
+// successful login hands its reservations back
+func TestLoginThrottle(t *testing.T) {
+	sq := openSqlite(t)
+	reserve := func(email string, ip string, cfg *config.LoginThrottle) time.Duration {
+		t.Helper()
+		wait, err := sq.ReserveLoginAttempt(&email, &ip, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wait
+	}
+
+	// every attempt doubles the wait before the next one, a success clears it
+	backoff := &config.LoginThrottle{MaxFailures: 10, IPMaxFailures: 100, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour, LockoutDuration: time.Hour}
+	if wait := reserve("ada@example.com", "10.0.0.1", backoff); wait != 0 {
+		t.Fatalf("the first attempt had to wait %s", wait)
+	}
+	if wait := reserve("ada@example.com", "10.0.0.1", backoff); wait < 59*time.Second || wait > time.Minute {
+		t.Fatalf("expected to wait the base delay, got %s", wait)
+	}
+	check(t, sq.RecordLoginSuccess(ptr("ada@example.com"), ptr("10.0.0.1")))
+	if wait := reserve("ada@example.com", "10.0.0.1", backoff); wait != 0 {
+		t.Fatalf("a success didn't clear the backoff, got %s", wait)
+	}
+
+	// delays below a second don't hold anyone back, the lockout after MaxFailures does
+	lockout := &config.LoginThrottle{MaxFailures: 3, IPMaxFailures: 100, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Window: time.Hour, LockoutDuration: time.Hour}
+	for i := 0; i < 3; i++ {
+		if wait := reserve("grace@example.com", "10.0.0.2", lockout); wait != 0 {
+			t.Fatalf("attempt %d had to wait %s", i+1, wait)
+		}
+	}
+	if wait := reserve("grace@example.com", "10.0.0.2", lockout); wait < 59*time.Minute || wait > time.Hour {
+		t.Fatalf("expected the email to be locked for the lockout duration, got %s", wait)
+	}
+	if wait := reserve("linus@example.com", "10.0.0.2", lockout); wait != 0 {
+		t.Fatalf("the lockout of an email held back another one: %s", wait)
+	}
+
+	// the ip counts attempts across emails, a success hands its attempt back
+	ip := &config.LoginThrottle{MaxFailures: 10, IPMaxFailures: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Window: time.Hour, LockoutDuration: time.Hour}
+	reserve("a@example.com", "10.0.0.3", ip)
+	check(t, sq.RecordLoginSuccess(ptr("a@example.com"), ptr("10.0.0.3")))
+	reserve("b@example.com", "10.0.0.3", ip)
+	if wait := reserve("c@example.com", "10.0.0.3", ip); wait != 0 {
+		t.Fatalf("the successful attempt still counted against the ip: %s", wait)
+	}
+	if wait := reserve("d@example.com", "10.0.0.3", ip); wait < 59*time.Minute {
+		t.Fatalf("expected the ip to be locked, got %s", wait)
+	}
+}
+
+// TestPasswordRehash checks that a login upgrades a hash made with a weaker setting, from a lower
+// bcrypt cost and from bcrypt to argon2id
+func TestPasswordRehash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+	connect := func(cfg config.Password) *storage.Sqlite {
+		t.Helper()
+		full := testConfig("sqlite", "", path)
+		full.Password = cfg
+		sq, err := storage.ConnectDB(full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sq.DB.Close() })
+		return sq
+	}
+	stored := func(sq *storage.Sqlite) string {
+		t.Helper()
+		var hash string
+		if err := sq.DB.QueryRow("SELECT password FROM Admin WHERE email=?", "ada@example.com").Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	loadKeys(t)
+	const passphrase = "a long enough passphrase"
+
+	sq := connect(config.Password{MinLength: 12, MaxLength: 72, Hash: "bcrypt", BcryptCost: 4})
+	if _, err := sq.Admins(storagetest.Org(storage.DefaultOrgID)).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr(passphrase)); err != nil {
+		t.Fatal(err)
+	}
+	if hash := stored(sq); !strings.HasPrefix(hash, "$2a$04$") {
+		t.Fatalf("expected a bcrypt hash of cost 4, got %s", hash)
+	}
+
+	for _, step := range []struct {
+		cfg    config.Password
+		prefix string
+	}{
+		{config.Password{MinLength: 12, MaxLength: 72, Hash: "bcrypt", BcryptCost: 5}, "$2a$05$"},
+		{config.Password{MinLength: 12, MaxLength: 72, Hash: "argon2id", Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}, "$argon2id$"},
+	} {
+		sq := connect(step.cfg)
+		if _, _, err := sq.Login(ptr("ada@example.com"), ptr(passphrase)); err != nil {
+			t.Fatal(err)
+		}
+		if hash := stored(sq); !strings.HasPrefix(hash, step.prefix) {
+			t.Fatalf("the login didn't upgrade the hash to %s: %s", step.prefix, hash)
+		}
+		// a wrong password never rewrites the hash
+		before := stored(sq)
+		if _, _, err := sq.Login(ptr("ada@example.com"), ptr("not the passphrase")); !errors.Is(err, storage.ErrInvalidCredentials) {
+			t.Fatalf("a wrong password logged in: %v", err)
+		}
+		if stored(sq) != before {
+			t.Fatal("a failed login changed the hash")
+		}
+	}
+}
@@ -0,0 +1,814 @@
+// Package storagetest is the behaviour every storage.Repositories implementation has to share.
+// A backend runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Repositories { return openBackend(t) })
+//	}
+//
+// PostgresDSN gives the postgres backend an empty schema per store on a locally started server.
+//
+// open must return an empty store whose password policy refuses passwords shorter than 12
+// characters and ones equal to the username, as the defaults of config.Password do.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/middleware/requestid"
+	"github.com/Aytaditya/slotwise/internal/password"
+	"github.com/Aytaditya/slotwise/internal/storage"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+// Org returns a context whose requests work in the organization, as if an admin of it was
+// authenticated
+func Org(orgId int64) context.Context {
+	return jwt.ContextWithClaims(context.Background(), &types.CustomClaims{Role: types.RoleAdmin, OrgID: orgId})
+}
+
+// Run checks a fresh store from open for each group of cases
+func Run(t *testing.T, open func(t *testing.T) storage.Repositories) {
+	t.Run("Mentors", func(t *testing.T) { testMentors(t, open(t)) })
+	t.Run("Interns", func(t *testing.T) { testInterns(t, open(t)) })
+	t.Run("Projects", func(t *testing.T) { testProjects(t, open(t)) })
+	t.Run("Assignments", func(t *testing.T) { testAssignments(t, open(t)) })
+	t.Run("Deletions", func(t *testing.T) { testDeletions(t, open(t)) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, open(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, open(t)) })
+	t.Run("Audit", func(t *testing.T) { testAudit(t, open(t)) })
+	t.Run("History", func(t *testing.T) { testHistory(t, open(t)) })
+	t.Run("Admins", func(t *testing.T) { testAdmins(t, open(t)) })
+	t.Run("Accounts", func(t *testing.T) { testAccounts(t, open(t)) })
+	t.Run("NoOrganization", func(t *testing.T) { testNoOrganization(t, open(t)) })
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// result carries what a repository call returned until the test checks it with must
+type result[T any] struct {
+	v   T
+	err error
+}
+
+func ok[T any](v T, err error) result[T] {
+	return result[T]{v, err}
+}
+
+func (r result[T]) must(t *testing.T) T {
+	t.Helper()
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	return r.v
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func addMentor(t *testing.T, repos storage.Repositories, orgId int64, email string) int64 {
+	t.Helper()
+	return ok(repos.Mentors(Org(orgId)).AddMentor(ptr("Mentor "+email), ptr(email), ptr("Engineering"))).must(t)
+}
+
+func addIntern(t *testing.T, repos storage.Repositories, orgId int64, email string, mentorId int64) int64 {
+	t.Helper()
+	return ok(repos.Interns(Org(orgId)).AddIntern(ptr("Intern "+email), ptr(email), &mentorId)).must(t)
+}
+
+func addProject(t *testing.T, repos storage.Repositories, orgId int64, name string) int64 {
+	t.Helper()
+	return ok(repos.Projects(Org(orgId)).AddProject(ptr(name), ptr("about "+name), ptr("2025-01-01"), ptr("2025-06-30"))).must(t)
+}
+
+func testMentors(t *testing.T, repos storage.Repositories) {
+	mentors := repos.Mentors(Org(1))
+	id := addMentor(t, repos, 1, "ada@example.com")
+	other := addMentor(t, repos, 1, "grace@example.com")
+	addMentor(t, repos, 2, "linus@example.com")
+
+	if _, err := mentors.AddMentor(ptr("Copy"), ptr("ada@example.com"), ptr("Design")); err == nil {
+		t.Fatal("a second mentor with the same email was added")
+	}
+	if _, err := mentors.AddMentor(nil, ptr("x@example.com"), ptr("Design")); err == nil {
+		t.Fatal("a mentor without a name was added")
+	}
+
+	list := ok(mentors.GetMentors(&types.Scope{})).must(t)
+	if len(list) != 2 || list[0].Id != id || list[1].Id != other {
+		t.Fatalf("expected the two mentors of the organization in id order, got %+v", list)
+	}
+	if list[0].Email != "ada@example.com" || list[0].Department != "Engineering" {
+		t.Fatalf("mentor fields were not stored: %+v", list[0])
+	}
+	scoped := ok(mentors.GetMentors(&types.Scope{MentorId: other})).must(t)
+	if len(scoped) != 1 || scoped[0].Id != other {
+		t.Fatalf("a mentor scope should list only that mentor, got %+v", scoped)
+	}
+	if denied := ok(mentors.GetMentors(&types.Scope{MentorId: -1, InternId: -1})).must(t); len(denied) != 0 {
+		t.Fatalf("the deny scope listed mentors: %+v", denied)
+	}
+
+	check(t, mentors.UpdateMentor(&id, ptr("Ada"), ptr("ada@example.org"), ptr("Research"), nil))
+	list = ok(mentors.GetMentors(&types.Scope{MentorId: id})).must(t)
+	if len(list) != 1 || list[0].Name != "Ada" || list[0].Email != "ada@example.org" || list[0].Department != "Research" {
+		t.Fatalf("update was not applied: %+v", list)
+	}
+	if err := mentors.UpdateMentor(&id, ptr("Ada"), ptr("grace@example.com"), ptr("Research"), nil); err == nil {
+		t.Fatal("a mentor took the email of another one")
+	}
+
+	// rows of another organization can't be reached
+	other2 := ok(repos.Mentors(Org(2)).GetMentors(&types.Scope{})).must(t)
+	if len(other2) != 1 {
+		t.Fatalf("expected one mentor in the second organization, got %+v", other2)
+	}
+	check(t, mentors.UpdateMentor(&other2[0].Id, ptr("Taken"), ptr("taken@example.com"), ptr("Sales"), nil))
+	check(t, mentors.DeleteMentor(&other2[0].Id, nil))
+	other2 = ok(repos.Mentors(Org(2)).GetMentors(&types.Scope{})).must(t)
+	if len(other2) != 1 || other2[0].Name == "Taken" {
+		t.Fatalf("another organization changed a mentor: %+v", other2)
+	}
+	// emails are only unique within an organization, another one may use them too
+	addMentor(t, repos, 2, "grace@example.com")
+
+	check(t, mentors.DeleteMentor(&id, nil))
+	if list = ok(mentors.GetMentors(&types.Scope{})).must(t); len(list) != 1 || list[0].Id != other {
+		t.Fatalf("deleted mentor is still listed: %+v", list)
+	}
+	if err := mentors.DeleteMentor(nil, nil); err == nil {
+		t.Fatal("delete without an id succeeded")
+	}
+}
+
+func testInterns(t *testing.T, repos storage.Repositories) {
+	interns := repos.Interns(Org(1))
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	secondMentor := addMentor(t, repos, 1, "grace@example.com")
+	foreignMentor := addMentor(t, repos, 2, "linus@example.com")
+
+	// a reference to another organization's row is a bad field, not a missing record
+	var invalid *password.ValidationError
+	if _, err := interns.AddIntern(ptr("Lost"), ptr("lost@example.com"), &foreignMentor); !errors.As(err, &invalid) || len(invalid.Fields["mentor_id"]) != 1 {
+		t.Fatalf("expected a validation error on mentor_id for a mentor of another organization, got %v", err)
+	}
+	if _, err := interns.AddIntern(ptr("Lost"), ptr("lost@example.com"), ptr(int64(999))); err == nil {
+		t.Fatal("an intern was added under a mentor that doesn't exist")
+	}
+
+	id := addIntern(t, repos, 1, "alan@example.com", mentorId)
+	second := addIntern(t, repos, 1, "barbara@example.com", secondMentor)
+	if _, err := interns.AddIntern(ptr("Copy"), ptr("alan@example.com"), &mentorId); err == nil {
+		t.Fatal("a second intern with the same email was added")
+	}
+	addIntern(t, repos, 2, "alan@example.com", foreignMentor)
+
+	list := ok(interns.GetInterns(&types.Scope{})).must(t)
+	if len(list) != 2 || list[0].ID != id || list[1].ID != second {
+		t.Fatalf("expected both interns in id order, got %+v", list)
+	}
+	if list[0].Status != "active" || list[0].MentorName != "Mentor ada@example.com" || list[0].MentorEmail != "ada@example.com" {
+		t.Fatalf("intern is missing its status or mentor: %+v", list[0])
+	}
+	if byMentor := ok(interns.GetInterns(&types.Scope{MentorId: secondMentor})).must(t); len(byMentor) != 1 || byMentor[0].ID != second {
+		t.Fatalf("a mentor scope should list that mentor's interns, got %+v", byMentor)
+	}
+	if byIntern := ok(interns.GetInterns(&types.Scope{InternId: id})).must(t); len(byIntern) != 1 || byIntern[0].ID != id {
+		t.Fatalf("an intern scope should list only that intern, got %+v", byIntern)
+	}
+
+	check(t, interns.UpdateIntern(&id, ptr("Alan"), ptr("alan@example.org"), &secondMentor, ptr("completed"), nil))
+	list = ok(interns.GetInterns(&types.Scope{InternId: id})).must(t)
+	if len(list) != 1 || list[0].Name != "Alan" || list[0].Status != "completed" || list[0].MentorEmail != "grace@example.com" {
+		t.Fatalf("update was not applied: %+v", list)
+	}
+	if err := interns.UpdateIntern(&id, ptr("Alan"), ptr("alan@example.org"), &foreignMentor, ptr("active"), nil); err == nil {
+		t.Fatal("an intern was moved to a mentor of another organization")
+	}
+
+	// a mentor can't be deleted while interns are assigned to them
+	var blocked *storage.DependentsError
+	err := repos.Mentors(Org(1)).DeleteMentor(&secondMentor, nil)
+	if !errors.As(err, &blocked) || !errors.Is(err, storage.ErrHasDependents) || len(blocked.Dependents) != 2 {
+		t.Fatalf("expected both interns of the mentor to block the delete, got %v", err)
+	}
+	if d := blocked.Dependents[0]; d.Entity != "intern" || d.Id != id || d.Name != "Alan" {
+		t.Fatalf("blocking intern is wrong: %+v", d)
+	}
+	check(t, repos.Mentors(Org(1)).DeleteMentor(&mentorId, nil))
+	check(t, interns.DeleteIntern(&second, nil))
+	list = ok(interns.GetInterns(&types.Scope{})).must(t)
+	if len(list) != 1 || list[0].ID != id {
+		t.Fatalf("expected only the remaining intern, got %+v", list)
+	}
+
+	// an intern without a mentor is still listed, with the mentor left blank
+	check(t, interns.UpdateIntern(&id, ptr("Alan"), ptr("alan@example.org"), nil, ptr("active"), nil))
+	list = ok(interns.GetInterns(&types.Scope{})).must(t)
+	if len(list) != 1 || list[0].ID != id || list[0].MentorId != "" || list[0].MentorName != "" || list[0].MentorEmail != "" {
+		t.Fatalf("expected the intern without a mentor, got %+v", list)
+	}
+	if intern := ok(interns.GetIntern(&id)).must(t); intern.MentorId != "" {
+		t.Fatalf("intern without a mentor was read with one: %+v", intern)
+	}
+	if foreign := ok(repos.Interns(Org(2)).GetInterns(&types.Scope{})).must(t); len(foreign) != 1 || foreign[0].MentorEmail != "linus@example.com" {
+		t.Fatalf("interns leaked into another organization: %+v", foreign)
+	}
+}
+
+func testProjects(t *testing.T, repos storage.Repositories) {
+	projects := repos.Projects(Org(1))
+	id := addProject(t, repos, 1, "Website")
+	addProject(t, repos, 2, "Secret")
+
+	list := ok(projects.GetProjects()).must(t)
+	if len(list) != 1 || list[0].Id != id || list[0].Status != "ongoing" || list[0].StartDate != "2025-01-01" || list[0].EndDate != "2025-06-30" {
+		t.Fatalf("expected the new ongoing project, got %+v", list)
+	}
+	if _, err := projects.AddProject(ptr("Incomplete"), nil, ptr("2025-01-01"), ptr("2025-02-01")); err == nil {
+		t.Fatal("a project without a description was added")
+	}
+
+	check(t, projects.UpdateProject(&id, ptr("Website v2"), ptr("rebuilt"), ptr("completed"), ptr("2025-02-01"), ptr("2025-07-31"), nil))
+	list = ok(projects.GetProjects()).must(t)
+	if len(list) != 1 || list[0].Name != "Website v2" || list[0].Status != "completed" || list[0].EndDate != "2025-07-31" {
+		t.Fatalf("update was not applied: %+v", list)
+	}
+
+	foreign := ok(repos.Projects(Org(2)).GetProjects()).must(t)
+	check(t, projects.DeleteProject(&foreign[0].Id, nil))
+	if foreign = ok(repos.Projects(Org(2)).GetProjects()).must(t); len(foreign) != 1 {
+		t.Fatalf("another organization deleted a project: %+v", foreign)
+	}
+
+	check(t, projects.DeleteProject(&id, nil))
+	if list = ok(projects.GetProjects()).must(t); len(list) != 0 {
+		t.Fatalf("deleted project is still listed: %+v", list)
+	}
+}
+
+func testAssignments(t *testing.T, repos storage.Repositories) {
+	assignments := repos.Assignments(Org(1))
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	otherMentor := addMentor(t, repos, 1, "grace@example.com")
+	internId := addIntern(t, repos, 1, "alan@example.com", mentorId)
+	otherIntern := addIntern(t, repos, 1, "barbara@example.com", otherMentor)
+	projectId := addProject(t, repos, 1, "Website")
+	foreignProject := addProject(t, repos, 2, "Secret")
+
+	if _, err := assignments.AddAssignment(&internId, &foreignProject, ptr("")); err == nil {
+		t.Fatal("an assignment to a project of another organization was added")
+	}
+	if _, err := assignments.AddAssignment(ptr(int64(999)), &projectId, ptr("")); err == nil {
+		t.Fatal("an assignment for an intern that doesn't exist was added")
+	}
+
+	id := ok(assignments.AddAssignment(&internId, &projectId, ptr("frontend"))).must(t)
+	second := ok(assignments.AddAssignment(&otherIntern, &projectId, ptr("backend"))).must(t)
+
+	list := ok(assignments.GetAssignmets(&types.Scope{})).must(t)
+	if len(list) != 2 || list[0].Id != id || list[1].Id != second {
+		t.Fatalf("expected both assignments in id order, got %+v", list)
+	}
+	if list[0].Progress != 0 || list[0].Remarks != "frontend" || list[0].InternName != "Intern alan@example.com" || list[0].ProjectName != "Website" {
+		t.Fatalf("assignment fields are wrong: %+v", list[0])
+	}
+	if byMentor := ok(assignments.GetAssignmets(&types.Scope{MentorId: otherMentor})).must(t); len(byMentor) != 1 || byMentor[0].Id != second {
+		t.Fatalf("a mentor scope should list the assignments of their interns, got %+v", byMentor)
+	}
+	if byIntern := ok(assignments.GetAssignmets(&types.Scope{InternId: internId})).must(t); len(byIntern) != 1 || byIntern[0].Id != id {
+		t.Fatalf("an intern scope should list their own assignments, got %+v", byIntern)
+	}
+
+	check(t, assignments.UpdateAssignment(&id, &otherIntern, &projectId, ptr(int64(60)), ptr("halfway"), nil))
+	list = ok(assignments.GetAssignmets(&types.Scope{InternId: otherIntern})).must(t)
+	if len(list) != 2 || list[0].Progress != 60 || list[0].Remarks != "halfway" {
+		t.Fatalf("update was not applied: %+v", list)
+	}
+	var invalid *password.ValidationError
+	if err := assignments.UpdateAssignment(&id, &otherIntern, &foreignProject, ptr(int64(60)), ptr(""), nil); !errors.As(err, &invalid) || len(invalid.Fields["project_id"]) != 1 {
+		t.Fatalf("expected a validation error on project_id for a project of another organization, got %v", err)
+	}
+
+	// a project can't be deleted while interns work on it, an intern's assignments go with them
+	var blocked *storage.DependentsError
+	err := repos.Projects(Org(1)).DeleteProject(&projectId, nil)
+	if !errors.As(err, &blocked) || len(blocked.Dependents) != 2 {
+		t.Fatalf("expected both assignments to block the delete, got %v", err)
+	}
+	if d := blocked.Dependents[0]; d.Entity != "assignment" || d.Id != id || d.Name != "Intern barbara@example.com on Website" {
+		t.Fatalf("blocking assignment is wrong: %+v", d)
+	}
+	check(t, repos.Interns(Org(1)).DeleteIntern(&otherIntern, nil))
+	if list = ok(assignments.GetAssignmets(&types.Scope{})).must(t); len(list) != 0 {
+		t.Fatalf("assignments of a deleted intern are still listed: %+v", list)
+	}
+	check(t, repos.Projects(Org(1)).DeleteProject(&projectId, nil))
+	check(t, assignments.DeleteAssignment(&id, nil))
+	if err := assignments.DeleteAssignment(nil, nil); err == nil {
+		t.Fatal("delete without an id succeeded")
+	}
+}
+
+func testDeletions(t *testing.T, repos storage.Repositories) {
+	deletions := repos.Deletions(Org(1))
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	otherMentor := addMentor(t, repos, 1, "grace@example.com")
+	foreignMentor := addMentor(t, repos, 2, "hedy@example.com")
+	internId := addIntern(t, repos, 1, "alan@example.com", mentorId)
+	projectId := addProject(t, repos, 1, "Website")
+	assignmentId := ok(repos.Assignments(Org(1)).AddAssignment(&internId, &projectId, ptr(""))).must(t)
+
+	impact := ok(deletions.DeleteImpact("mentor", mentorId, nil)).must(t)
+	if impact.Name != "Mentor ada@example.com" || len(impact.Blocked) != 1 || impact.Blocked[0].Id != internId || len(impact.Deleted) != 0 {
+		t.Fatalf("expected the intern to block deleting the mentor, got %+v", impact)
+	}
+	impact = ok(deletions.DeleteImpact("intern", internId, nil)).must(t)
+	if len(impact.Blocked) != 0 || len(impact.Deleted) != 1 || impact.Deleted[0].Id != assignmentId || impact.Deleted[0].Name != "Intern alan@example.com on Website" {
+		t.Fatalf("expected the assignment to go with the intern, got %+v", impact)
+	}
+	impact = ok(deletions.DeleteImpact("project", projectId, nil)).must(t)
+	if len(impact.Blocked) != 1 || impact.Blocked[0].Entity != "assignment" {
+		t.Fatalf("expected the assignment to block deleting the project, got %+v", impact)
+	}
+	impact = ok(deletions.DeleteImpact("mentor", mentorId, &otherMentor)).must(t)
+	if len(impact.Blocked) != 0 || len(impact.Reassigned) != 1 || impact.Reassigned[0].Id != internId {
+		t.Fatalf("expected the intern to be reassigned, got %+v", impact)
+	}
+
+	if _, err := deletions.DeleteImpact("assignment", assignmentId, nil); !errors.Is(err, storage.ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity, got %v", err)
+	}
+	if _, err := deletions.DeleteImpact("mentor", foreignMentor, nil); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("a mentor of another organization was previewed: %v", err)
+	}
+	if err := deletions.DeleteReassigning("mentor", mentorId, mentorId, nil); !errors.Is(err, storage.ErrReassignToSelf) {
+		t.Fatalf("expected ErrReassignToSelf, got %v", err)
+	}
+	if err := deletions.DeleteReassigning("mentor", mentorId, foreignMentor, nil); !errors.Is(err, storage.ErrReassignMissing) {
+		t.Fatalf("interns were reassigned to a mentor of another organization: %v", err)
+	}
+
+	check(t, deletions.DeleteReassigning("mentor", mentorId, otherMentor, nil))
+	interns := ok(repos.Interns(Org(1)).GetInterns(&types.Scope{})).must(t)
+	if len(interns) != 1 || interns[0].MentorName != "Mentor grace@example.com" {
+		t.Fatalf("intern was not moved to the other mentor: %+v", interns)
+	}
+	if mentors := ok(repos.Mentors(Org(1)).GetMentors(&types.Scope{})).must(t); len(mentors) != 1 || mentors[0].Id != otherMentor {
+		t.Fatalf("reassigned mentor was not deleted: %+v", mentors)
+	}
+	if _, err := deletions.DeleteImpact("mentor", mentorId, nil); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected the deleted mentor to be gone, got %v", err)
+	}
+}
+
+func testTrash(t *testing.T, repos storage.Repositories) {
+	trash := repos.Trash(Org(1))
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	internId := addIntern(t, repos, 1, "alan@example.com", mentorId)
+	projectId := addProject(t, repos, 1, "Website")
+	assignmentId := ok(repos.Assignments(Org(1)).AddAssignment(&internId, &projectId, ptr("frontend"))).must(t)
+
+	// deleting the intern trashes their assignment with them, which frees the mentor
+	check(t, repos.Interns(Org(1)).DeleteIntern(&internId, nil))
+	if list := ok(repos.Assignments(Org(1)).GetAssignmets(&types.Scope{})).must(t); len(list) != 0 {
+		t.Fatalf("trashed assignments are still listed: %+v", list)
+	}
+	check(t, repos.Mentors(Org(1)).DeleteMentor(&mentorId, nil))
+	if list := ok(repos.Mentors(Org(1)).GetMentors(&types.Scope{})).must(t); len(list) != 0 {
+		t.Fatalf("trashed mentors are still listed: %+v", list)
+	}
+	if _, err := repos.Interns(Org(1)).AddIntern(ptr("Grace"), ptr("grace@example.com"), &mentorId); err == nil {
+		t.Fatal("an intern was added under a trashed mentor")
+	}
+
+	list := ok(trash.GetTrash("")).must(t)
+	if len(list) != 3 {
+		t.Fatalf("expected the mentor, intern and assignment in the trash, got %+v", list)
+	}
+	for _, record := range list {
+		if record.DeletedAt.IsZero() || time.Since(record.DeletedAt) > time.Minute {
+			t.Fatalf("trashed record has no deletion time: %+v", record)
+		}
+	}
+	if interns := ok(trash.GetTrash("intern")).must(t); len(interns) != 1 || interns[0].Id != internId || interns[0].Name != "Intern alan@example.com" {
+		t.Fatalf("expected only the intern, got %+v", interns)
+	}
+	if _, err := trash.GetTrash("admin"); !errors.Is(err, storage.ErrNotTrashable) {
+		t.Fatalf("expected ErrNotTrashable, got %v", err)
+	}
+	if foreign := ok(repos.Trash(Org(2)).GetTrash("")).must(t); len(foreign) != 0 {
+		t.Fatalf("the trash leaked into another organization: %+v", foreign)
+	}
+	if _, err := repos.Trash(Org(2)).Restore("intern", internId); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("another organization restored an intern: %v", err)
+	}
+
+	// the intern can only come back once their mentor has
+	var blocked *storage.RestoreError
+	_, err := trash.Restore("intern", internId)
+	if !errors.As(err, &blocked) || !errors.Is(err, storage.ErrRestoreBlocked) || len(blocked.Parents) != 1 || blocked.Parents[0].Id != mentorId {
+		t.Fatalf("expected the trashed mentor to block the restore, got %v", err)
+	}
+	if restored := ok(trash.Restore("mentor", mentorId)).must(t); len(restored) != 0 {
+		t.Fatalf("restoring the mentor restored other records: %+v", restored)
+	}
+	restored := ok(trash.Restore("intern", internId)).must(t)
+	if len(restored) != 1 || restored[0].Entity != "assignment" || restored[0].Id != assignmentId {
+		t.Fatalf("expected the assignment to come back with the intern, got %+v", restored)
+	}
+	if list := ok(repos.Assignments(Org(1)).GetAssignmets(&types.Scope{})).must(t); len(list) != 1 || list[0].Remarks != "frontend" {
+		t.Fatalf("restored assignment is not listed: %+v", list)
+	}
+	if _, err := trash.Restore("intern", internId); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound restoring twice, got %v", err)
+	}
+
+	check(t, repos.Assignments(Org(1)).DeleteAssignment(&assignmentId, nil))
+	check(t, repos.Projects(Org(1)).DeleteProject(&projectId, nil))
+	if _, err := trash.Restore("assignment", assignmentId); !errors.Is(err, storage.ErrRestoreBlocked) {
+		t.Fatalf("expected the trashed project to block the restore, got %v", err)
+	}
+
+	purger, isPurger := repos.(storage.TrashPurger)
+	if !isPurger {
+		t.Fatal("the store can't purge its trash")
+	}
+	if purged := ok(purger.PurgeTrash(context.Background(), time.Now().Add(-time.Hour))).must(t); purged != 0 {
+		t.Fatalf("records trashed just now were purged: %d", purged)
+	}
+	if purged := ok(purger.PurgeTrash(context.Background(), time.Now().Add(time.Second))).must(t); purged != 2 {
+		t.Fatalf("expected the assignment and project to be purged, got %d", purged)
+	}
+	if list := ok(trash.GetTrash("")).must(t); len(list) != 0 {
+		t.Fatalf("purged records are still in the trash: %+v", list)
+	}
+	if _, err := trash.Restore("project", projectId); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("a purged project was restored: %v", err)
+	}
+
+	// a trashed mentor's email can be used again, and then they can't come back with it
+	trashed := addMentor(t, repos, 1, "grace@example.com")
+	check(t, repos.Mentors(Org(1)).DeleteMentor(&trashed, nil))
+	addMentor(t, repos, 1, "grace@example.com")
+	if _, err := trash.Restore("mentor", trashed); !errors.Is(err, storage.ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken restoring a mentor whose email was reused, got %v", err)
+	}
+}
+
+func testVersions(t *testing.T, repos storage.Repositories) {
+	mentors := repos.Mentors(Org(1))
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	otherMentor := addMentor(t, repos, 1, "grace@example.com")
+	internId := addIntern(t, repos, 1, "alan@example.com", mentorId)
+	projectId := addProject(t, repos, 1, "Website")
+	assignmentId := ok(repos.Assignments(Org(1)).AddAssignment(&internId, &projectId, ptr(""))).must(t)
+
+	mentor := ok(mentors.GetMentor(&mentorId)).must(t)
+	if mentor.Id != mentorId || mentor.Version != 1 {
+		t.Fatalf("expected a new mentor at version 1, got %+v", mentor)
+	}
+	if _, err := repos.Mentors(Org(2)).GetMentor(&mentorId); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("another organization read a mentor: %v", err)
+	}
+
+	check(t, mentors.UpdateMentor(&mentorId, ptr("Ada"), ptr("ada@example.com"), ptr("Research"), ptr(int64(1))))
+	if mentor = ok(mentors.GetMentor(&mentorId)).must(t); mentor.Version != 2 || mentor.Name != "Ada" {
+		t.Fatalf("expected the update to move the mentor to version 2, got %+v", mentor)
+	}
+	if err := mentors.UpdateMentor(&mentorId, ptr("Stale"), ptr("ada@example.com"), ptr("Research"), ptr(int64(1))); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch updating an old version, got %v", err)
+	}
+	if err := mentors.DeleteMentor(&otherMentor, ptr(int64(7))); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch deleting an old version, got %v", err)
+	}
+	if err := repos.Mentors(Org(2)).UpdateMentor(&mentorId, ptr("Taken"), ptr("ada@example.com"), ptr("Sales"), ptr(int64(2))); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound updating a mentor of another organization, got %v", err)
+	}
+	if mentor = ok(mentors.GetMentor(&mentorId)).must(t); mentor.Name != "Ada" || mentor.Version != 2 {
+		t.Fatalf("a refused change was applied: %+v", mentor)
+	}
+
+	check(t, repos.Interns(Org(1)).UpdateIntern(&internId, ptr("Alan"), ptr("alan@example.com"), &mentorId, ptr("active"), ptr(int64(1))))
+	check(t, repos.Projects(Org(1)).UpdateProject(&projectId, ptr("Site"), ptr(""), ptr("ongoing"), ptr("2025-01-01"), ptr("2025-06-30"), ptr(int64(1))))
+	check(t, repos.Assignments(Org(1)).UpdateAssignment(&assignmentId, &internId, &projectId, ptr(int64(50)), ptr(""), ptr(int64(1))))
+	if project := ok(repos.Projects(Org(1)).GetProject(&projectId)).must(t); project.Version != 2 || project.Name != "Site" {
+		t.Fatalf("expected the project at version 2, got %+v", project)
+	}
+	if assignment := ok(repos.Assignments(Org(1)).GetAssignment(&assignmentId)).must(t); assignment.Version != 2 || assignment.Progress != 50 {
+		t.Fatalf("expected the assignment at version 2, got %+v", assignment)
+	}
+
+	// moving the intern to another mentor changes the intern too
+	if err := repos.Deletions(Org(1)).DeleteReassigning("mentor", mentorId, otherMentor, ptr(int64(1))); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch reassigning from an old version, got %v", err)
+	}
+	check(t, repos.Deletions(Org(1)).DeleteReassigning("mentor", mentorId, otherMentor, ptr(int64(2))))
+	intern := ok(repos.Interns(Org(1)).GetIntern(&internId)).must(t)
+	if intern.Version != 3 || intern.MentorEmail != "grace@example.com" {
+		t.Fatalf("expected the reassigned intern at version 3, got %+v", intern)
+	}
+	if _, err := mentors.GetMentor(&mentorId); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected the deleted mentor to be gone, got %v", err)
+	}
+	if err := mentors.DeleteMentor(&mentorId, ptr(int64(3))); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound deleting a trashed mentor, got %v", err)
+	}
+
+	check(t, repos.Interns(Org(1)).DeleteIntern(&internId, &intern.Version))
+	ok(repos.Trash(Org(1)).Restore("intern", internId)).must(t)
+	if intern = ok(repos.Interns(Org(1)).GetIntern(&internId)).must(t); intern.Version != 5 {
+		t.Fatalf("expected the trip through the trash to take the intern to version 5, got %+v", intern)
+	}
+}
+
+func testAudit(t *testing.T, repos storage.Repositories) {
+	ctx := requestid.NewContext(jwt.ContextWithClaims(context.Background(),
+		&types.CustomClaims{ID: 7, Email: "root@example.com", Role: types.RoleAdmin, OrgID: 1}), "req-1")
+	audit := repos.Audit(ctx)
+	mentorId := ok(repos.Mentors(ctx).AddMentor(ptr("Ada"), ptr("ada@example.com"), ptr("Engineering"))).must(t)
+	otherMentor := addMentor(t, repos, 1, "grace@example.com")
+	internId := ok(repos.Interns(ctx).AddIntern(ptr("Alan"), ptr("alan@example.com"), &mentorId)).must(t)
+	projectId := ok(repos.Projects(ctx).AddProject(ptr("Website"), ptr(""), ptr("2025-01-01"), ptr("2025-06-30"))).must(t)
+	assignmentId := ok(repos.Assignments(ctx).AddAssignment(&internId, &projectId, ptr(""))).must(t)
+
+	check(t, repos.Mentors(ctx).UpdateMentor(&mentorId, ptr("Ada"), ptr("ada@example.com"), ptr("Research"), nil))
+	// changes that change nothing or are refused leave no entry
+	check(t, repos.Mentors(ctx).UpdateMentor(&mentorId, ptr("Ada"), ptr("ada@example.com"), ptr("Research"), nil))
+	if err := repos.Mentors(ctx).UpdateMentor(&mentorId, ptr("Stale"), ptr("ada@example.com"), ptr("Research"), ptr(int64(1))); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	check(t, repos.Deletions(ctx).DeleteReassigning("mentor", mentorId, otherMentor, nil))
+	ok(repos.Trash(ctx).Restore("mentor", mentorId)).must(t)
+
+	history := ok(audit.GetAudit(&storage.AuditFilter{Entity: "mentor", EntityId: mentorId})).must(t)
+	if actions := auditActions(history); actions != "[restore delete update create]" {
+		t.Fatalf("expected the mentor's changes newest first, got %s", actions)
+	}
+	for _, entry := range history {
+		if entry.Entity != "mentor" || entry.ActorId != 7 || entry.ActorRole != types.RoleAdmin || entry.ActorEmail != "root@example.com" || entry.RequestId != "req-1" {
+			t.Fatalf("expected the entry to name the actor and request, got %+v", entry)
+		}
+	}
+	if changes := string(history[2].Changes); changes != `{"department":{"old":"Engineering","new":"Research"}}` {
+		t.Fatalf("expected the update to list the changed field only, got %s", changes)
+	}
+	if changes := string(history[3].Changes); changes != `{"department":{"old":null,"new":"Engineering"},"email":{"old":null,"new":"ada@example.com"},"name":{"old":null,"new":"Ada"}}` {
+		t.Fatalf("expected the creation to list every field, got %s", changes)
+	}
+	if changes := string(history[1].Changes); changes != `{"department":{"old":"Research","new":null},"email":{"old":"ada@example.com","new":null},"name":{"old":"Ada","new":null}}` {
+		t.Fatalf("expected the delete to list what was deleted, got %s", changes)
+	}
+
+	// the reassigned intern got an update of its own
+	history = ok(audit.GetAudit(&storage.AuditFilter{Entity: "intern", EntityId: internId})).must(t)
+	if actions := auditActions(history); actions != "[update create]" {
+		t.Fatalf("expected the intern to be created and reassigned, got %s", actions)
+	}
+	if changes, want := string(history[0].Changes), fmt.Sprintf(`{"mentor_id":{"old":%d,"new":%d}}`, mentorId, otherMentor); changes != want {
+		t.Fatalf("expected %s, got %s", want, changes)
+	}
+
+	// rows deleted and restored along with another get entries too
+	check(t, repos.Interns(ctx).DeleteIntern(&internId, nil))
+	ok(repos.Trash(ctx).Restore("intern", internId)).must(t)
+	history = ok(audit.GetAudit(&storage.AuditFilter{Entity: "assignment", EntityId: assignmentId})).must(t)
+	if actions := auditActions(history); actions != "[restore delete create]" {
+		t.Fatalf("expected the assignment to follow its intern through the trash, got %s", actions)
+	}
+
+	all := ok(audit.GetAudit(&storage.AuditFilter{})).must(t)
+	if len(all) != 13 {
+		t.Fatalf("expected 13 entries in the organization, got %d: %s", len(all), auditActions(all))
+	}
+	if byActor := ok(audit.GetAudit(&storage.AuditFilter{ActorId: 7, ActorRole: types.RoleAdmin})).must(t); len(byActor) != 12 {
+		t.Fatalf("expected the actor filter to leave out the mentor someone else created, got %d entries", len(byActor))
+	}
+	if limited := ok(audit.GetAudit(&storage.AuditFilter{Limit: 2})).must(t); len(limited) != 2 || limited[0].Id != all[0].Id {
+		t.Fatalf("expected the 2 newest entries, got %+v", limited)
+	}
+	now := time.Now()
+	if later := ok(audit.GetAudit(&storage.AuditFilter{Since: now.Add(time.Hour)})).must(t); len(later) != 0 {
+		t.Fatalf("expected no entries after now, got %d", len(later))
+	}
+	if earlier := ok(audit.GetAudit(&storage.AuditFilter{Until: now.Add(-time.Hour)})).must(t); len(earlier) != 0 {
+		t.Fatalf("expected no entries before now, got %d", len(earlier))
+	}
+	if inRange := ok(audit.GetAudit(&storage.AuditFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)})).must(t); len(inRange) != 13 {
+		t.Fatalf("expected every entry within the hour, got %d", len(inRange))
+	}
+	if other := ok(repos.Audit(Org(2)).GetAudit(&storage.AuditFilter{})).must(t); len(other) != 0 {
+		t.Fatalf("another organization read %d audit entries", len(other))
+	}
+	if _, err := audit.GetAudit(&storage.AuditFilter{Entity: "admin"}); !errors.Is(err, storage.ErrNotAudited) {
+		t.Fatalf("expected ErrNotAudited for admins, got %v", err)
+	}
+}
+
+func auditActions(entries []storage.AuditEntry) string {
+	actions := make([]string, len(entries))
+	for i, entry := range entries {
+		actions[i] = entry.Action
+	}
+	return fmt.Sprint(actions)
+}
+
+func testHistory(t *testing.T, repos storage.Repositories) {
+	interns, assignments := repos.Interns(Org(1)), repos.Assignments(Org(1))
+	before := time.Now().Add(-time.Hour)
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	otherMentor := addMentor(t, repos, 1, "grace@example.com")
+	internId := addIntern(t, repos, 1, "alan@example.com", mentorId)
+	projectId := addProject(t, repos, 1, "Website")
+	assignmentId := ok(assignments.AddAssignment(&internId, &projectId, ptr("started"))).must(t)
+	created := time.Now()
+	nextSecond(created)
+
+	check(t, interns.UpdateIntern(&internId, ptr("Alan Turing"), ptr("alan@example.com"), &otherMentor, ptr("active"), nil))
+	check(t, assignments.UpdateAssignment(&assignmentId, &internId, &projectId, ptr(int64(50)), ptr("halfway"), nil))
+	updated := time.Now()
+	nextSecond(updated)
+
+	check(t, interns.DeleteIntern(&internId, nil))
+	deleted := time.Now()
+
+	if _, err := interns.GetInternAsOf(&internId, before); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound before the intern was created, got %v", err)
+	}
+	intern := ok(interns.GetInternAsOf(&internId, created)).must(t)
+	if intern.Name != "Intern alan@example.com" || intern.MentorEmail != "ada@example.com" || intern.Version != 1 {
+		t.Fatalf("expected the intern as created, got %+v", intern)
+	}
+	intern = ok(interns.GetInternAsOf(&internId, updated)).must(t)
+	if intern.Name != "Alan Turing" || intern.MentorEmail != "grace@example.com" || intern.Version != 2 {
+		t.Fatalf("expected the intern as updated, got %+v", intern)
+	}
+	if _, err := interns.GetInternAsOf(&internId, deleted); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound once the intern was deleted, got %v", err)
+	}
+	if list := ok(interns.GetInternsAsOf(&types.Scope{MentorId: mentorId}, created)).must(t); len(list) != 1 || list[0].ID != internId {
+		t.Fatalf("expected the first mentor to have had the intern, got %+v", list)
+	}
+	if list := ok(interns.GetInternsAsOf(&types.Scope{MentorId: mentorId}, updated)).must(t); len(list) != 0 {
+		t.Fatalf("expected the intern to have moved on from the first mentor, got %+v", list)
+	}
+	if list := ok(interns.GetInternsAsOf(nil, deleted)).must(t); len(list) != 0 {
+		t.Fatalf("expected no interns after the delete, got %+v", list)
+	}
+
+	assignment := ok(assignments.GetAssignmentAsOf(&assignmentId, created)).must(t)
+	if assignment.Progress != 0 || assignment.Remarks != "started" || assignment.InternName != "Intern alan@example.com" || assignment.ProjectName != "Website" {
+		t.Fatalf("expected the assignment as created, got %+v", assignment)
+	}
+	assignment = ok(assignments.GetAssignmentAsOf(&assignmentId, updated)).must(t)
+	if assignment.Progress != 50 || assignment.Remarks != "halfway" || assignment.InternName != "Alan Turing" || assignment.Version != 2 {
+		t.Fatalf("expected the assignment as updated, got %+v", assignment)
+	}
+	if list := ok(assignments.GetAssignmentsAsOf(&types.Scope{MentorId: otherMentor}, updated)).must(t); len(list) != 1 || list[0].Id != assignmentId {
+		t.Fatalf("expected the second mentor to see the assignment of their intern, got %+v", list)
+	}
+	if list := ok(assignments.GetAssignmentsAsOf(&types.Scope{MentorId: otherMentor}, created)).must(t); len(list) != 0 {
+		t.Fatalf("expected the second mentor not to have had the intern yet, got %+v", list)
+	}
+	if _, err := assignments.GetAssignmentAsOf(&assignmentId, deleted); !errors.Is(err, storage.ErrRecordNotFound) {
+		t.Fatalf("expected the assignment to be deleted with its intern, got %v", err)
+	}
+	if list := ok(repos.Assignments(Org(2)).GetAssignmentsAsOf(nil, updated)).must(t); len(list) != 0 {
+		t.Fatalf("another organization read %d past assignments", len(list))
+	}
+
+	ok(repos.Trash(Org(1)).Restore("intern", internId)).must(t)
+	if intern = ok(interns.GetInternAsOf(&internId, time.Now().Add(time.Hour))).must(t); intern.Version != 4 {
+		t.Fatalf("expected the restored intern to have a current state again, got %+v", intern)
+	}
+}
+
+// nextSecond waits for the second after t, history is kept in whole seconds
+func nextSecond(t time.Time) {
+	time.Sleep(time.Until(t.Truncate(time.Second).Add(time.Second)))
+}
+
+func testAdmins(t *testing.T, repos storage.Repositories) {
+	admins := repos.Admins(Org(1))
+	const strong = "a long enough passphrase"
+
+	id := ok(admins.CreateAdmin(ptr("ada"), ptr(" ada@example.com "), ptr(strong))).must(t)
+	foreign := ok(repos.Admins(Org(2)).CreateAdmin(ptr("linus"), ptr("linus@example.com"), ptr(strong))).must(t)
+
+	if _, err := admins.CreateAdmin(ptr("ada"), ptr("other@example.com"), ptr(strong)); !errors.Is(err, storage.ErrAdminExists) {
+		t.Fatalf("expected ErrAdminExists for a taken username, got %v", err)
+	}
+	if _, err := admins.CreateAdmin(ptr("grace"), ptr("grace@example.com"), ptr("short")); !hasFieldErrors(err, "password") {
+		t.Fatalf("expected a password validation error, got %v", err)
+	}
+	if _, err := admins.CreateAdmin(ptr("grace"), ptr("grace@example.com"), ptr("")); err == nil {
+		t.Fatal("an admin without a password was created")
+	}
+
+	admin := ok(admins.GetAdmin(&id)).must(t)
+	if admin.Username != "ada" || admin.Email != "ada@example.com" || !admin.Active || admin.TwoFactorEnabled {
+		t.Fatalf("admin fields are wrong: %+v", admin)
+	}
+	if _, err := admins.GetAdmin(&foreign); !errors.Is(err, storage.ErrAdminNotFound) {
+		t.Fatalf("an admin of another organization was visible: %v", err)
+	}
+	if list := ok(admins.GetAdmins()).must(t); len(list) != 1 || list[0].Id != id {
+		t.Fatalf("expected only the admin of the organization, got %+v", list)
+	}
+
+	second := ok(admins.CreateAdmin(ptr("grace"), ptr("grace@example.com"), ptr(strong))).must(t)
+	if err := admins.UpdateAdmin(&second, ptr("ada"), ptr("grace@example.com")); !errors.Is(err, storage.ErrAdminExists) {
+		t.Fatalf("expected ErrAdminExists when taking a username, got %v", err)
+	}
+	check(t, admins.UpdateAdmin(&second, ptr("grace.h"), ptr("grace@example.org")))
+	if admin = ok(admins.GetAdmin(&second)).must(t); admin.Username != "grace.h" || admin.Email != "grace@example.org" {
+		t.Fatalf("update was not applied: %+v", admin)
+	}
+	if err := admins.UpdateAdmin(&foreign, ptr("x"), ptr("x@example.com")); !errors.Is(err, storage.ErrAdminNotFound) {
+		t.Fatalf("an admin of another organization was updated: %v", err)
+	}
+
+	if err := admins.SetAdminPassword(&second, ptr("grace.h")); !hasFieldErrors(err, "password") {
+		t.Fatalf("expected a password validation error, got %v", err)
+	}
+	check(t, admins.SetAdminPassword(&second, ptr("another long passphrase")))
+
+	// the last active admin of an organization can't be switched off or removed, however many
+	// other organizations have
+	if err := repos.Admins(Org(2)).SetAdminActive(&foreign, false); !errors.Is(err, storage.ErrLastActiveAdmin) {
+		t.Fatalf("expected ErrLastActiveAdmin in the other organization, got %v", err)
+	}
+	check(t, admins.SetAdminActive(&second, false))
+	if admin = ok(admins.GetAdmin(&second)).must(t); admin.Active {
+		t.Fatal("deactivated admin is still active")
+	}
+	if err := admins.SetAdminActive(&id, false); !errors.Is(err, storage.ErrLastActiveAdmin) {
+		t.Fatalf("expected ErrLastActiveAdmin, got %v", err)
+	}
+	if err := admins.DeleteAdmin(&id); !errors.Is(err, storage.ErrLastActiveAdmin) {
+		t.Fatalf("expected ErrLastActiveAdmin, got %v", err)
+	}
+	check(t, admins.DeleteAdmin(&second))
+	if _, err := admins.GetAdmin(&second); !errors.Is(err, storage.ErrAdminNotFound) {
+		t.Fatalf("deleted admin is still there: %v", err)
+	}
+	if err := admins.DeleteAdmin(&second); !errors.Is(err, storage.ErrAdminNotFound) {
+		t.Fatalf("expected ErrAdminNotFound deleting twice, got %v", err)
+	}
+	check(t, admins.SetAdminActive(&id, true))
+}
+
+func testAccounts(t *testing.T, repos storage.Repositories) {
+	accounts := repos.Accounts(Org(1))
+	mentorId := addMentor(t, repos, 1, "ada@example.com")
+	foreignMentor := addMentor(t, repos, 2, "linus@example.com")
+
+	token := ok(accounts.CreateAccount(ptr(types.RoleMentor), &mentorId, time.Hour)).must(t)
+	if token == "" {
+		t.Fatal("no activation token was returned")
+	}
+	if again := ok(accounts.CreateAccount(ptr(types.RoleMentor), &mentorId, time.Hour)).must(t); again == token {
+		t.Fatal("asking again returned the same activation token")
+	}
+	if _, err := accounts.CreateAccount(ptr(types.RoleMentor), &foreignMentor, time.Hour); err == nil {
+		t.Fatal("an account was created for a mentor of another organization")
+	}
+	if _, err := accounts.CreateAccount(ptr(types.RoleAdmin), &mentorId, time.Hour); err == nil {
+		t.Fatal("an account was created with the admin role")
+	}
+}
+
+// testNoOrganization checks that requests without an organization can't write anything
+func testNoOrganization(t *testing.T, repos storage.Repositories) {
+	none := context.Background()
+	if _, err := repos.Mentors(none).AddMentor(ptr("Ada"), ptr("ada@example.com"), ptr("Engineering")); !errors.Is(err, storage.ErrNoOrganization) {
+		t.Fatalf("expected ErrNoOrganization, got %v", err)
+	}
+	if _, err := repos.Projects(none).AddProject(ptr("Website"), ptr(""), ptr(""), ptr("")); !errors.Is(err, storage.ErrNoOrganization) {
+		t.Fatalf("expected ErrNoOrganization, got %v", err)
+	}
+	if _, err := repos.Admins(none).CreateAdmin(ptr("ada"), ptr("ada@example.com"), ptr("a long enough passphrase")); !errors.Is(err, storage.ErrNoOrganization) {
+		t.Fatalf("expected ErrNoOrganization, got %v", err)
+	}
+	addMentor(t, repos, 1, "grace@example.com")
+	if list := ok(repos.Mentors(none).GetMentors(&types.Scope{})).must(t); len(list) != 0 {
+		t.Fatalf("a request without an organization listed mentors: %+v", list)
+	}
+}
+
+func hasFieldErrors(err error, field string) bool {
+	var invalid interface {
+		FieldErrors() map[string][]string
+	}
+	return errors.As(err, &invalid) && len(invalid.FieldErrors()[field]) > 0
+}
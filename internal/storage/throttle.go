@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/config"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+const (
+	ReasonInvalidCredentials   = "invalid_credentials"
+	ReasonInvalidTwoFactorCode = "invalid_2fa_code"
+	ReasonLockedOut            = "locked_out"
+)
+
+func emailKey(email string) string { return "email:" + email }
+func ipKey(ip string) string       { return "ip:" + ip }
+
+// ReserveLoginAttempt counts an attempt against the email and the ip before its password or code
+// is checked, so parallel guesses can't all get past the throttle before the first failure is
+// recorded. The email gets an exponential backoff and is locked after MaxFailures, the ip is only
+// locked after IPMaxFailures. When either has to wait nothing is counted and it returns how long,
+// otherwise zero. A successful attempt hands its reservation back with RecordLoginSuccess.
+func (sq *Sqlite) ReserveLoginAttempt(email *string, ip *string, cfg *config.LoginThrottle) (time.Duration, error) {
+	if email == nil || ip == nil {
+		return 0, fmt.Errorf("email and ip are required")
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	reserved, err := reserveThrottle(sq.ctx, tx, emailKey(*email), now, cfg.MaxFailures, cfg, true)
+	if err == nil && reserved {
+		reserved, err = reserveThrottle(sq.ctx, tx, ipKey(*ip), now, cfg.IPMaxFailures, cfg, false)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if reserved {
+		return 0, tx.Commit()
+	}
+	tx.Rollback()
+	return sq.loginRetryAfter(*email, *ip, now)
+}
+
+// loginRetryAfter is how long the email or ip has to wait before the next attempt, zero if allowed
+func (sq *Sqlite) loginRetryAfter(email string, ip string, now time.Time) (time.Duration, error) {
+	var wait int64
+	err := sq.DB.QueryRowContext(sq.ctx, `SELECT COALESCE(MAX(CASE WHEN next_attempt_at>locked_until THEN next_attempt_at ELSE locked_until END),0)
+		FROM LoginThrottles WHERE key IN (?,?)`,
+		emailKey(email), ipKey(ip)).Scan(&wait)
+	if err != nil {
+		return 0, err
+	}
+	if wait <= now.Unix() {
+		// the throttle ran out since the attempt was refused, one second is the shortest Retry-After
+		return time.Second, nil
+	}
+	return time.Unix(wait, 0).Sub(now), nil
+}
+
+// reserveThrottle bumps the counter of the key unless it is backing off or locked, in which case
+// it returns false. The upsert both checks and bumps, so concurrent attempts can't read the same
+// count.
+func reserveThrottle(ctx context.Context, tx *sql.Tx, key string, now time.Time, maxFailures int, cfg *config.LoginThrottle, backoff bool) (bool, error) {
+	var failures int64
+	err := tx.QueryRowContext(ctx, `INSERT INTO LoginThrottles (key,failures,last_failure_at,next_attempt_at,locked_until) VALUES (?,1,?,?,0)
+		ON CONFLICT (key) DO UPDATE SET failures=CASE WHEN LoginThrottles.last_failure_at<? THEN 1 ELSE LoginThrottles.failures+1 END,
+		last_failure_at=excluded.last_failure_at
+		WHERE LoginThrottles.next_attempt_at<=? AND LoginThrottles.locked_until<=?
+		RETURNING failures`,
+		key, now.Unix(), now.Unix(), now.Add(-cfg.Window).Unix(), now.Unix(), now.Unix()).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	next := now
+	if backoff {
+		delay := cfg.BaseDelay << (failures - 1)
+		if delay <= 0 || delay > cfg.MaxDelay {
+			delay = cfg.MaxDelay
+		}
+		next = now.Add(delay)
+	}
+	var lockedUntil int64
+	if maxFailures > 0 && failures >= int64(maxFailures) {
+		lockedUntil = now.Add(cfg.LockoutDuration).Unix()
+		failures = 0
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE LoginThrottles SET failures=?, next_attempt_at=?, locked_until=? WHERE key=?",
+		failures, next.Unix(), lockedUntil, key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordLoginFailure logs a failed or refused attempt, ReserveLoginAttempt already counted it
+func (sq *Sqlite) RecordLoginFailure(email *string, ip *string, reason string) error {
+	if email == nil || ip == nil {
+		return fmt.Errorf("email and ip are required")
+	}
+	_, err := sq.DB.ExecContext(sq.ctx, "INSERT INTO LoginAttempts (email,ip,reason,created_at) VALUES (?,?,?,?)", email, ip, reason, time.Now().Unix())
+	return err
+}
+
+// RecordLoginSuccess clears the email's counter and hands back the ip's reservation; failures
+// of the ip only decay with time
+func (sq *Sqlite) RecordLoginSuccess(email *string, ip *string) error {
+	if email == nil || ip == nil {
+		return nil
+	}
+	_, err := sq.DB.ExecContext(sq.ctx, "DELETE FROM LoginThrottles WHERE key=?", emailKey(*email))
+	if err != nil {
+		return err
+	}
+	_, err = sq.DB.ExecContext(sq.ctx, "UPDATE LoginThrottles SET failures=failures-1 WHERE key=? AND failures>0", ipKey(*ip))
+	return err
+}
+
+// orgLoginEmail matches an expression against the login emails of the handle's organization:
+// its admins, mentors and interns. It takes the organization id three times.
+func orgLoginEmail(expr string) string {
+	return "LOWER(" + expr + `) IN (SELECT LOWER(a.email) FROM Admin as a INNER JOIN AdminOrganizations as m on m.admin_id=a.id WHERE m.org_id=?
+		UNION SELECT LOWER(email) FROM Mentors WHERE org_id=? UNION SELECT LOWER(email) FROM Interns WHERE org_id=?)`
+}
+
+// UnlockLogin lifts the lockout and backoff of an email and/or ip. Only emails of the handle's
+// organization, and ips that attempted to log in with one of them, can be unlocked.
+func (sq *Sqlite) UnlockLogin(email *string, ip *string) (int64, error) {
+	if err := sq.requireOrg(); err != nil {
+		return 0, err
+	}
+	if (email == nil || *email == "") && (ip == nil || *ip == "") {
+		return 0, fmt.Errorf("email or ip is required")
+	}
+	var keys []any
+	if email != nil && *email != "" {
+		var found int
+		err := sq.DB.QueryRowContext(sq.ctx, "SELECT 1 WHERE "+orgLoginEmail("?"), email, sq.orgId, sq.orgId, sq.orgId).Scan(&found)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		if err == nil {
+			keys = append(keys, emailKey(*email))
+		}
+	}
+	if ip != nil && *ip != "" {
+		var found int
+		err := sq.DB.QueryRowContext(sq.ctx, "SELECT 1 FROM LoginAttempts WHERE ip=? AND "+orgLoginEmail("email")+" LIMIT 1",
+			ip, sq.orgId, sq.orgId, sq.orgId).Scan(&found)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		if err == nil {
+			keys = append(keys, ipKey(*ip))
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	query := "DELETE FROM LoginThrottles WHERE key=?"
+	if len(keys) == 2 {
+		query += " OR key=?"
+	}
+	res, err := sq.DB.ExecContext(sq.ctx, query, keys...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetLoginAttempts lists attempts with the login emails of the handle's organization
+func (sq *Sqlite) GetLoginAttempts(filter *types.LoginAttemptFilter) ([]types.ReturnLoginAttempt, error) {
+	if err := sq.requireOrg(); err != nil {
+		return nil, err
+	}
+	query := "SELECT id,email,ip,reason,created_at FROM LoginAttempts WHERE created_at>=? AND " + orgLoginEmail("email")
+	args := []any{filter.Since, sq.orgId, sq.orgId, sq.orgId}
+	if filter.Email != "" {
+		query += " AND email=?"
+		args = append(args, filter.Email)
+	}
+	if filter.IP != "" {
+		query += " AND ip=?"
+		args = append(args, filter.IP)
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := sq.DB.QueryContext(sq.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []types.ReturnLoginAttempt{}
+	for rows.Next() {
+		var attempt types.ReturnLoginAttempt
+		var createdAt int64
+		err1 := rows.Scan(&attempt.Id, &attempt.Email, &attempt.IP, &attempt.Reason, &createdAt)
+		if err1 != nil {
+			return nil, err1
+		}
+		attempt.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RFC3339)
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/middleware/jwt"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, please log in again")
+)
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartSession records a login and starts its refresh token family; the session id doubles as
+// the family id and is put in the access tokens as sid. It returns the session id and the raw
+// refresh token.
+func (sq *Sqlite) StartSession(principal *types.Principal, userAgent string, ip string) (string, string, error) {
+	family, err := jwt.NewTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.ExecContext(sq.ctx, `INSERT INTO Sessions (id,role,user_id,org_id,user_agent,ip,created_at,last_seen_at,expires_at) VALUES (?,?,?,?,?,?,?,?,?)`,
+		family, principal.Role, principal.ID, principal.OrgID, userAgent, ip, now.Unix(), now.Unix(), now.Add(jwt.RefreshTTL()).Unix())
+	if err != nil {
+		return "", "", err
+	}
+	token, err := insertRefreshToken(sq.ctx, tx, principal.Role, principal.ID, family)
+	if err != nil {
+		return "", "", err
+	}
+	if err = tx.Commit(); err != nil {
+		return "", "", err
+	}
+	return family, token, nil
+}
+
+// execer, querier and rowReader are what *sql.DB and *sql.Tx share, so helpers run the same
+// statements in or out of a transaction
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertRefreshToken(ctx context.Context, db execer, role string, userId int64, family string) (string, error) {
+	token, err := jwt.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	_, err = db.ExecContext(ctx, "INSERT INTO RefreshTokens (role,user_id,family_id,token_hash,expires_at,created_at) VALUES (?,?,?,?,?,?)",
+		role, userId, family, hashToken(token), now.Add(jwt.RefreshTTL()).Unix(), now.Unix())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family.
+// Presenting a token that was already rotated revokes the whole family.
+func (sq *Sqlite) RotateRefreshToken(token *string) (*types.Principal, string, error) {
+	if token == nil || *token == "" {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var id, userId, expiresAt int64
+	var role, family string
+	var replacedAt, revokedAt sql.NullInt64
+	row := tx.QueryRowContext(sq.ctx, "SELECT id,role,user_id,family_id,expires_at,replaced_at,revoked_at FROM RefreshTokens WHERE token_hash=?", hashToken(*token))
+	err = row.Scan(&id, &role, &userId, &family, &expiresAt, &replacedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().Unix()
+	if revokedAt.Valid && !replacedAt.Valid {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if replacedAt.Valid {
+		return nil, "", revokeReused(sq.ctx, tx, family)
+	}
+	if expiresAt < now {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	principal, err := findPrincipal(sq.ctx, tx, role, userId)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if role == types.RoleAdmin {
+		// stay in the organization the admin switched to, unless they were removed from it
+		var orgId int64
+		err = tx.QueryRowContext(sq.ctx, `SELECT s.org_id FROM Sessions as s INNER JOIN AdminOrganizations as m
+			on m.admin_id=s.user_id AND m.org_id=s.org_id WHERE s.id=?`, family).Scan(&orgId)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, "", err
+		}
+		if err == nil {
+			principal.OrgID = orgId
+		}
+	}
+
+	// only one of two concurrent refreshes with the same token can mark it replaced, the other
+	// one is a reuse
+	res, err := tx.ExecContext(sq.ctx, "UPDATE RefreshTokens SET replaced_at=? WHERE id=? AND replaced_at IS NULL AND revoked_at IS NULL", now, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, "", err
+	} else if n == 0 {
+		return nil, "", revokeReused(sq.ctx, tx, family)
+	}
+	newToken, err := insertRefreshToken(sq.ctx, tx, role, userId, family)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tx.ExecContext(sq.ctx, "UPDATE Sessions SET org_id=?, last_seen_at=?, expires_at=? WHERE id=?", principal.OrgID, now, time.Now().Add(jwt.RefreshTTL()).Unix(), family)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	principal.SessionID = family
+	return principal, newToken, nil
+}
+
+// revokeReused ends the session a reused refresh token belongs to and returns ErrRefreshTokenReused
+func revokeReused(ctx context.Context, tx *sql.Tx, family string) error {
+	if err := revokeSession(ctx, tx, family); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// findPrincipal loads the current email, entity and organization of an admin or account.
+// Admins start in their default organization.
+func findPrincipal(ctx context.Context, db querier, role string, id int64) (*types.Principal, error) {
+	principal := types.Principal{ID: id, Role: role}
+	var err error
+	switch role {
+	case types.RoleAdmin:
+		err = db.QueryRowContext(ctx, "SELECT email FROM Admin WHERE id=? AND active=1", id).Scan(&principal.Email)
+		if err == nil {
+			principal.OrgID, err = defaultOrg(ctx, db, id)
+		}
+	default:
+		err = db.QueryRowContext(ctx, `SELECT a.entity_id,COALESCE(m.email,i.email),COALESCE(m.org_id,i.org_id) FROM Accounts as a
+			LEFT JOIN Mentors as m on a.role='mentor' AND m.id=a.entity_id AND m.deleted_at IS NULL
+			LEFT JOIN Interns as i on a.role='intern' AND i.id=a.entity_id AND i.deleted_at IS NULL
+			WHERE a.id=? AND a.role=? AND COALESCE(m.email,i.email) IS NOT NULL
+			AND (a.password IS NOT NULL OR EXISTS (SELECT 1 FROM OIDCIdentities WHERE role=a.role AND user_id=a.id))`, id, role).Scan(&principal.EntityID, &principal.Email, &principal.OrgID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
+
+// RevokeRefreshFamily ends the session the given refresh token belongs to
+func (sq *Sqlite) RevokeRefreshFamily(principal *types.Principal, token *string) error {
+	if principal == nil || token == nil {
+		return ErrInvalidRefreshToken
+	}
+	var family string
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT family_id FROM RefreshTokens WHERE token_hash=? AND role=? AND user_id=? AND revoked_at IS NULL",
+		hashToken(*token), principal.Role, principal.ID).Scan(&family)
+	if err == sql.ErrNoRows {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return revokeSession(sq.ctx, sq.DB, family)
+}
+
+// revokeSession revokes the session and every refresh token of its family
+func revokeSession(ctx context.Context, db execer, sessionId string) error {
+	now := time.Now().Unix()
+	_, err := db.ExecContext(ctx, "UPDATE RefreshTokens SET revoked_at=? WHERE family_id=? AND revoked_at IS NULL", now, sessionId)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "UPDATE Sessions SET revoked_at=? WHERE id=? AND revoked_at IS NULL", now, sessionId)
+	return err
+}
+
+// RevokeToken denylists an access token until it would have expired anyway
+func (sq *Sqlite) RevokeToken(jti *string, expiresAt time.Time) error {
+	if jti == nil || *jti == "" {
+		return errors.New("jti is required")
+	}
+	_, err := sq.DB.ExecContext(sq.ctx, "INSERT INTO RevokedTokens (jti,expires_at) VALUES (?,?) ON CONFLICT DO NOTHING", jti, expiresAt.Unix())
+	if err != nil {
+		return err
+	}
+	// expired entries can never match a valid token again
+	_, err = sq.DB.ExecContext(sq.ctx, "DELETE FROM RevokedTokens WHERE expires_at < ?", time.Now().Unix())
+	return err
+}
+
+// IsTokenRevoked reports whether the access token was denylisted by jti, belongs to a
+// revoked session or a deactivated admin, names an organization the admin was removed from, or
+// was issued before the user's tokens were cut off (e.g. by a password reset). Both times are in
+// milliseconds, a token issued right after the cutoff is still accepted.
+func (sq *Sqlite) IsTokenRevoked(ctx context.Context, claims *types.CustomClaims) (bool, error) {
+	sq = sq.WithContext(ctx)
+	var found int
+	err := sq.DB.QueryRowContext(sq.ctx, `SELECT 1 FROM RevokedTokens WHERE jti=?
+		UNION ALL
+		SELECT 1 FROM Sessions WHERE id=? AND revoked_at IS NOT NULL
+		UNION ALL
+		SELECT 1 FROM Admin WHERE ?='admin' AND id=? AND active=0
+		UNION ALL
+		SELECT 1 WHERE ?='admin' AND ?<>0 AND NOT EXISTS (SELECT 1 FROM AdminOrganizations WHERE admin_id=? AND org_id=?)
+		UNION ALL
+		SELECT 1 FROM TokenCutoffs WHERE role=? AND user_id=? AND not_before>?`,
+		claims.RegisteredClaims.ID, claims.SessionID, claims.Role, claims.ID,
+		claims.Role, claims.OrgID, claims.ID, claims.OrgID,
+		claims.Role, claims.ID, issuedAt(claims)).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func issuedAt(claims *types.CustomClaims) int64 {
+	if claims.IssuedAt == nil {
+		return 0
+	}
+	return claims.IssuedAt.UnixMilli()
+}
+
+// revokeAllTokens ends every session of a user: refresh tokens are revoked and access
+// tokens issued before now stop being accepted
+func revokeAllTokens(ctx context.Context, db execer, role string, userId int64) error {
+	now := time.Now().Unix()
+	cutoff := time.Now().UnixMilli()
+	_, err := db.ExecContext(ctx, "UPDATE RefreshTokens SET revoked_at=? WHERE role=? AND user_id=? AND revoked_at IS NULL", now, role, userId)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "UPDATE Sessions SET revoked_at=? WHERE role=? AND user_id=? AND revoked_at IS NULL", now, role, userId)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO TokenCutoffs (role,user_id,not_before) VALUES (?,?,?)
+		ON CONFLICT (role,user_id) DO UPDATE SET not_before=excluded.not_before`, role, userId, cutoff)
+	return err
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TrashTables are the tables of the entities that go to the trash when they are deleted
+var TrashTables = map[string]string{"mentor": "Mentors", "intern": "Interns", "project": "Projects", "assignment": "Assignments"}
+
+// purgeOrder has every trash table after the tables that point at it, so rows are purged before
+// the rows they point at
+var purgeOrder = []string{"Assignments", "Interns", "Projects", "Mentors"}
+
+var (
+	ErrNotTrashable   = errors.New("only mentors, interns, projects and assignments go to the trash")
+	ErrRestoreBlocked = errors.New("a record this one points at is in the trash")
+	ErrEmailTaken     = errors.New("another record of the organization uses its email now")
+)
+
+// TrashedRecord is a deleted row that can still be restored
+type TrashedRecord struct {
+	Entity    string    `json:"entity"`
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// RestoreError refuses a restore and lists the trashed rows the restored one would point at
+type RestoreError struct {
+	Entity  string
+	Id      int64
+	Parents []Dependent
+}
+
+func (e *RestoreError) Error() string {
+	parent := e.Parents[0]
+	return fmt.Sprintf("%s %d can't be restored, its %s %d is in the trash", e.Entity, e.Id, parent.Entity, parent.Id)
+}
+
+func (e *RestoreError) Is(target error) bool {
+	return target == ErrRestoreBlocked
+}
+
+// BlockingDependents is what response.WriteDependentsError lists, the parents to restore first
+func (e *RestoreError) BlockingDependents() any {
+	return e.Parents
+}
+
+// GetTrash lists the trashed rows of the organization, the most recently deleted first. entity
+// limits it to one kind, all of them are listed when it's empty.
+func (sq *Sqlite) GetTrash(entity string) ([]TrashedRecord, error) {
+	tables := purgeOrder
+	if entity != "" {
+		table, ok := TrashTables[entity]
+		if !ok {
+			return nil, ErrNotTrashable
+		}
+		tables = []string{table}
+	}
+
+	list := []TrashedRecord{}
+	for _, table := range tables {
+		rows, err := sq.DB.QueryContext(sq.ctx, "SELECT id,COALESCE("+rowLabels[table]+",''),deleted_at FROM "+table+" WHERE org_id=? AND deleted_at IS NOT NULL", sq.orgId)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			record := TrashedRecord{Entity: entityName(table)}
+			var deletedAt int64
+			if err := rows.Scan(&record.Id, &record.Name, &deletedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			record.DeletedAt = time.Unix(deletedAt, 0).UTC()
+			list = append(list, record)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	SortTrash(list)
+	return list, nil
+}
+
+// SortTrash orders trashed rows the way GetTrash returns them
+func SortTrash(list []TrashedRecord) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].DeletedAt.Equal(list[j].DeletedAt) {
+			return list[i].DeletedAt.After(list[j].DeletedAt)
+		}
+		if list[i].Entity != list[j].Entity {
+			return list[i].Entity < list[j].Entity
+		}
+		return list[i].Id < list[j].Id
+	})
+}
+
+// Restore takes a row of the organization out of the trash, along with the rows that were
+// trashed with it. It's refused while a row it points at is in the trash; rows trashed with it
+// that point at a trashed row stay in the trash. It returns the rows restored along with it.
+func (sq *Sqlite) Restore(entity string, id int64) ([]Dependent, error) {
+	table, ok := TrashTables[entity]
+	if !ok {
+		return nil, ErrNotTrashable
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deletedAt int64
+	err = tx.QueryRowContext(sq.ctx, "SELECT deleted_at FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NOT NULL", id, sq.orgId).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: no %s with id %d in the trash", ErrRecordNotFound, entity, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	restored, err := sq.restoreRow(tx, table, id, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return restored, tx.Commit()
+}
+
+func (sq *Sqlite) restoreRow(tx *sql.Tx, table string, id int64, deletedAt int64) ([]Dependent, error) {
+	if err := sq.requireParents(tx, table, id); err != nil {
+		return nil, err
+	}
+	_, err := tx.ExecContext(sq.ctx, "UPDATE "+table+" SET deleted_at=NULL, version=version+1 WHERE id=? AND org_id=?", id, sq.orgId)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: %s %d can't be restored", ErrEmailTaken, entityName(table), id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := sq.audit(tx, table, id, AuditRestore, nil); err != nil {
+		return nil, err
+	}
+
+	restored := []Dependent{}
+	for _, rel := range Relationships {
+		if rel.Parent != table || rel.OnDelete != Cascade {
+			continue
+		}
+		children, err := sq.dependents(tx, rel, id, "deleted_at=?", deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			more, err := sq.restoreRow(tx, rel.Child, child.Id, deletedAt)
+			if errors.Is(err, ErrRestoreBlocked) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			restored = append(append(restored, child), more...)
+		}
+	}
+	return restored, nil
+}
+
+// requireParents fails with a RestoreError when a row the row points at is in the trash
+func (sq *Sqlite) requireParents(db rowReader, table string, id int64) error {
+	var trashed []Dependent
+	for _, rel := range Relationships {
+		if rel.Child != table {
+			continue
+		}
+		parent := Dependent{Entity: entityName(rel.Parent)}
+		err := db.QueryRowContext(sq.ctx, "SELECT id,COALESCE("+rowLabels[rel.Parent]+",'') FROM "+rel.Parent+
+			" WHERE id=(SELECT "+rel.Column+" FROM "+table+" WHERE id=?) AND deleted_at IS NOT NULL", id).Scan(&parent.Id, &parent.Name)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		trashed = append(trashed, parent)
+	}
+	if len(trashed) > 0 {
+		return &RestoreError{Entity: entityName(table), Id: id, Parents: trashed}
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes the rows of every organization that were trashed before the
+// given time. A row is kept as long as any row, trashed or not, still points at it.
+func (sq *Sqlite) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	sq = sq.WithContext(ctx)
+	var purged int64
+	for _, table := range purgeOrder {
+		query := "DELETE FROM " + table + " WHERE deleted_at IS NOT NULL AND deleted_at<?"
+		for _, rel := range Relationships {
+			if rel.Parent == table {
+				query += " AND NOT EXISTS (SELECT 1 FROM " + rel.Child + " WHERE " + rel.Child + "." + rel.Column + "=" + table + ".id)"
+			}
+		}
+		res, err := sq.DB.ExecContext(sq.ctx, query, before.Unix())
+		if err != nil {
+			return purged, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+	return purged, nil
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Aytaditya/slotwise/internal/totp"
+	"github.com/Aytaditya/slotwise/internal/types"
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+const recoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// normalizeRecoveryCode lets users type codes with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	b := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes drops the admin's old codes and stores hashes of a fresh set
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, adminId int64) ([]string, error) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM RecoveryCodes WHERE admin_id=?", adminId); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO RecoveryCodes (admin_id,code_hash) VALUES (?,?)", adminId, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func (sq *Sqlite) GetTwoFactorStatus(adminId int64) (*types.TwoFactorStatus, error) {
+	status := types.TwoFactorStatus{}
+	var enabledAt sql.NullInt64
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT enabled_at FROM TwoFactor WHERE admin_id=?", adminId).Scan(&enabledAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	status.Enabled = enabledAt.Valid
+	if status.Enabled {
+		err = sq.DB.QueryRowContext(sq.ctx, "SELECT COUNT(*) FROM RecoveryCodes WHERE admin_id=? AND used_at IS NULL", adminId).Scan(&status.RecoveryCodesLeft)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &status, nil
+}
+
+// BeginTwoFactor stores a new pending secret for the admin, replacing any unconfirmed one
+func (sq *Sqlite) BeginTwoFactor(adminId int64) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	res, err := sq.DB.ExecContext(sq.ctx, `INSERT INTO TwoFactor (admin_id,secret,created_at) VALUES (?,?,?)
+		ON CONFLICT (admin_id) DO UPDATE SET secret=excluded.secret, created_at=excluded.created_at, last_step=0
+		WHERE TwoFactor.enabled_at IS NULL`, adminId, secret, time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", ErrTwoFactorEnabled
+	}
+	return secret, nil
+}
+
+// ConfirmTwoFactor enables the pending secret once the admin proves their app generates the
+// right codes, and returns the one-time recovery codes
+func (sq *Sqlite) ConfirmTwoFactor(adminId int64, code *string) ([]string, error) {
+	if code == nil || *code == "" {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret string
+	var enabledAt sql.NullInt64
+	err = tx.QueryRowContext(sq.ctx, "SELECT secret,enabled_at FROM TwoFactor WHERE admin_id=?", adminId).Scan(&secret, &enabledAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	step, ok := totp.Validate(secret, *code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	_, err = tx.ExecContext(sq.ctx, "UPDATE TwoFactor SET enabled_at=?, last_step=? WHERE admin_id=?", time.Now().Unix(), step, adminId)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(sq.ctx, tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactor accepts a current TOTP code or an unused recovery code. A TOTP code is
+// only accepted once, so a code seen over the shoulder can't be replayed.
+func (sq *Sqlite) VerifyTwoFactor(adminId int64, code *string) error {
+	if code == nil || *code == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	var secret string
+	var lastStep int64
+	err := sq.DB.QueryRowContext(sq.ctx, "SELECT secret,last_step FROM TwoFactor WHERE admin_id=? AND enabled_at IS NOT NULL", adminId).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	if step, ok := totp.Validate(secret, *code, time.Now(), 1); ok {
+		res, err1 := sq.DB.ExecContext(sq.ctx, "UPDATE TwoFactor SET last_step=? WHERE admin_id=? AND last_step<?", step, adminId, step)
+		if err1 != nil {
+			return err1
+		}
+		n, err1 := res.RowsAffected()
+		if err1 != nil {
+			return err1
+		}
+		if n == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	res, err := sq.DB.ExecContext(sq.ctx, "UPDATE RecoveryCodes SET used_at=? WHERE admin_id=? AND code_hash=? AND used_at IS NULL",
+		time.Now().Unix(), adminId, hashToken(normalizeRecoveryCode(*code)))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates the remaining recovery codes and returns a new set
+func (sq *Sqlite) RegenerateRecoveryCodes(adminId int64) ([]string, error) {
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRowContext(sq.ctx, "SELECT enabled_at IS NOT NULL FROM TwoFactor WHERE admin_id=?", adminId).Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	codes, err := replaceRecoveryCodes(sq.ctx, tx, adminId)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (sq *Sqlite) DisableTwoFactor(adminId int64) error {
+	tx, err := sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(sq.ctx, "DELETE FROM TwoFactor WHERE admin_id=?", adminId); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(sq.ctx, "DELETE FROM RecoveryCodes WHERE admin_id=?", adminId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
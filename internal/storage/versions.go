@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrVersionMismatch refuses to change a record that was changed since the caller read it
+var ErrVersionMismatch = errors.New("the record has changed since it was read")
+
+// versioned makes an UPDATE of one row only match while the row is at version, nil leaves it as is
+func versioned(query string, args []any, version *int64) (string, []any) {
+	if version == nil {
+		return query, args
+	}
+	return query + " AND version=?", append(args, *version)
+}
+
+// checkUpdated explains a versioned update that changed nothing, with ErrRecordNotFound when the
+// row is gone or in the trash and ErrVersionMismatch when it has moved on
+func (sq *Sqlite) checkUpdated(db querier, res sql.Result, table string, id int64, version *int64) error {
+	if version == nil {
+		return nil
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	return sq.requireVersion(db, table, id, version)
+}
+
+// requireVersion fails unless the row of the organization is at version, nil accepts any
+func (sq *Sqlite) requireVersion(db querier, table string, id int64, version *int64) error {
+	if version == nil {
+		return nil
+	}
+	var current int64
+	err := db.QueryRowContext(sq.ctx, "SELECT version FROM "+table+" WHERE id=? AND org_id=? AND deleted_at IS NULL", id, sq.orgId).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: no %s with id %d", ErrRecordNotFound, entityName(table), id)
+	}
+	if err != nil {
+		return err
+	}
+	if current != *version {
+		return fmt.Errorf("%w: %s %d is at version %d, not %d", ErrVersionMismatch, entityName(table), id, current, *version)
+	}
+	return nil
+}
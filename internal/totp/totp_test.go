@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// the SHA1 vectors of RFC 6238 appendix B, which lists 8 digits; the 6 digit codes are their
+// last six
+func TestCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	code, err := Code(secret, current-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if step, ok := Validate(secret, code, now, 1); !ok || step != current-1 {
+		t.Fatalf("a code from the previous step was refused with a skew of 1: %d, %v", step, ok)
+	}
+	if _, ok := Validate(secret, code, now, 0); ok {
+		t.Fatal("a code from the previous step was accepted without skew")
+	}
+	if _, ok := Validate(secret, " "+code+" ", now, 1); !ok {
+		t.Fatal("surrounding spaces were not ignored")
+	}
+	for _, bad := range []string{"", "12345", "1234567", "000000"} {
+		if _, ok := Validate(secret, bad, now, 1); ok {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+	if _, ok := Validate("not base32!", code, now, 1); ok {
+		t.Fatal("a code was accepted for an invalid secret")
+	}
+}
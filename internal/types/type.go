@@ -13,16 +13,229 @@ type Login struct {
 	Password string `json:"password"`
 }
 
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+const (
+	RoleAdmin  = "admin"
+	RoleMentor = "mentor"
+	RoleIntern = "intern"
+	RoleAPIKey = "api_key" // service integrations, limited by the key's scopes
+)
+
+// Principal is whoever a token is issued to: an admin or a mentor/intern account
+type Principal struct {
+	ID        int64  `json:"id"` // Admin.id for admins, Accounts.id otherwise
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	EntityID  int64  `json:"entity_id,omitempty"`
+	OrgID     int64  `json:"org_id"`
+	SessionID string `json:"-"` // set once a login session has been started
+}
+
 type CustomClaims struct {
-	ID    int64  `json:"id"`
-	Email string `json:"email"`
+	ID        int64    `json:"id"`
+	Email     string   `json:"email"`
+	Role      string   `json:"role"`
+	EntityID  int64    `json:"entity_id,omitempty"` // Mentors.id or Interns.id for mentor and intern roles
+	Scopes    []string `json:"scopes,omitempty"`    // api keys only, never part of a signed token
+	SessionID string   `json:"sid,omitempty"`
+	OrgID     int64    `json:"org_id,omitempty"` // the organization whose data the token can reach
 	jwt.RegisteredClaims
 }
 
-type Intern struct {
-	Name     string `json:"name"`
+// Scope restricts list queries to the rows a principal owns, zero values mean unrestricted
+type Scope struct {
+	MentorId int64
+	InternId int64
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type UnlockLogin struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+}
+
+type ReturnLoginAttempt struct {
+	Id        int64  `json:"id"`
+	Email     string `json:"email"`
+	IP        string `json:"ip"`
+	Reason    string `json:"reason"` // invalid_credentials, invalid_2fa_code or locked_out
+	CreatedAt string `json:"created_at"`
+}
+
+type LoginAttemptFilter struct {
+	Email string
+	IP    string
+	Since int64
+	Limit int
+}
+
+type ForgotPassword struct {
+	Email string `json:"email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ReturnOrganization struct {
+	Id        int64  `json:"id"`
+	Name      string `json:"name"`
+	Current   bool   `json:"current"` // the organization the caller's token works in
+	CreatedAt string `json:"created_at"`
+}
+
+type CreateOrganization struct {
+	Name string `json:"name"`
+}
+
+type SwitchOrganization struct {
+	OrgId int64 `json:"org_id"`
+}
+
+type OrgMember struct {
+	AdminId int64 `json:"admin_id"`
+}
+
+// ReturnOrgInvitation is a pending invitation of the caller into another organization
+type ReturnOrgInvitation struct {
+	Id        int64  `json:"id"`
+	OrgId     int64  `json:"org_id"`
+	OrgName   string `json:"org_name"`
+	InvitedBy string `json:"invited_by"` // email of the admin who sent it
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type ReturnAdmin struct {
+	Id               int64  `json:"id"`
+	Username         string `json:"username"`
+	Email            string `json:"email"`
+	Active           bool   `json:"active"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+}
+
+type UpdateAdmin struct {
+	Username string `json:"username"`
 	Email    string `json:"email"`
-	MentorId int64  `json:"mentor_id"`
+}
+
+// SetPassword is an admin replacing a colleague's password
+type SetPassword struct {
+	Password string `json:"password"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code"` // TOTP code or recovery code
+}
+
+// TwoFactorLogin finishes a login that answered with a challenge token
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+type ReturnSession struct {
+	Id         string `json:"id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	Current    bool   `json:"current"` // the session making the request
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+}
+
+// RevokeSessions names the user whose sessions an admin ends: user_id for admins,
+// entity_id (the mentor or intern row) otherwise
+type RevokeSessions struct {
+	Role     string `json:"role"`
+	UserId   int64  `json:"user_id,omitempty"`
+	EntityId int64  `json:"entity_id,omitempty"`
+}
+
+type CreateAPIKey struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`     // e.g. interns:read, assignments:write
+	ExpiresAt string   `json:"expires_at"` // RFC3339, empty for keys that don't expire
+}
+
+type ReturnAPIKey struct {
+	Id         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"` // first characters of the key, to recognise it
+	Scopes     []string `json:"scopes"`
+	CreatedBy  int64    `json:"created_by"`
+	Status     string   `json:"status"` // active, revoked or expired
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+}
+
+type CreateInvitation struct {
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	EntityId int64  `json:"entity_id,omitempty"` // the mentor or intern row the invitee will log in as
+}
+
+type AcceptInvitation struct {
+	Username string `json:"username"` // admins only
+	Password string `json:"password"`
+}
+
+type ReturnInvitation struct {
+	Id        int64  `json:"id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	EntityId  int64  `json:"entity_id,omitempty"`
+	InvitedBy int64  `json:"invited_by"`
+	Status    string `json:"status"` // pending, accepted, revoked or expired
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type Intern struct {
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	MentorId      int64  `json:"mentor_id"`
+	CreateAccount bool   `json:"create_account"` // also create a login account and return its activation link
+}
+
+type CreateAccount struct {
+	Role     string `json:"role"`
+	EntityId int64  `json:"entity_id"`
+}
+
+type ActivateAccount struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
 }
 
 type Mentor struct {
@@ -36,6 +249,7 @@ type ReturnMentor struct {
 	Name       string `json:"name"`
 	Email      string `json:"email"`
 	Department string `json:"department"`
+	Version    int64  `json:"version"` // the ETag of the record, send it as If-Match to change it
 }
 
 type ReturnIntern struct {
@@ -46,6 +260,7 @@ type ReturnIntern struct {
 	MentorId    string `json:"mentor_id"`
 	MentorName  string `json:"mentor_name"`
 	MentorEmail string `json:"mentor_email"`
+	Version     int64  `json:"version"`
 }
 
 type UpdateIntern struct {
@@ -69,6 +284,7 @@ type ReturnProject struct {
 	Status      string `json:"status"`
 	StartDate   string `json:"start_date"`
 	EndDate     string `json:"end_date"`
+	Version     int64  `json:"version"`
 }
 
 type UpdateProject struct {
@@ -93,6 +309,7 @@ type ReturnAssignment struct {
 	ProjectName string `json:"project_name"`
 	Progress    int64  `json:"progress"`
 	Remarks     string `json:"remarks"`
+	Version     int64  `json:"version"`
 }
 
 type UpdateAssignment struct {